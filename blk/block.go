@@ -61,10 +61,10 @@ func GetChaintip(ctx context.Context) (*BlockHeader, error) {
 			return nil, err
 		}
 		header := &headerState.Header
+		header.Height = headerState.Height
 		if C != nil && (Chaintip == nil || header.Hash != Chaintip.Hash) {
 			C <- header
 		}
-		header.Height = headerState.Height
 		// header.ChainWork = headerState.ChainWork
 		Chaintip = header
 		updated = time.Now()
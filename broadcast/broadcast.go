@@ -13,6 +13,7 @@ import (
 	"github.com/shruggr/1sat-indexer/v5/idx"
 	"github.com/shruggr/1sat-indexer/v5/jb"
 	"github.com/shruggr/1sat-indexer/v5/lib"
+	"github.com/shruggr/1sat-indexer/v5/metrics"
 )
 
 const MIN_SAT_PER_KB = 100.0
@@ -102,11 +103,14 @@ func Broadcast(ctx context.Context, store idx.TxoStore, tx *transaction.Transact
 	}
 	score := idx.HeightScore(0, 0)
 
-	if err := jb.Cache.Set(ctx, jb.TxKey(response.Txid), tx.Bytes(), 0).Err(); err != nil { //
-		response.Error = err.Error()
-		return
-		// Log Transaction Status as pending
-	} else if err = store.Log(ctx, idx.PendingTxLog, response.Txid, -score); err != nil {
+	if jb.Cache != nil {
+		if err := jb.Cache.Set(ctx, jb.TxKey(response.Txid), tx.Bytes(), 0).Err(); err != nil {
+			response.Error = err.Error()
+			return
+		}
+	}
+	// Log Transaction Status as pending
+	if err := store.Log(ctx, idx.PendingTxLog, response.Txid, -score); err != nil {
 		response.Error = err.Error()
 		return
 	}
@@ -133,7 +137,9 @@ func Broadcast(ctx context.Context, store idx.TxoStore, tx *transaction.Transact
 	}
 
 	// Broadcast directly and handle immediate response
+	arcStart := time.Now()
 	arcResp, err := arcBroadcaster.ArcBroadcast(ctx, tx)
+	metrics.ObserveRequest(metrics.ServiceArc, "broadcast", arcStart, err)
 	if err != nil {
 		rollbackSpends(ctx, store, spendOutpoints, response.Txid)
 		response.Error = err.Error()
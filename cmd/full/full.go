@@ -13,8 +13,10 @@ import (
 	"github.com/redis/go-redis/v9"
 	"github.com/shruggr/1sat-indexer/v5/config"
 	"github.com/shruggr/1sat-indexer/v5/idx"
+	metricsstore "github.com/shruggr/1sat-indexer/v5/idx/metrics-store"
 	"github.com/shruggr/1sat-indexer/v5/ingest"
 	"github.com/shruggr/1sat-indexer/v5/jb"
+	"github.com/shruggr/1sat-indexer/v5/mod/onesat"
 	"github.com/shruggr/1sat-indexer/v5/server"
 )
 
@@ -47,6 +49,13 @@ func init() {
 
 func main() {
 	ctx := context.Background()
+	config.Store = metricsstore.NewMetricsStore(config.Store)
+	if err := onesat.ApplyPolicies(ctx, config.Store, config.Indexers, time.Minute); err != nil {
+		log.Panic(err)
+	}
+	if err := onesat.ApplyFunds(ctx, config.Store, config.Indexers, time.Minute); err != nil {
+		log.Panic(err)
+	}
 
 	go func() {
 		for {
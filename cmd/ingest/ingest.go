@@ -5,11 +5,15 @@ import (
 	"flag"
 	"log"
 	"os"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/shruggr/1sat-indexer/v5/config"
 	"github.com/shruggr/1sat-indexer/v5/idx"
+	metricsstore "github.com/shruggr/1sat-indexer/v5/idx/metrics-store"
 	"github.com/shruggr/1sat-indexer/v5/ingest"
+	"github.com/shruggr/1sat-indexer/v5/metrics"
+	"github.com/shruggr/1sat-indexer/v5/mod/onesat"
 )
 
 var CONCURRENCY uint
@@ -34,6 +38,14 @@ func init() {
 
 func main() {
 	ctx := context.Background()
+	config.Store = metricsstore.NewMetricsStore(config.Store)
+	if err := onesat.ApplyPolicies(ctx, config.Store, config.Indexers, time.Minute); err != nil {
+		log.Panic(err)
+	}
+	if err := onesat.ApplyFunds(ctx, config.Store, config.Indexers, time.Minute); err != nil {
+		log.Panic(err)
+	}
+	metrics.Serve()
 
 	// Setup Redis client for event publishing
 	var redisClient *redis.Client
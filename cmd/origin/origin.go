@@ -10,6 +10,8 @@ import (
 	"github.com/shruggr/1sat-indexer/v5/config"
 	"github.com/shruggr/1sat-indexer/v5/evt"
 	"github.com/shruggr/1sat-indexer/v5/idx"
+	metricsstore "github.com/shruggr/1sat-indexer/v5/idx/metrics-store"
+	"github.com/shruggr/1sat-indexer/v5/metrics"
 	"github.com/shruggr/1sat-indexer/v5/mod/onesat"
 )
 
@@ -36,13 +38,14 @@ func init() {
 		Tag:      "origin",
 		Indexers: config.Indexers,
 		Network:  config.Network,
-		Store:    config.Store,
+		Store:    metricsstore.NewMetricsStore(config.Store),
 	}
 }
 
 func main() {
 	flag.UintVar(&CONCURRENCY, "c", 1, "Concurrency")
 	flag.Parse()
+	metrics.Serve()
 	go func() {
 		failed := make(chan string, 100)
 		limiter := make(chan struct{}, CONCURRENCY)
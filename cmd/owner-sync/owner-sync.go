@@ -9,7 +9,9 @@ import (
 	"github.com/redis/go-redis/v9"
 	"github.com/shruggr/1sat-indexer/v5/config"
 	"github.com/shruggr/1sat-indexer/v5/idx"
+	metricsstore "github.com/shruggr/1sat-indexer/v5/idx/metrics-store"
 	"github.com/shruggr/1sat-indexer/v5/jb"
+	"github.com/shruggr/1sat-indexer/v5/metrics"
 )
 
 var ctx = context.Background()
@@ -21,10 +23,11 @@ func init() {
 	flag.StringVar(&TAG, "tag", idx.IngestTag, "Ingest tag")
 	flag.IntVar(&CONCURRENCY, "c", 1, "Concurrency")
 	flag.Parse()
-	store = config.Store
+	store = metricsstore.NewMetricsStore(config.Store)
 }
 
 func main() {
+	metrics.Serve()
 	for {
 		if results, err := store.Search(ctx, &idx.SearchCfg{
 			Keys: []string{idx.OwnerSyncKey},
@@ -1,21 +1,27 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"log"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/shruggr/1sat-indexer/v5/config"
 	"github.com/shruggr/1sat-indexer/v5/idx"
+	memstore "github.com/shruggr/1sat-indexer/v5/idx/mem-store"
+	metricsstore "github.com/shruggr/1sat-indexer/v5/idx/metrics-store"
+	"github.com/shruggr/1sat-indexer/v5/mod/onesat"
 	"github.com/shruggr/1sat-indexer/v5/server"
 )
 
 var PORT int
 var CONCURRENCY uint
 var VERBOSE int
+var DRYRUN bool
 
 func init() {
 	wd, _ := os.Getwd()
@@ -26,10 +32,22 @@ func init() {
 	flag.IntVar(&PORT, "p", PORT, "Port to listen on")
 	flag.UintVar(&CONCURRENCY, "c", 1, "Concurrency")
 	flag.IntVar(&VERBOSE, "v", 0, "Verbose")
+	flag.BoolVar(&DRYRUN, "dry-run", false, "Index into an in-memory store")
 	flag.Parse()
 }
 
 func main() {
+	if DRYRUN {
+		log.Println("Dry run: using in-memory store")
+		config.Store = memstore.NewMemStore()
+	}
+	config.Store = metricsstore.NewMetricsStore(config.Store)
+	if err := onesat.ApplyPolicies(context.Background(), config.Store, config.Indexers, time.Minute); err != nil {
+		log.Panic(err)
+	}
+	if err := onesat.ApplyFunds(context.Background(), config.Store, config.Indexers, time.Minute); err != nil {
+		log.Panic(err)
+	}
 	app := server.Initialize(&idx.IngestCtx{
 		Tag:         idx.IngestTag,
 		Indexers:    config.Indexers,
@@ -6,6 +6,7 @@ import (
 	"log"
 
 	"github.com/shruggr/1sat-indexer/v5/idx"
+	"github.com/shruggr/1sat-indexer/v5/metrics"
 	"github.com/shruggr/1sat-indexer/v5/mod/onesat"
 	"github.com/shruggr/1sat-indexer/v5/sub"
 )
@@ -43,6 +44,7 @@ func init() {
 }
 
 func main() {
+	metrics.Serve()
 	if err := (&sub.Sub{
 		Tag:          TAG,
 		Queue:        QUEUE,
@@ -31,7 +31,9 @@ func init() {
 	godotenv.Load(fmt.Sprintf(`%s/../../.env`, wd))
 
 	log.Println("REDISEVT", os.Getenv("REDISEVT"))
-	if opts, err := redis.ParseURL(os.Getenv("REDISEVT")); err != nil {
+	if os.Getenv("REDISEVT") == "" {
+		return
+	} else if opts, err := redis.ParseURL(os.Getenv("REDISEVT")); err != nil {
 		panic(err)
 	} else {
 		db = redis.NewClient(opts)
@@ -39,5 +41,8 @@ func init() {
 }
 
 func Publish(ctx context.Context, event string, data string) error {
+	if db == nil {
+		return nil
+	}
 	return db.Publish(ctx, event, data).Err()
 }
@@ -23,7 +23,7 @@ func SyncOwner(ctx context.Context, tag string, own string, ing *IngestCtx) erro
 	log.Println("Syncing:", own)
 	if lastHeight, err := ing.Store.LogScore(ctx, OwnerSyncKey, own); err != nil {
 		return err
-	} else if addTxns, err := jb.FetchOwnerTxns(own, int(lastHeight)); err != nil {
+	} else if addTxns, err := ing.TxSource().FetchOwnerTxns(ctx, own, int(lastHeight)); err != nil {
 		log.Println("FetchOwnerTxns:", err)
 		return err
 	} else {
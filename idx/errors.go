@@ -0,0 +1,54 @@
+package idx
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/shruggr/1sat-indexer/v5/jb"
+)
+
+var ErrMissingInput = errors.New("missing-input")
+var ErrStoreUnavailable = errors.New("store-unavailable")
+var ErrMalformedData = errors.New("malformed-data")
+
+// IngestError describes why a transaction could not be indexed. Kind is one of
+// ErrMissingInput, ErrStoreUnavailable or ErrMalformedData and matches with errors.Is.
+type IngestError struct {
+	Txid string
+	Kind error
+	Err  error
+}
+
+func NewIngestError(txid string, kind error, err error) *IngestError {
+	return &IngestError{
+		Txid: txid,
+		Kind: kind,
+		Err:  err,
+	}
+}
+
+func (e *IngestError) Error() string {
+	return fmt.Sprintf("%v %s: %v", e.Kind, e.Txid, e.Err)
+}
+
+func (e *IngestError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// IsPermanent reports whether retrying the ingest of a transaction that failed
+// with err can never succeed
+func IsPermanent(err error) bool {
+	return errors.Is(err, ErrMalformedData) ||
+		errors.Is(err, jb.ErrMalformed) ||
+		errors.Is(err, jb.ErrBadRequest)
+}
+
+// ErrorKind names the kind of an ingest error for logging and metrics
+func ErrorKind(err error) string {
+	for _, kind := range []error{ErrMissingInput, ErrStoreUnavailable, ErrMalformedData} {
+		if errors.Is(err, kind) {
+			return kind.Error()
+		}
+	}
+	return "other"
+}
@@ -0,0 +1,50 @@
+package idx_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bsv-blockchain/go-sdk/chainhash"
+	"github.com/bsv-blockchain/go-sdk/script"
+	"github.com/bsv-blockchain/go-sdk/transaction"
+	"github.com/shruggr/1sat-indexer/v5/idx"
+	memstore "github.com/shruggr/1sat-indexer/v5/idx/mem-store"
+	"github.com/shruggr/1sat-indexer/v5/jb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngestMissingInput(t *testing.T) {
+	parent := chainhash.DoubleHashH([]byte("missing parent"))
+	tx := transaction.NewTransaction()
+	tx.Inputs = append(tx.Inputs, &transaction.TransactionInput{
+		SourceTXID:       &parent,
+		SourceTxOutIndex: 0,
+		SequenceNumber:   0xffffffff,
+		UnlockingScript:  &script.Script{},
+	})
+	tx.AddOutput(&transaction.TransactionOutput{
+		Satoshis:      1,
+		LockingScript: &script.Script{script.OpTRUE},
+	})
+
+	ingest := &idx.IngestCtx{
+		Store:  memstore.NewMemStore(),
+		Source: jb.NewMemorySource(),
+	}
+	_, err := ingest.IngestTx(context.Background(), tx, idx.AncestorConfig{Load: true})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, idx.ErrMissingInput)
+	assert.False(t, idx.IsPermanent(err))
+
+	var ingestErr *idx.IngestError
+	require.True(t, errors.As(err, &ingestErr))
+	assert.Equal(t, tx.TxID().String(), ingestErr.Txid)
+}
+
+func TestFromBytesMalformed(t *testing.T) {
+	_, err := idx.BaseIndexer{}.FromBytes([]byte("{"))
+	assert.ErrorIs(t, err, idx.ErrMalformedData)
+	assert.True(t, idx.IsPermanent(err))
+}
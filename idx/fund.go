@@ -1,8 +1,10 @@
 package idx
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"sync"
 	"time"
 
 	ec "github.com/bsv-blockchain/go-sdk/primitives/ec"
@@ -17,6 +19,32 @@ import (
 
 const SATS_PER_KB = uint64(10)
 
+var fundLocks = struct {
+	sync.Mutex
+	until map[string]time.Time
+}{until: make(map[string]time.Time)}
+
+// lockFundUtxo reserves a funding output for a minute so concurrent funding does
+// not spend it twice. Without a cache the reservation only holds in this process.
+func lockFundUtxo(ctx context.Context, outpoint string) (bool, error) {
+	if jb.Cache != nil {
+		return jb.Cache.SetNX(ctx, LockKey(outpoint), time.Now().Unix(), time.Minute).Result()
+	}
+	fundLocks.Lock()
+	defer fundLocks.Unlock()
+	now := time.Now()
+	for op, until := range fundLocks.until {
+		if until.Before(now) {
+			delete(fundLocks.until, op)
+		}
+	}
+	if _, ok := fundLocks.until[outpoint]; ok {
+		return false, nil
+	}
+	fundLocks.until[outpoint] = now.Add(time.Minute)
+	return true, nil
+}
+
 func (idxCtx *IndexContext) FundAndSignTx(priv *ec.PrivateKey) error {
 	var satsIn, satsOut uint64
 	tx := idxCtx.Tx
@@ -71,7 +99,7 @@ func (idxCtx *IndexContext) FundAndSignTx(priv *ec.PrivateKey) error {
 				if outpoint, err := lib.NewOutpointFromString(op); err != nil {
 					log.Println(err)
 					log.Panic(err)
-				} else if locked, err := jb.Cache.SetNX(idxCtx.Ctx, LockKey(op), time.Now().Unix(), time.Minute).Result(); err != nil {
+				} else if locked, err := lockFundUtxo(idxCtx.Ctx, op); err != nil {
 					log.Println(err)
 					log.Panic(err)
 				} else if !locked {
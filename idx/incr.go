@@ -0,0 +1,81 @@
+package idx
+
+import (
+	"slices"
+	"strings"
+)
+
+// Incr moves the score of Member in Key by Delta when a transaction is committed.
+// Members whose score falls to zero or below are removed. Use a Write to log a
+// member under a score of the indexer's choosing.
+type Incr struct {
+	Key    string
+	Member string
+	Delta  float64
+}
+
+// IncrKey journals the increments committed for txid, so committing the
+// transaction again replaces them and rolling it back reverts them
+func IncrKey(txid string) string {
+	return "inc:" + txid
+}
+
+// Incr adds an increment to be applied when the transaction is committed
+func (idxCtx *IndexContext) Incr(key string, member string, delta float64) {
+	idxCtx.Incrs = append(idxCtx.Incrs, &Incr{
+		Key:    key,
+		Member: member,
+		Delta:  delta,
+	})
+}
+
+// JournalMember identifies the key and member of an increment in IncrKey
+func (i *Incr) JournalMember() string {
+	return i.Key + "|" + i.Member
+}
+
+// ParseIncr reads an increment back from its IncrKey journal entry
+func ParseIncr(l *Log) *Incr {
+	key, member, _ := strings.Cut(l.Member, "|")
+	return &Incr{
+		Key:    key,
+		Member: member,
+		Delta:  l.Score,
+	}
+}
+
+// MergeIncrs sums the increments to each key and member, dropping those that cancel out
+func MergeIncrs(incrs []*Incr) []*Incr {
+	merged := make(map[string]*Incr, len(incrs))
+	for _, incr := range incrs {
+		if m, ok := merged[incr.JournalMember()]; ok {
+			m.Delta += incr.Delta
+		} else {
+			m := *incr
+			merged[incr.JournalMember()] = &m
+		}
+	}
+	result := make([]*Incr, 0, len(merged))
+	for _, incr := range merged {
+		if incr.Delta != 0 {
+			result = append(result, incr)
+		}
+	}
+	// A stable order keeps concurrent commits from deadlocking on row locks
+	slices.SortFunc(result, func(a, b *Incr) int {
+		return strings.Compare(a.JournalMember(), b.JournalMember())
+	})
+	return result
+}
+
+// NetIncrs returns the changes which move the scores from the increments
+// journaled by a previous commit, prev, to incrs
+func NetIncrs(prev []*Log, incrs []*Incr) []*Incr {
+	net := make([]*Incr, 0, len(prev)+len(incrs))
+	for _, l := range prev {
+		incr := ParseIncr(l)
+		incr.Delta = -incr.Delta
+		net = append(net, incr)
+	}
+	return MergeIncrs(append(net, incrs...))
+}
@@ -3,7 +3,7 @@ package idx
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"fmt"
 	"time"
 
 	"github.com/bsv-blockchain/go-sdk/chainhash"
@@ -11,6 +11,7 @@ import (
 	"github.com/bsv-blockchain/go-sdk/transaction"
 	"github.com/shruggr/1sat-indexer/v5/jb"
 	"github.com/shruggr/1sat-indexer/v5/lib"
+	"github.com/shruggr/1sat-indexer/v5/metrics"
 )
 
 func HeightScore(height uint32, idx uint64) float64 {
@@ -35,12 +36,15 @@ type IndexContext struct {
 	Score          float64                  `json:"score"`
 	Txos           []*Txo                   `json:"txos"`
 	Spends         []*Txo                   `json:"spends"`
+	Incrs          []*Incr                  `json:"-"`
+	Writes         []*Write                 `json:"-"`
 	Indexers       []Indexer                `json:"-"`
 	Ctx            context.Context          `json:"-"`
 	Network        lib.Network              `json:"-"`
 	tags           []string                 `json:"-"`
 	ancestorConfig AncestorConfig           `json:"-"`
 	Store          TxoStore                 `json:"-"`
+	Source         jb.TxSource              `json:"-"`
 }
 
 func NewIndexContext(ctx context.Context, store TxoStore, tx *transaction.Transaction, indexers []Indexer, ancestorConfig AncestorConfig, network ...lib.Network) *IndexContext {
@@ -53,6 +57,7 @@ func NewIndexContext(ctx context.Context, store TxoStore, tx *transaction.Transa
 		Indexers:       indexers,
 		Ctx:            ctx,
 		Store:          store,
+		Source:         jb.Source,
 		ancestorConfig: ancestorConfig,
 	}
 	if len(network) > 0 {
@@ -93,31 +98,35 @@ func (idxCtx *IndexContext) ParseSpends() (err error) {
 		outpoint := lib.NewOutpointFromHash(txin.SourceTXID, txin.SourceTxOutIndex)
 		op := outpoint.String()
 		if spend, err := idxCtx.Store.LoadTxo(idxCtx.Ctx, op, idxCtx.tags, false, false); err != nil {
-			log.Panic(err)
-			return err
+			return NewIngestError(idxCtx.TxidHex, ErrStoreUnavailable, err)
 		} else if spend != nil {
 			for i, indexer := range idxCtx.Indexers {
 				tag := idxCtx.tags[i]
 				if data, ok := spend.Data[tag]; ok {
-					if data.Data, err = indexer.FromBytes(data.Data.(json.RawMessage)); err != nil {
-						log.Panic(err)
-						return err
+					if raw, ok := data.Data.(json.RawMessage); !ok {
+						return NewIngestError(idxCtx.TxidHex, ErrMalformedData, fmt.Errorf("%s %s: unexpected data type %T", op, tag, data.Data))
+					} else if data.Data, err = indexer.FromBytes(raw); err != nil {
+						return NewIngestError(idxCtx.TxidHex, ErrMalformedData, fmt.Errorf("%s %s: %w", op, tag, err))
 					}
 				}
 			}
 			idxCtx.Spends = append(idxCtx.Spends, spend)
 		} else if idxCtx.ancestorConfig.Load || idxCtx.ancestorConfig.Parse {
 			parentTxid := outpoint.TxidHex()
-			if tx, err := jb.LoadTx(idxCtx.Ctx, parentTxid, true); err != nil {
-				return err
+			if tx, err := jb.LoadTxFrom(idxCtx.Ctx, idxCtx.Source, parentTxid, true); err != nil {
+				return NewIngestError(idxCtx.TxidHex, ErrMissingInput, fmt.Errorf("%s: %w", op, err))
+			} else if tx == nil || int(outpoint.Vout()) >= len(tx.Outputs) {
+				return NewIngestError(idxCtx.TxidHex, ErrMissingInput, fmt.Errorf("%s: %w", op, jb.ErrNotFound))
 			} else if idxCtx.ancestorConfig.Parse {
 				spendCtx := NewIndexContext(idxCtx.Ctx, idxCtx.Store, tx, idxCtx.Indexers, AncestorConfig{}, idxCtx.Network)
-				spendCtx.ParseTxos()
+				spendCtx.Source = idxCtx.Source
+				if err := spendCtx.ParseTxos(); err != nil {
+					return err
+				}
 				idxCtx.Spends = append(idxCtx.Spends, spendCtx.Txos[outpoint.Vout()])
 				if idxCtx.ancestorConfig.Save {
 					if err := spendCtx.Store.SaveTxos(spendCtx); err != nil {
-						log.Panic(err)
-						return err
+						return NewIngestError(idxCtx.TxidHex, ErrStoreUnavailable, err)
 					}
 				}
 			} else {
@@ -156,8 +165,11 @@ func (idxCtx *IndexContext) ParseTxos() (err error) {
 		}
 		idxCtx.Txos = append(idxCtx.Txos, txo)
 		accSats += txout.Satoshis
-		for _, indexer := range idxCtx.Indexers {
-			if data := indexer.Parse(idxCtx, uint32(vout)); data != nil {
+		for i, indexer := range idxCtx.Indexers {
+			start := time.Now()
+			data := indexer.Parse(idxCtx, uint32(vout))
+			metrics.ParseDuration.WithLabelValues(idxCtx.tags[i]).Observe(time.Since(start).Seconds())
+			if data != nil {
 				txo.Data[indexer.Tag()] = data
 			}
 		}
@@ -168,14 +180,9 @@ func (idxCtx *IndexContext) ParseTxos() (err error) {
 	return nil
 }
 
-func (idxCtx *IndexContext) Save() error {
-	if err := idxCtx.Store.SaveTxos(idxCtx); err != nil {
-		log.Panic(err)
-		return err
-	} else if err := idxCtx.Store.SaveSpends(idxCtx); err != nil {
-		log.Panic(err)
-		return err
+func (idxCtx *IndexContext) Save(logKeys ...string) error {
+	if err := idxCtx.Store.Commit(idxCtx, logKeys...); err != nil {
+		return NewIngestError(idxCtx.TxidHex, ErrStoreUnavailable, err)
 	}
-
 	return nil
 }
@@ -2,7 +2,7 @@ package idx
 
 import (
 	"encoding/json"
-	"log"
+	"fmt"
 )
 
 type Indexer interface {
@@ -32,8 +32,7 @@ func (b BaseIndexer) FromBytes(data []byte) (any, error) {
 	}
 	obj := make(map[string]any)
 	if err := json.Unmarshal(data, &obj); err != nil {
-		log.Panicf("Error unmarshalling: %s %v", b.Tag(), err)
-		return nil, err
+		return nil, fmt.Errorf("%w: %v", ErrMalformedData, err)
 	}
 	return obj, nil
 }
@@ -9,6 +9,7 @@ import (
 	"github.com/bsv-blockchain/go-sdk/transaction"
 	"github.com/shruggr/1sat-indexer/v5/jb"
 	"github.com/shruggr/1sat-indexer/v5/lib"
+	"github.com/shruggr/1sat-indexer/v5/metrics"
 )
 
 const PendingTxLog = "tx"
@@ -29,6 +30,15 @@ type IngestCtx struct {
 	Once           bool
 	Store          TxoStore
 	AncestorConfig AncestorConfig
+	Source         jb.TxSource
+	RetryPolicy    *RetryPolicy
+}
+
+func (cfg *IngestCtx) TxSource() jb.TxSource {
+	if cfg.Source != nil {
+		return cfg.Source
+	}
+	return jb.Source
 }
 
 func (cfg *IngestCtx) IndexedTags() []string {
@@ -40,8 +50,10 @@ func (cfg *IngestCtx) IndexedTags() []string {
 }
 
 func (cfg *IngestCtx) ParseTxid(ctx context.Context, txid string, ancestorCfg AncestorConfig) (*IndexContext, error) {
-	if tx, err := jb.LoadTx(ctx, txid, true); err != nil {
+	if tx, err := jb.LoadTxFrom(ctx, cfg.TxSource(), txid, true); err != nil {
 		return nil, err
+	} else if tx == nil {
+		return nil, fmt.Errorf("missing-txn %s: %w", txid, jb.ErrNotFound)
 	} else {
 		return cfg.ParseTx(ctx, tx, ancestorCfg)
 	}
@@ -49,6 +61,7 @@ func (cfg *IngestCtx) ParseTxid(ctx context.Context, txid string, ancestorCfg An
 
 func (cfg *IngestCtx) ParseTx(ctx context.Context, tx *transaction.Transaction, ancestorCfg AncestorConfig) (idxCtx *IndexContext, err error) {
 	idxCtx = NewIndexContext(ctx, cfg.Store, tx, cfg.Indexers, ancestorCfg, cfg.Network)
+	idxCtx.Source = cfg.TxSource()
 	err = idxCtx.ParseTxn()
 	return
 }
@@ -56,18 +69,17 @@ func (cfg *IngestCtx) ParseTx(ctx context.Context, tx *transaction.Transaction,
 func (cfg *IngestCtx) IngestTxid(ctx context.Context, txid string, ancestorCfg AncestorConfig) (*IndexContext, error) {
 	if cfg.Once {
 		if score, err := cfg.Store.LogScore(ctx, LogKey(cfg.Tag), txid); err != nil {
-			log.Panic(err)
-			return nil, err
+			return nil, NewIngestError(txid, ErrStoreUnavailable, err)
 		} else if score > 0 {
 			log.Println("[INGEST] Skipping", txid)
 			return nil, nil
 		}
 	}
-	if tx, err := jb.LoadTx(ctx, txid, true); err != nil {
+	if tx, err := jb.LoadTxFrom(ctx, cfg.TxSource(), txid, true); err != nil {
 		log.Println("LoadTx error", txid, err)
 		return nil, err
 	} else if tx == nil {
-		return nil, fmt.Errorf("missing-txn %s", txid)
+		return nil, fmt.Errorf("missing-txn %s: %w", txid, jb.ErrNotFound)
 	} else {
 		return cfg.IngestTx(ctx, tx, ancestorCfg)
 	}
@@ -75,6 +87,14 @@ func (cfg *IngestCtx) IngestTxid(ctx context.Context, txid string, ancestorCfg A
 
 func (cfg *IngestCtx) IngestTx(ctx context.Context, tx *transaction.Transaction, ancestorCfg AncestorConfig) (idxCtx *IndexContext, err error) {
 	start := time.Now()
+	defer func() {
+		if err != nil {
+			metrics.IngestErrors.WithLabelValues(cfg.Tag, ErrorKind(err)).Inc()
+		} else {
+			metrics.TxsIngested.WithLabelValues(cfg.Tag).Inc()
+			metrics.IngestDuration.WithLabelValues(cfg.Tag).Observe(time.Since(start).Seconds())
+		}
+	}()
 	if idxCtx, err = cfg.ParseTx(ctx, tx, ancestorCfg); err != nil {
 		return nil, err
 	}
@@ -87,17 +107,18 @@ func (cfg *IngestCtx) IngestTx(ctx context.Context, tx *transaction.Transaction,
 }
 
 func (cfg *IngestCtx) Save(ctx context.Context, idxCtx *IndexContext) (err error) {
-	idxCtx.Save()
-	if err = cfg.Store.Log(ctx, PendingTxLog, idxCtx.TxidHex, idxCtx.Score); err != nil {
-		log.Panic(err)
-		return
-	} else if len(cfg.Tag) > 0 {
-		if err = cfg.Store.Log(ctx, LogKey(cfg.Tag), idxCtx.TxidHex, idxCtx.Score); err != nil {
-			log.Panic(err)
-			return
+	logKeys := []string{PendingTxLog}
+	if len(cfg.Tag) > 0 {
+		logKeys = append(logKeys, LogKey(cfg.Tag))
+	}
+	if idxCtx.Tx.MerklePath != nil {
+		if root, err := idxCtx.Tx.MerklePath.ComputeRoot(idxCtx.Txid); err != nil {
+			return NewIngestError(idxCtx.TxidHex, ErrMalformedData, err)
+		} else if err := cfg.Store.Log(ctx, BlockRootKey, root.String(), float64(idxCtx.Height)); err != nil {
+			return NewIngestError(idxCtx.TxidHex, ErrStoreUnavailable, err)
 		}
 	}
-	return
+	return idxCtx.Save(logKeys...)
 }
 
 // func (cfg *IngestCtx) Rollback(ctx context.Context, txid string) error {
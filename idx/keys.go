@@ -1,5 +1,7 @@
 package idx
 
+import "strings"
+
 func AccountKey(account string) string {
 	return "acct:" + account
 }
@@ -16,6 +18,9 @@ func BalanceKey(key string) string {
 	return "bal:" + key
 }
 
+// BlockRootKey logs the merkle root of each block with indexed transactions, scored by height
+const BlockRootKey = "blk:root"
+
 const OwnerSyncKey = "own:sync"
 const OwnerAccountKey = "own:acct"
 
@@ -26,6 +31,35 @@ func QueueKey(tag string) string {
 const IngestTag = "ingest"
 const IngestQueueKey = "que:ingest"
 
+func DeadLetterKey(tag string) string {
+	return "dlq:" + tag
+}
+
+func AttemptsKey(tag string) string {
+	return "att:" + tag
+}
+
 func LogKey(tag string) string {
 	return "log:" + tag
 }
+
+// DepsKey logs the outpoints which the tag data of outpoint depends on
+func DepsKey(tag string, outpoint string) string {
+	return "dep:" + tag + ":" + outpoint
+}
+
+// DependentsKey logs the outpoints whose tag data depends on outpoint.
+// Stores save it with the events of the dependent txo so it shares their lifecycle.
+func DependentsKey(tag string, outpoint string) string {
+	return "dpt:" + tag + ":" + outpoint
+}
+
+// ParseDependentsKey returns the tag and dependency outpoint of a DependentsKey
+func ParseDependentsKey(key string) (tag string, outpoint string, ok bool) {
+	if rest, found := strings.CutPrefix(key, "dpt:"); !found {
+		return "", "", false
+	} else if tag, outpoint, ok = strings.Cut(rest, ":"); !ok {
+		return "", "", false
+	}
+	return
+}
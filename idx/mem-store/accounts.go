@@ -0,0 +1,53 @@
+package memstore
+
+import (
+	"context"
+	"slices"
+
+	"github.com/shruggr/1sat-indexer/v5/idx"
+)
+
+func (m *MemStore) AcctsByOwners(ctx context.Context, owners []string) ([]string, error) {
+	if len(owners) == 0 {
+		return nil, nil
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	accts := make([]string, 0, len(owners))
+	for _, owner := range owners {
+		if acct, ok := m.ownerAccts[owner]; ok && !slices.Contains(accts, acct) {
+			accts = append(accts, acct)
+		}
+	}
+	return accts, nil
+}
+
+func (m *MemStore) AcctOwners(ctx context.Context, account string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	owners := make([]string, 0, len(m.accounts[account]))
+	for owner := range m.accounts[account] {
+		owners = append(owners, owner)
+	}
+	slices.Sort(owners)
+	return owners, nil
+}
+
+func (m *MemStore) UpdateAccount(ctx context.Context, account string, owners []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.accounts[account]; !ok {
+		m.accounts[account] = make(map[string]struct{})
+	}
+	for _, owner := range owners {
+		if owner == "" {
+			continue
+		}
+		if _, ok := m.logs[idx.OwnerSyncKey][owner]; !ok {
+			m.log(idx.OwnerSyncKey, owner, 0)
+		}
+		m.accounts[account][owner] = struct{}{}
+		m.ownerAccts[owner] = account
+	}
+	return nil
+}
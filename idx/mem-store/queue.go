@@ -0,0 +1,64 @@
+package memstore
+
+import (
+	"context"
+
+	"github.com/shruggr/1sat-indexer/v5/idx"
+)
+
+func (m *MemStore) log(key string, member string, score float64) {
+	if _, ok := m.logs[key]; !ok {
+		m.logs[key] = make(map[string]float64)
+	}
+	m.logs[key][member] = score
+}
+
+func (m *MemStore) delog(key string, member string) {
+	if set, ok := m.logs[key]; ok {
+		delete(set, member)
+		if len(set) == 0 {
+			delete(m.logs, key)
+		}
+	}
+}
+
+func (m *MemStore) Delog(ctx context.Context, key string, members ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, member := range members {
+		m.delog(key, member)
+	}
+	return nil
+}
+
+func (m *MemStore) Log(ctx context.Context, key string, member string, score float64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.log(key, member, score)
+	return nil
+}
+
+func (m *MemStore) LogMany(ctx context.Context, key string, logs []idx.Log) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, l := range logs {
+		m.log(key, l.Member, l.Score)
+	}
+	return nil
+}
+
+func (m *MemStore) LogOnce(ctx context.Context, key string, member string, score float64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.logs[key][member]; ok {
+		return false, nil
+	}
+	m.log(key, member, score)
+	return true, nil
+}
+
+func (m *MemStore) LogScore(ctx context.Context, key string, member string) (float64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.logs[key][member], nil
+}
@@ -0,0 +1,233 @@
+package memstore
+
+import (
+	"context"
+	"slices"
+
+	"github.com/shruggr/1sat-indexer/v5/idx"
+	"github.com/shruggr/1sat-indexer/v5/lib"
+)
+
+func inRange(from *float64, to *float64, reverse bool, score float64) bool {
+	if reverse {
+		from, to = to, from
+	}
+	if from != nil && score <= *from {
+		return false
+	} else if to != nil && score >= *to {
+		return false
+	}
+	return true
+}
+
+type record struct {
+	count int
+	score float64
+}
+
+func (m *MemStore) Search(ctx context.Context, cfg *idx.SearchCfg) (records []*idx.Log, err error) {
+	from, to := cfg.Range()
+	m.mu.RLock()
+	memberSet := make(map[string]*record)
+	for _, key := range cfg.Keys {
+		for member, score := range m.logs[key] {
+			if !inRange(from, to, cfg.Reverse, score) {
+				continue
+			} else if len(member) < 65 && (cfg.OutpointsOnly || cfg.FilterSpent) {
+				continue
+			}
+			if r, ok := memberSet[member]; !ok {
+				memberSet[member] = &record{
+					score: score,
+					count: 1,
+				}
+			} else {
+				r.count++
+				if score < r.score {
+					r.score = score
+				}
+			}
+		}
+	}
+	records = make([]*idx.Log, 0, len(memberSet))
+	for member, r := range memberSet {
+		if cfg.ComparisonType == idx.ComparisonAND && r.count != len(cfg.Keys) {
+			continue
+		} else if _, spent := m.spends[member]; cfg.FilterSpent && spent {
+			continue
+		}
+		records = append(records, &idx.Log{
+			Member: member,
+			Score:  r.score,
+		})
+	}
+	m.mu.RUnlock()
+
+	slices.SortFunc(records, func(a, b *idx.Log) int {
+		if a.Score < b.Score {
+			return -1
+		} else if a.Score > b.Score {
+			return 1
+		} else if a.Member < b.Member {
+			return -1
+		} else if a.Member > b.Member {
+			return 1
+		}
+		return 0
+	})
+	if cfg.Reverse {
+		slices.Reverse(records)
+	}
+
+	if cfg.FilterSpent && cfg.RefreshSpends {
+		unspent := make([]*idx.Log, 0, len(records))
+		for _, record := range records {
+			if cfg.Limit > 0 && len(unspent) >= int(cfg.Limit) {
+				break
+			} else if spend, err := m.refreshSpend(ctx, record.Member); err != nil {
+				return nil, err
+			} else if spend == "" {
+				unspent = append(unspent, record)
+			}
+		}
+		records = unspent
+	}
+
+	if cfg.Limit > 0 && len(records) > int(cfg.Limit) {
+		records = records[:cfg.Limit]
+	}
+	return records, nil
+}
+
+func (m *MemStore) SearchMembers(ctx context.Context, cfg *idx.SearchCfg) (results []string, err error) {
+	if items, err := m.Search(ctx, cfg); err != nil {
+		return nil, err
+	} else {
+		members := make([]string, 0, len(items))
+		for _, item := range items {
+			members = append(members, item.Member)
+		}
+		return members, nil
+	}
+}
+
+func (m *MemStore) SearchOutpoints(ctx context.Context, cfg *idx.SearchCfg) (results []string, err error) {
+	cfg.OutpointsOnly = true
+	return m.SearchMembers(ctx, cfg)
+}
+
+func (m *MemStore) SearchTxos(ctx context.Context, cfg *idx.SearchCfg) (txos []*idx.Txo, err error) {
+	results, err := m.Search(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	outpoints := make([]string, 0, len(results))
+	for _, result := range results {
+		outpoints = append(outpoints, result.Member)
+	}
+	if cfg.IncludeTxo {
+		if txos, err = m.LoadTxos(ctx, outpoints, cfg.IncludeTags, cfg.IncludeScript, cfg.IncludeSpend); err != nil {
+			return nil, err
+		}
+		return txos, nil
+	}
+
+	txos = make([]*idx.Txo, 0, len(results))
+	for _, result := range results {
+		txo := &idx.Txo{
+			Height: uint32(result.Score / 1000000000),
+			Idx:    uint64(result.Score) % 1000000000,
+			Score:  result.Score,
+		}
+		if txo.Outpoint, err = lib.NewOutpointFromString(result.Member); err != nil {
+			return nil, err
+		} else if txo.Data, err = m.LoadData(ctx, result.Member, cfg.IncludeTags); err != nil {
+			return nil, err
+		} else if txo.Data == nil {
+			txo.Data = make(idx.IndexDataMap)
+		}
+		if cfg.IncludeSpend {
+			if txo.Spend, err = m.GetSpend(ctx, result.Member, cfg.RefreshSpends); err != nil {
+				return nil, err
+			}
+		}
+		if cfg.IncludeScript {
+			if stored, _ := m.LoadTxo(ctx, result.Member, nil, true, false); stored != nil {
+				txo.Script = stored.Script
+			}
+		}
+		txos = append(txos, txo)
+	}
+	return txos, nil
+}
+
+func (m *MemStore) SearchBalance(ctx context.Context, cfg *idx.SearchCfg) (balance uint64, err error) {
+	cfg.OutpointsOnly = true
+	cfg.FilterSpent = true
+	if outpoints, err := m.SearchMembers(ctx, cfg); err != nil {
+		return 0, err
+	} else if txos, err := m.LoadTxos(ctx, outpoints, nil, false, false); err != nil {
+		return 0, err
+	} else {
+		for _, txo := range txos {
+			if txo != nil && txo.Satoshis != nil {
+				balance += *txo.Satoshis
+			}
+		}
+	}
+	return
+}
+
+func (m *MemStore) SearchTxns(ctx context.Context, cfg *idx.SearchCfg) (txns []*lib.TxResult, err error) {
+	txMap := make(map[float64]*lib.TxResult)
+	scores := make([]float64, 0, 1000)
+	if activity, err := m.Search(ctx, cfg); err != nil {
+		return nil, err
+	} else {
+		for _, item := range activity {
+			var txid string
+			var out *uint32
+			if len(item.Member) == 64 {
+				txid = item.Member
+			} else if outpoint, err := lib.NewOutpointFromString(item.Member); err != nil {
+				return nil, err
+			} else {
+				txid = outpoint.TxidHex()
+				vout := outpoint.Vout()
+				out = &vout
+			}
+			result, ok := txMap[item.Score]
+			if !ok {
+				result = &lib.TxResult{
+					Txid:    txid,
+					Height:  uint32(item.Score / 1000000000),
+					Idx:     uint64(item.Score) % 1000000000,
+					Outputs: lib.NewOutputMap(),
+					Score:   item.Score,
+				}
+				if cfg.IncludeRawtx {
+					if result.Rawtx, err = m.source().LoadRawtx(ctx, txid); err != nil {
+						return nil, err
+					}
+				}
+				txMap[item.Score] = result
+				scores = append(scores, item.Score)
+			}
+			if out != nil {
+				result.Outputs[*out] = struct{}{}
+			}
+		}
+	}
+	slices.Sort(scores)
+	results := make([]*lib.TxResult, 0, len(scores))
+	for _, score := range scores {
+		results = append(results, txMap[score])
+	}
+	return results, nil
+}
+
+func (m *MemStore) CountMembers(ctx context.Context, key string) (uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return uint64(len(m.logs[key])), nil
+}
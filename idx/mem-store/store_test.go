@@ -0,0 +1,15 @@
+package memstore_test
+
+import (
+	"testing"
+
+	"github.com/shruggr/1sat-indexer/v5/idx"
+	memstore "github.com/shruggr/1sat-indexer/v5/idx/mem-store"
+	"github.com/shruggr/1sat-indexer/v5/idxtest"
+)
+
+func TestMemStore(t *testing.T) {
+	idxtest.TestStore(t, func(t *testing.T) idx.TxoStore {
+		return memstore.NewMemStore()
+	})
+}
@@ -0,0 +1,404 @@
+package memstore
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/shruggr/1sat-indexer/v5/evt"
+	"github.com/shruggr/1sat-indexer/v5/idx"
+	"github.com/shruggr/1sat-indexer/v5/jb"
+)
+
+// MemStore is a TxoStore held entirely in memory. It is intended for tests,
+// dry runs and embedding the indexer where an external database is not available.
+type MemStore struct {
+	// Source is consulted for spends when a refresh is requested. Defaults to jb.Source.
+	Source     jb.TxSource
+	mu         sync.RWMutex
+	txos       map[string]*idx.Txo
+	data       map[string]map[string]json.RawMessage
+	spends     map[string]string
+	inputs     map[string][]string
+	logs       map[string]map[string]float64
+	accounts   map[string]map[string]struct{}
+	ownerAccts map[string]string
+}
+
+func NewMemStore() *MemStore {
+	return &MemStore{
+		txos:       make(map[string]*idx.Txo),
+		data:       make(map[string]map[string]json.RawMessage),
+		spends:     make(map[string]string),
+		inputs:     make(map[string][]string),
+		logs:       make(map[string]map[string]float64),
+		accounts:   make(map[string]map[string]struct{}),
+		ownerAccts: make(map[string]string),
+	}
+}
+
+func (m *MemStore) loadTxo(outpoint string, tags []string, script bool, spend bool) *idx.Txo {
+	stored, ok := m.txos[outpoint]
+	if !ok {
+		return nil
+	}
+	txo := *stored
+	txo.Score = idx.HeightScore(txo.Height, txo.Idx)
+	txo.Data = m.loadData(outpoint, tags)
+	if txo.Data == nil {
+		txo.Data = make(idx.IndexDataMap)
+	}
+	if !script {
+		txo.Script = nil
+	}
+	if spend {
+		txo.Spend = m.spends[outpoint]
+	}
+	return &txo
+}
+
+func (m *MemStore) loadData(outpoint string, tags []string) idx.IndexDataMap {
+	if len(tags) == 0 {
+		return nil
+	}
+	data := make(idx.IndexDataMap, len(tags))
+	for _, tag := range tags {
+		if raw, ok := m.data[outpoint][tag]; ok {
+			data[tag] = &idx.IndexData{
+				Data: raw,
+			}
+		}
+	}
+	return data
+}
+
+func (m *MemStore) LoadTxo(ctx context.Context, outpoint string, tags []string, script bool, spend bool) (*idx.Txo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.loadTxo(outpoint, tags, script, spend), nil
+}
+
+func (m *MemStore) LoadTxos(ctx context.Context, outpoints []string, tags []string, script bool, spend bool) ([]*idx.Txo, error) {
+	if len(outpoints) == 0 {
+		return nil, nil
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	txos := make([]*idx.Txo, 0, len(outpoints))
+	for _, outpoint := range outpoints {
+		txos = append(txos, m.loadTxo(outpoint, tags, script, spend))
+	}
+	return txos, nil
+}
+
+func (m *MemStore) LoadTxosByTxid(ctx context.Context, txid string, tags []string, script bool, spend bool) ([]*idx.Txo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.txosByTxid(txid, tags, script, spend), nil
+}
+
+func (m *MemStore) txosByTxid(txid string, tags []string, script bool, spend bool) []*idx.Txo {
+	txos := make([]*idx.Txo, 0)
+	for outpoint := range m.txos {
+		if strings.HasPrefix(outpoint, txid+"_") {
+			txos = append(txos, m.loadTxo(outpoint, tags, script, spend))
+		}
+	}
+	slices.SortFunc(txos, func(a, b *idx.Txo) int {
+		return int(a.Outpoint.Vout()) - int(b.Outpoint.Vout())
+	})
+	return txos
+}
+
+func (m *MemStore) LoadData(ctx context.Context, outpoint string, tags []string) (idx.IndexDataMap, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.loadData(outpoint, tags), nil
+}
+
+func (m *MemStore) SaveTxos(idxCtx *idx.IndexContext) (err error) {
+	m.mu.Lock()
+	outpoints, err := m.saveTxos(idxCtx)
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	publishTxos(idxCtx, outpoints)
+	return nil
+}
+
+func (m *MemStore) SaveSpends(idxCtx *idx.IndexContext) error {
+	m.mu.Lock()
+	ownerKeys := m.saveSpends(idxCtx)
+	m.mu.Unlock()
+	publishSpends(idxCtx, ownerKeys)
+	return nil
+}
+
+func (m *MemStore) Commit(idxCtx *idx.IndexContext, logKeys ...string) error {
+	m.mu.Lock()
+	outpoints, err := m.saveTxos(idxCtx)
+	if err != nil {
+		m.mu.Unlock()
+		return err
+	}
+	ownerKeys := m.saveSpends(idxCtx)
+	m.saveIncrs(idxCtx)
+	m.saveWrites(idxCtx.TxidHex, idxCtx.Writes)
+	for _, key := range logKeys {
+		m.log(key, idxCtx.TxidHex, idxCtx.Score)
+	}
+	m.mu.Unlock()
+
+	publishTxos(idxCtx, outpoints)
+	publishSpends(idxCtx, ownerKeys)
+	return nil
+}
+
+// saveTxos stores the txos of idxCtx. The caller must hold the write lock.
+func (m *MemStore) saveTxos(idxCtx *idx.IndexContext) (outpoints []string, err error) {
+	score := idx.HeightScore(idxCtx.Height, idxCtx.Idx)
+	outpoints = make([]string, 0, len(idxCtx.Txos))
+	stored := make([]*idx.Txo, 0, len(idxCtx.Txos))
+	datas := make([]map[string]json.RawMessage, 0, len(idxCtx.Txos))
+	for vout, txo := range idxCtx.Txos {
+		outpoints = append(outpoints, txo.Outpoint.String())
+
+		txo.Events = make([]string, 0, 100)
+		data := make(map[string]json.RawMessage, len(txo.Data))
+		for tag, d := range txo.Data {
+			if d == nil {
+				continue
+			}
+			txo.Events = append(txo.Events, evt.TagKey(tag))
+			for _, event := range d.Events {
+				txo.Events = append(txo.Events, evt.EventKey(tag, event))
+			}
+			for _, dep := range d.Deps {
+				txo.Events = append(txo.Events, idx.DependentsKey(tag, dep.String()))
+			}
+			if data[tag], err = d.MarshalJSON(); err != nil {
+				return nil, err
+			}
+		}
+		for _, owner := range txo.Owners {
+			if owner == "" {
+				continue
+			}
+			txo.Events = append(txo.Events, idx.OwnerKey(owner))
+		}
+
+		s := *txo
+		s.Height = idxCtx.Height
+		s.Idx = idxCtx.Idx
+		s.Owners = slices.Clone(txo.Owners)
+		s.Events = slices.Clone(txo.Events)
+		s.Data = nil
+		s.Score = 0
+		s.Spend = ""
+		if idxCtx.Tx != nil && vout < len(idxCtx.Tx.Outputs) {
+			s.Script = *idxCtx.Tx.Outputs[vout].LockingScript
+		}
+		stored = append(stored, &s)
+		datas = append(datas, data)
+	}
+
+	// Nothing is written until every txo has been marshalled so a failure leaves the store untouched
+	for i, outpoint := range outpoints {
+		m.txos[outpoint] = stored[i]
+		m.data[outpoint] = datas[i]
+		for _, event := range stored[i].Events {
+			m.log(event, outpoint, score)
+			if tag, dep, ok := idx.ParseDependentsKey(event); ok {
+				m.log(idx.DepsKey(tag, outpoint), dep, score)
+			}
+		}
+	}
+	return outpoints, nil
+}
+
+// saveSpends marks the inputs of idxCtx as spent. The caller must hold the write lock.
+func (m *MemStore) saveSpends(idxCtx *idx.IndexContext) (ownerKeys []string) {
+	score := idx.HeightScore(idxCtx.Height, idxCtx.Idx)
+	ownerKeys = make([]string, 0, 10)
+	inputs := make([]string, 0, len(idxCtx.Spends))
+	for _, spend := range idxCtx.Spends {
+		outpoint := spend.Outpoint.String()
+		m.spends[outpoint] = idxCtx.TxidHex
+		inputs = append(inputs, outpoint)
+		for _, owner := range spend.Owners {
+			if owner == "" {
+				continue
+			}
+			ownerKey := idx.OwnerKey(owner)
+			if !slices.Contains(ownerKeys, ownerKey) {
+				ownerKeys = append(ownerKeys, ownerKey)
+				m.log(ownerKey, idxCtx.TxidHex, score)
+			}
+		}
+	}
+	m.inputs[idxCtx.TxidHex] = inputs
+	return ownerKeys
+}
+
+// saveIncrs applies the increments of idxCtx in place of any journaled by a
+// previous commit of the transaction. The caller must hold the write lock.
+func (m *MemStore) saveIncrs(idxCtx *idx.IndexContext) {
+	journal := idx.IncrKey(idxCtx.TxidHex)
+	m.applyIncrs(idx.NetIncrs(m.journal(journal), idxCtx.Incrs))
+	delete(m.logs, journal)
+	for _, incr := range idx.MergeIncrs(idxCtx.Incrs) {
+		m.log(journal, incr.JournalMember(), incr.Delta)
+	}
+}
+
+// saveWrites applies writes in place of any journaled by a previous commit of
+// txid. The caller must hold the write lock.
+func (m *MemStore) saveWrites(txid string, writes []*idx.Write) {
+	journal := idx.WriteKey(txid)
+	apply, entries, _ := idx.PlanWrites(m.journal(journal), writes, func(key string, member string) (*float64, error) {
+		if score, ok := m.logs[key][member]; ok {
+			return &score, nil
+		}
+		return nil, nil
+	})
+	for _, w := range apply {
+		if w.Delete {
+			m.delog(w.Key, w.Member)
+		} else {
+			m.log(w.Key, w.Member, w.Score)
+		}
+	}
+	delete(m.logs, journal)
+	for _, entry := range entries {
+		m.log(journal, entry.Member, entry.Score)
+	}
+}
+
+func (m *MemStore) journal(key string) []*idx.Log {
+	logs := make([]*idx.Log, 0, len(m.logs[key]))
+	for member, score := range m.logs[key] {
+		logs = append(logs, &idx.Log{Member: member, Score: score})
+	}
+	return logs
+}
+
+func (m *MemStore) applyIncrs(incrs []*idx.Incr) {
+	for _, incr := range incrs {
+		if score := m.logs[incr.Key][incr.Member] + incr.Delta; score > 0 {
+			m.log(incr.Key, incr.Member, score)
+		} else {
+			m.delog(incr.Key, incr.Member)
+		}
+	}
+}
+
+func publishTxos(idxCtx *idx.IndexContext, outpoints []string) {
+	for vout, txo := range idxCtx.Txos {
+		for _, event := range txo.Events {
+			evt.Publish(idxCtx.Ctx, event, outpoints[vout])
+		}
+	}
+}
+
+func publishSpends(idxCtx *idx.IndexContext, ownerKeys []string) {
+	for _, ownerKey := range ownerKeys {
+		evt.Publish(idxCtx.Ctx, ownerKey, idxCtx.TxidHex)
+	}
+}
+
+func (m *MemStore) source() jb.TxSource {
+	if m.Source != nil {
+		return m.Source
+	}
+	return jb.Source
+}
+
+func (m *MemStore) refreshSpend(ctx context.Context, outpoint string) (spend string, err error) {
+	if spend, err = jb.GetSpendFrom(ctx, m.source(), outpoint); err != nil {
+		return "", err
+	} else if spend != "" {
+		if _, err = m.SetNewSpend(ctx, outpoint, spend); err != nil {
+			return "", err
+		}
+	}
+	return spend, nil
+}
+
+func (m *MemStore) GetSpend(ctx context.Context, outpoint string, refresh bool) (string, error) {
+	m.mu.RLock()
+	spend := m.spends[outpoint]
+	m.mu.RUnlock()
+	if spend == "" && refresh {
+		return m.refreshSpend(ctx, outpoint)
+	}
+	return spend, nil
+}
+
+func (m *MemStore) GetSpends(ctx context.Context, outpoints []string, refresh bool) ([]string, error) {
+	spends := make([]string, 0, len(outpoints))
+	for _, outpoint := range outpoints {
+		if spend, err := m.GetSpend(ctx, outpoint, refresh); err != nil {
+			return nil, err
+		} else {
+			spends = append(spends, spend)
+		}
+	}
+	return spends, nil
+}
+
+func (m *MemStore) SetNewSpend(ctx context.Context, outpoint, txid string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.spends[outpoint]; ok {
+		return false, nil
+	}
+	m.spends[outpoint] = txid
+	return true, nil
+}
+
+func (m *MemStore) UnsetSpends(ctx context.Context, outpoints []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, outpoint := range outpoints {
+		delete(m.spends, outpoint)
+	}
+	return nil
+}
+
+func (m *MemStore) Rollback(ctx context.Context, txid string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, outpoint := range m.inputs[txid] {
+		if m.spends[outpoint] != txid {
+			continue
+		}
+		delete(m.spends, outpoint)
+		if spend, ok := m.txos[outpoint]; ok {
+			for _, owner := range spend.Owners {
+				if owner != "" {
+					m.delog(idx.OwnerKey(owner), txid)
+				}
+			}
+		}
+	}
+	delete(m.inputs, txid)
+	m.applyIncrs(idx.NetIncrs(m.journal(idx.IncrKey(txid)), nil))
+	delete(m.logs, idx.IncrKey(txid))
+	m.saveWrites(txid, nil)
+
+	for _, txo := range m.txosByTxid(txid, nil, false, false) {
+		outpoint := txo.Outpoint.String()
+		for _, event := range txo.Events {
+			m.delog(event, outpoint)
+			if tag, dep, ok := idx.ParseDependentsKey(event); ok {
+				m.delog(idx.DepsKey(tag, outpoint), dep)
+			}
+		}
+		delete(m.txos, outpoint)
+		delete(m.data, outpoint)
+	}
+	return nil
+}
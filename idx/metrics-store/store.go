@@ -0,0 +1,154 @@
+package metricsstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/shruggr/1sat-indexer/v5/idx"
+	"github.com/shruggr/1sat-indexer/v5/lib"
+	"github.com/shruggr/1sat-indexer/v5/metrics"
+)
+
+// MetricsStore wraps a TxoStore, recording the latency and errors of every operation
+type MetricsStore struct {
+	Store idx.TxoStore
+}
+
+func NewMetricsStore(store idx.TxoStore) *MetricsStore {
+	return &MetricsStore{Store: store}
+}
+
+func (m *MetricsStore) AcctsByOwners(ctx context.Context, owners []string) (result []string, err error) {
+	defer func(start time.Time) { metrics.ObserveStore("accts_by_owners", start, err) }(time.Now())
+	return m.Store.AcctsByOwners(ctx, owners)
+}
+
+func (m *MetricsStore) AcctOwners(ctx context.Context, acct string) (result []string, err error) {
+	defer func(start time.Time) { metrics.ObserveStore("acct_owners", start, err) }(time.Now())
+	return m.Store.AcctOwners(ctx, acct)
+}
+
+func (m *MetricsStore) UpdateAccount(ctx context.Context, account string, owners []string) (err error) {
+	defer func(start time.Time) { metrics.ObserveStore("update_account", start, err) }(time.Now())
+	return m.Store.UpdateAccount(ctx, account, owners)
+}
+
+func (m *MetricsStore) LoadTxo(ctx context.Context, outpoint string, tags []string, script bool, spend bool) (result *idx.Txo, err error) {
+	defer func(start time.Time) { metrics.ObserveStore("load_txo", start, err) }(time.Now())
+	return m.Store.LoadTxo(ctx, outpoint, tags, script, spend)
+}
+
+func (m *MetricsStore) LoadTxos(ctx context.Context, outpoints []string, tags []string, script bool, spend bool) (result []*idx.Txo, err error) {
+	defer func(start time.Time) { metrics.ObserveStore("load_txos", start, err) }(time.Now())
+	return m.Store.LoadTxos(ctx, outpoints, tags, script, spend)
+}
+
+func (m *MetricsStore) LoadTxosByTxid(ctx context.Context, txid string, tags []string, script bool, spend bool) (result []*idx.Txo, err error) {
+	defer func(start time.Time) { metrics.ObserveStore("load_txos_by_txid", start, err) }(time.Now())
+	return m.Store.LoadTxosByTxid(ctx, txid, tags, script, spend)
+}
+
+func (m *MetricsStore) LoadData(ctx context.Context, outpoint string, tags []string) (result idx.IndexDataMap, err error) {
+	defer func(start time.Time) { metrics.ObserveStore("load_data", start, err) }(time.Now())
+	return m.Store.LoadData(ctx, outpoint, tags)
+}
+
+func (m *MetricsStore) SaveTxos(idxCtx *idx.IndexContext) (err error) {
+	defer func(start time.Time) { metrics.ObserveStore("save_txos", start, err) }(time.Now())
+	return m.Store.SaveTxos(idxCtx)
+}
+
+func (m *MetricsStore) SaveSpends(idxCtx *idx.IndexContext) (err error) {
+	defer func(start time.Time) { metrics.ObserveStore("save_spends", start, err) }(time.Now())
+	return m.Store.SaveSpends(idxCtx)
+}
+
+func (m *MetricsStore) Commit(idxCtx *idx.IndexContext, logKeys ...string) (err error) {
+	defer func(start time.Time) { metrics.ObserveStore("commit", start, err) }(time.Now())
+	return m.Store.Commit(idxCtx, logKeys...)
+}
+
+func (m *MetricsStore) GetSpend(ctx context.Context, outpoint string, refresh bool) (result string, err error) {
+	defer func(start time.Time) { metrics.ObserveStore("get_spend", start, err) }(time.Now())
+	return m.Store.GetSpend(ctx, outpoint, refresh)
+}
+
+func (m *MetricsStore) GetSpends(ctx context.Context, outpoints []string, refresh bool) (result []string, err error) {
+	defer func(start time.Time) { metrics.ObserveStore("get_spends", start, err) }(time.Now())
+	return m.Store.GetSpends(ctx, outpoints, refresh)
+}
+
+func (m *MetricsStore) SetNewSpend(ctx context.Context, outpoint string, spend string) (result bool, err error) {
+	defer func(start time.Time) { metrics.ObserveStore("set_new_spend", start, err) }(time.Now())
+	return m.Store.SetNewSpend(ctx, outpoint, spend)
+}
+
+func (m *MetricsStore) UnsetSpends(ctx context.Context, outpoints []string) (err error) {
+	defer func(start time.Time) { metrics.ObserveStore("unset_spends", start, err) }(time.Now())
+	return m.Store.UnsetSpends(ctx, outpoints)
+}
+
+func (m *MetricsStore) Search(ctx context.Context, cfg *idx.SearchCfg) (result []*idx.Log, err error) {
+	defer func(start time.Time) { metrics.ObserveStore("search", start, err) }(time.Now())
+	return m.Store.Search(ctx, cfg)
+}
+
+func (m *MetricsStore) SearchMembers(ctx context.Context, cfg *idx.SearchCfg) (result []string, err error) {
+	defer func(start time.Time) { metrics.ObserveStore("search_members", start, err) }(time.Now())
+	return m.Store.SearchMembers(ctx, cfg)
+}
+
+func (m *MetricsStore) SearchOutpoints(ctx context.Context, cfg *idx.SearchCfg) (result []string, err error) {
+	defer func(start time.Time) { metrics.ObserveStore("search_outpoints", start, err) }(time.Now())
+	return m.Store.SearchOutpoints(ctx, cfg)
+}
+
+func (m *MetricsStore) SearchTxos(ctx context.Context, cfg *idx.SearchCfg) (result []*idx.Txo, err error) {
+	defer func(start time.Time) { metrics.ObserveStore("search_txos", start, err) }(time.Now())
+	return m.Store.SearchTxos(ctx, cfg)
+}
+
+func (m *MetricsStore) SearchTxns(ctx context.Context, cfg *idx.SearchCfg) (result []*lib.TxResult, err error) {
+	defer func(start time.Time) { metrics.ObserveStore("search_txns", start, err) }(time.Now())
+	return m.Store.SearchTxns(ctx, cfg)
+}
+
+func (m *MetricsStore) SearchBalance(ctx context.Context, cfg *idx.SearchCfg) (result uint64, err error) {
+	defer func(start time.Time) { metrics.ObserveStore("search_balance", start, err) }(time.Now())
+	return m.Store.SearchBalance(ctx, cfg)
+}
+
+func (m *MetricsStore) CountMembers(ctx context.Context, key string) (result uint64, err error) {
+	defer func(start time.Time) { metrics.ObserveStore("count_members", start, err) }(time.Now())
+	return m.Store.CountMembers(ctx, key)
+}
+
+func (m *MetricsStore) Log(ctx context.Context, key string, id string, score float64) (err error) {
+	defer func(start time.Time) { metrics.ObserveStore("log", start, err) }(time.Now())
+	return m.Store.Log(ctx, key, id, score)
+}
+
+func (m *MetricsStore) LogMany(ctx context.Context, key string, logs []idx.Log) (err error) {
+	defer func(start time.Time) { metrics.ObserveStore("log_many", start, err) }(time.Now())
+	return m.Store.LogMany(ctx, key, logs)
+}
+
+func (m *MetricsStore) LogOnce(ctx context.Context, key string, id string, score float64) (result bool, err error) {
+	defer func(start time.Time) { metrics.ObserveStore("log_once", start, err) }(time.Now())
+	return m.Store.LogOnce(ctx, key, id, score)
+}
+
+func (m *MetricsStore) Delog(ctx context.Context, key string, ids ...string) (err error) {
+	defer func(start time.Time) { metrics.ObserveStore("delog", start, err) }(time.Now())
+	return m.Store.Delog(ctx, key, ids...)
+}
+
+func (m *MetricsStore) LogScore(ctx context.Context, key string, id string) (result float64, err error) {
+	defer func(start time.Time) { metrics.ObserveStore("log_score", start, err) }(time.Now())
+	return m.Store.LogScore(ctx, key, id)
+}
+
+func (m *MetricsStore) Rollback(ctx context.Context, txid string) (err error) {
+	defer func(start time.Time) { metrics.ObserveStore("rollback", start, err) }(time.Now())
+	return m.Store.Rollback(ctx, txid)
+}
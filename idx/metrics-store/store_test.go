@@ -0,0 +1,16 @@
+package metricsstore_test
+
+import (
+	"testing"
+
+	"github.com/shruggr/1sat-indexer/v5/idx"
+	memstore "github.com/shruggr/1sat-indexer/v5/idx/mem-store"
+	metricsstore "github.com/shruggr/1sat-indexer/v5/idx/metrics-store"
+	"github.com/shruggr/1sat-indexer/v5/idxtest"
+)
+
+func TestMetricsStore(t *testing.T) {
+	idxtest.TestStore(t, func(t *testing.T) idx.TxoStore {
+		return metricsstore.NewMetricsStore(memstore.NewMemStore())
+	})
+}
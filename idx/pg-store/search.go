@@ -16,7 +16,7 @@ import (
 func (p *PGStore) Search(ctx context.Context, cfg *idx.SearchCfg) (results []*idx.Log, err error) {
 	var sqlBuilder strings.Builder
 	args := make([]interface{}, 0, 3)
-	if cfg.ComparisonType == idx.ComparisonAND && len(cfg.Keys) > 1 {
+	if len(cfg.Keys) > 1 {
 		sqlBuilder.WriteString(`SELECT logs.member, min(logs.score) as score FROM logs `)
 	} else {
 		sqlBuilder.WriteString(`SELECT logs.member, logs.score FROM logs `)
@@ -32,37 +32,43 @@ func (p *PGStore) Search(ctx context.Context, cfg *idx.SearchCfg) (results []*id
 		args = append(args, cfg.Keys)
 		sqlBuilder.WriteString(`WHERE search_key=ANY($1) `)
 	}
-	if cfg.From != nil {
-		args = append(args, *cfg.From)
+	if cfg.OutpointsOnly {
+		sqlBuilder.WriteString("AND length(logs.member) > 64 ")
+	}
+	from, to := cfg.Range()
+	if from != nil {
+		args = append(args, *from)
 		if cfg.Reverse {
-			sqlBuilder.WriteString(fmt.Sprintf("AND score < $%d ", len(args)))
+			sqlBuilder.WriteString(fmt.Sprintf("AND logs.score < $%d ", len(args)))
 		} else {
-			sqlBuilder.WriteString(fmt.Sprintf("AND score > $%d ", len(args)))
+			sqlBuilder.WriteString(fmt.Sprintf("AND logs.score > $%d ", len(args)))
 		}
 	}
 
-	if cfg.To != nil {
-		args = append(args, *cfg.To)
+	if to != nil {
+		args = append(args, *to)
 		if cfg.Reverse {
-			sqlBuilder.WriteString(fmt.Sprintf("AND score > $%d ", len(args)))
+			sqlBuilder.WriteString(fmt.Sprintf("AND logs.score > $%d ", len(args)))
 		} else {
-			sqlBuilder.WriteString(fmt.Sprintf("AND score < $%d ", len(args)))
+			sqlBuilder.WriteString(fmt.Sprintf("AND logs.score < $%d ", len(args)))
 		}
 	}
 
-	if cfg.ComparisonType == idx.ComparisonAND && len(cfg.Keys) > 1 {
-		args = append(args, len(cfg.Keys))
+	if len(cfg.Keys) > 1 {
 		sqlBuilder.WriteString("GROUP BY logs.member ")
-		sqlBuilder.WriteString(fmt.Sprintf("HAVING COUNT(1) = $%d ", len(args)))
+		if cfg.ComparisonType == idx.ComparisonAND {
+			args = append(args, len(cfg.Keys))
+			sqlBuilder.WriteString(fmt.Sprintf("HAVING COUNT(1) = $%d ", len(args)))
+		}
 	}
 
 	if cfg.Reverse {
-		sqlBuilder.WriteString("ORDER BY score DESC ")
+		sqlBuilder.WriteString("ORDER BY score DESC, member DESC ")
 	} else {
-		sqlBuilder.WriteString("ORDER BY score ASC ")
+		sqlBuilder.WriteString("ORDER BY score ASC, member ASC ")
 	}
 
-	if cfg.Limit > 0 {
+	if cfg.Limit > 0 && !(cfg.FilterSpent && cfg.RefreshSpends) {
 		args = append(args, cfg.Limit)
 		sqlBuilder.WriteString(fmt.Sprintf("LIMIT $%d ", len(args)))
 	}
@@ -87,9 +93,10 @@ func (p *PGStore) Search(ctx context.Context, cfg *idx.SearchCfg) (results []*id
 			if err = rows.Scan(&result.Member, &result.Score); err != nil {
 				return nil, err
 			}
-			if cfg.RefreshSpends {
-				log.Println("Refreshing spends", result.Member)
-				if spend, err := jb.GetSpend(result.Member); err != nil {
+			if cfg.FilterSpent && cfg.RefreshSpends {
+				if cfg.Limit > 0 && len(results) >= int(cfg.Limit) {
+					break
+				} else if spend, err := jb.GetSpendFrom(ctx, p.source(), result.Member); err != nil {
 					return nil, err
 				} else if spend != "" {
 					p.SetNewSpend(ctx, result.Member, spend)
@@ -118,6 +125,7 @@ func (p *PGStore) SearchMembers(ctx context.Context, cfg *idx.SearchCfg) (result
 }
 
 func (p *PGStore) SearchOutpoints(ctx context.Context, cfg *idx.SearchCfg) (results []string, err error) {
+	cfg.OutpointsOnly = true
 	if items, err := p.Search(ctx, cfg); err != nil {
 		return nil, err
 	} else {
@@ -0,0 +1,43 @@
+package pgstore_test
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/shruggr/1sat-indexer/v5/idx"
+	pgstore "github.com/shruggr/1sat-indexer/v5/idx/pg-store"
+	"github.com/shruggr/1sat-indexer/v5/idxtest"
+)
+
+// TestPGStore runs the store conformance suite against the database named by
+// POSTGRES_TEST. The tables in that database are dropped and recreated.
+func TestPGStore(t *testing.T) {
+	connString := os.Getenv("POSTGRES_TEST")
+	if connString == "" {
+		t.Skip("POSTGRES_TEST not set")
+	}
+	migration, err := os.ReadFile("../../migration/postgres/1_blockchain.up.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	idxtest.TestStore(t, func(t *testing.T) idx.TxoStore {
+		ctx := context.Background()
+		store, err := pgstore.NewPGStore(connString)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(store.DB.Close)
+		conn, err := store.DB.Acquire(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Release()
+		if _, err := conn.Conn().PgConn().Exec(ctx, `DROP TABLE IF EXISTS txos, txo_data, logs, owner_accounts`).ReadAll(); err != nil {
+			t.Fatal(err)
+		} else if _, err := conn.Conn().PgConn().Exec(ctx, string(migration)).ReadAll(); err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -19,6 +20,15 @@ import (
 
 type PGStore struct {
 	DB *pgxpool.Pool
+	// Source is consulted for spends when a refresh is requested. Defaults to jb.Source.
+	Source jb.TxSource
+}
+
+func (p *PGStore) source() jb.TxSource {
+	if p.Source != nil {
+		return p.Source
+	}
+	return jb.Source
 }
 
 func NewPGStore(connString string) (*PGStore, error) {
@@ -69,7 +79,6 @@ func (p *PGStore) LoadTxo(ctx context.Context, outpoint string, tags []string, s
 	if err := row.Scan(&txo.Outpoint, &txo.Height, &txo.Idx, &sats, &txo.Owners, &spendTxid); err == pgx.ErrNoRows {
 		return nil, nil
 	} else if err != nil {
-		log.Panic(err)
 		return nil, err
 	} else {
 		if spend {
@@ -81,7 +90,6 @@ func (p *PGStore) LoadTxo(ctx context.Context, outpoint string, tags []string, s
 		}
 		txo.Score = idx.HeightScore(txo.Height, txo.Idx)
 		if txo.Data, err = p.LoadData(ctx, txo.Outpoint.String(), tags); err != nil {
-			log.Panic(err)
 			return nil, err
 		} else if txo.Data == nil {
 			txo.Data = make(idx.IndexDataMap)
@@ -101,7 +109,7 @@ func (p *PGStore) LoadTxos(ctx context.Context, outpoints []string, tags []strin
 	}
 	rows, err := p.DB.Query(ctx, `SELECT outpoint, height, idx, satoshis, owners, spend
 		FROM txos 
-		WHERE outpoint = ANY($1)`,
+		WHERE outpoint = ANY($1) AND satoshis IS NOT NULL`,
 		outpoints,
 	)
 	if err != nil {
@@ -109,7 +117,7 @@ func (p *PGStore) LoadTxos(ctx context.Context, outpoints []string, tags []strin
 		return nil, err
 	}
 	defer rows.Close()
-	txos := make([]*idx.Txo, 0, len(outpoints))
+	txoMap := make(map[string]*idx.Txo, len(outpoints))
 	for rows.Next() {
 		txo := &idx.Txo{}
 		var spendTxid string
@@ -121,7 +129,18 @@ func (p *PGStore) LoadTxos(ctx context.Context, outpoints []string, tags []strin
 			txo.Spend = spendTxid
 		}
 		txo.Score = idx.HeightScore(txo.Height, txo.Idx)
-		if txo.Data, err = p.LoadData(ctx, txo.Outpoint.String(), tags); err != nil {
+		txoMap[txo.Outpoint.String()] = txo
+	}
+	rows.Close()
+
+	txos := make([]*idx.Txo, 0, len(outpoints))
+	for _, outpoint := range outpoints {
+		txo, ok := txoMap[outpoint]
+		if !ok {
+			txos = append(txos, nil)
+			continue
+		}
+		if txo.Data, err = p.LoadData(ctx, outpoint, tags); err != nil {
 			log.Panic(err)
 			return nil, err
 		} else if txo.Data == nil {
@@ -140,8 +159,9 @@ func (p *PGStore) LoadTxos(ctx context.Context, outpoints []string, tags []strin
 func (p *PGStore) LoadTxosByTxid(ctx context.Context, txid string, tags []string, script bool, spend bool) ([]*idx.Txo, error) {
 	rows, err := p.DB.Query(ctx, `SELECT outpoint
 		FROM txos 
-		WHERE outpoint LIKE $1`,
-		fmt.Sprintf("%s%%", txid),
+		WHERE outpoint LIKE $1 AND satoshis IS NOT NULL
+		ORDER BY split_part(outpoint, '_', 2)::int`,
+		fmt.Sprintf("%s\\_%%", txid),
 	)
 	if err != nil {
 		log.Panic(err)
@@ -155,6 +175,7 @@ func (p *PGStore) LoadTxosByTxid(ctx context.Context, txid string, tags []string
 			log.Panic(err)
 			return nil, err
 		}
+		outpoints = append(outpoints, outpoint)
 	}
 	return p.LoadTxos(ctx, outpoints, tags, script, spend)
 }
@@ -170,7 +191,6 @@ func (p *PGStore) LoadData(ctx context.Context, outpoint string, tags []string)
 		outpoint,
 		tags,
 	); err != nil {
-		log.Panic(err)
 		return nil, err
 	} else {
 		defer rows.Close()
@@ -178,7 +198,6 @@ func (p *PGStore) LoadData(ctx context.Context, outpoint string, tags []string)
 			var tag string
 			var dataStr string
 			if err = rows.Scan(&tag, &dataStr); err != nil {
-				log.Panic(err)
 				return nil, err
 			}
 			data[tag] = &idx.IndexData{
@@ -189,228 +208,435 @@ func (p *PGStore) LoadData(ctx context.Context, outpoint string, tags []string)
 	return data, nil
 }
 
+const saveBatchSize = 1000
+
 func (p *PGStore) SaveTxos(idxCtx *idx.IndexContext) (err error) {
-	// ctx := idxCtx.Ctx
-	// outpoints := make([]string, 0, len(idxCtx.Txos))
-	// batchSize := 1000
-	// insTxosRows := make([]string, 0, batchSize)
-	// insTxoArgs := make([]any, 0, 3*batchSize+2)
-	// insTxoArgs = append(insTxoArgs, idxCtx.Height, idxCtx.Idx)
-	// insLogsRows := make([]string, 0, batchSize)
-	// insLogsArgs := make([]any, 0, 2*batchSize+1)
-	// insLogsArgs = append(insLogsArgs, idxCtx.Score)
-	// insDataRows := make([]string, 0, batchSize)
-	// insDataArgs := make([]any, 0, 3*batchSize)
-	for _, txo := range idxCtx.Txos {
-		// outpoint := txo.Outpoint.String()
-		// outpoints = append(outpoints, outpoint)
-		// // score := idx.HeightScore(height, blkIdx)
-
-		// txo.Events = make([]string, 0, 100)
-		// datas := make(map[string]any, len(txo.Data))
-		// for tag, data := range txo.Data {
-		// 	if data != nil {
-		// 		txo.Events = append(txo.Events, evt.TagKey(tag))
-		// 		for _, event := range data.Events {
-		// 			txo.Events = append(txo.Events, evt.EventKey(tag, event))
-		// 		}
-		// 		if data.Data != nil {
-		// 			if datas[tag], err = data.MarshalJSON(); err != nil {
-		// 				log.Panic(err)
-		// 				return err
-		// 			}
-		// 		}
-		// 	}
-		// }
-		// for _, owner := range txo.Owners {
-		// 	if owner == "" {
-		// 		continue
-		// 	}
-		// 	txo.Events = append(txo.Events, idx.OwnerKey(owner))
-		// }
-
-		// insTxosRows = append(insTxosRows, fmt.Sprintf("($%d, $1, $2, $%d, $%d)", len(insTxoArgs)+1, len(insTxoArgs)+2, len(insTxoArgs)+3))
-		// insTxoArgs = append(insTxoArgs, outpoint, *txo.Satoshis, txo.Owners)
-
-		// if len(txo.Events) > 0 {
-		// 	for _, event := range txo.Events {
-		// 		insLogsRows = append(insLogsRows, fmt.Sprintf("($%d, $%d, $1)", len(insLogsArgs)+1, len(insLogsArgs)+2))
-		// 		insLogsArgs = append(insLogsArgs, event, outpoint)
-		// 	}
-		// }
-
-		// for tag, data := range datas {
-		// 	insDataRows = append(insDataRows, fmt.Sprintf("($%d, $%d, $%d)", len(insDataArgs)+1, len(insDataArgs)+2, len(insDataArgs)+3))
-		// 	insDataArgs = append(insDataArgs, outpoint, tag, data)
-		// }
-
-		// if vout == len(idxCtx.Txos)-1 || len(insTxosRows) >= batchSize {
-		// 	sql := "INSERT INTO txos(outpoint, height, idx, satoshis, owners) VALUES " +
-		// 		strings.Join(insTxosRows, ", ") +
-		// 		" ON CONFLICT (outpoint) DO UPDATE SET height = EXCLUDED.height, idx = EXCLUDED.idx, satoshis = EXCLUDED.satoshis, owners = EXCLUDED.owners"
-		// 	if _, err := p.DB.Exec(ctx, sql, insTxoArgs...); err != nil {
-		// 		log.Println("insTxo Err:", err, sql, insTxoArgs)
-		// 		log.Panic(err)
-		// 		return err
-		// 	}
-		// 	insTxosRows = make([]string, 0, batchSize)
-		// 	insTxoArgs = make([]any, 0, 3*len(idxCtx.Txos)+2)
-		// 	insTxoArgs = append(insTxoArgs, idxCtx.Height, idxCtx.Idx)
-		// }
-		// if vout == len(idxCtx.Txos)-1 || len(insLogsRows) >= batchSize {
-		// 	sql := "INSERT INTO logs(search_key, member, score) VALUES " +
-		// 		strings.Join(insLogsRows, ", ") +
-		// 		" ON CONFLICT (search_key, member) DO UPDATE SET score = EXCLUDED.score"
-		// 	if _, err := p.DB.Exec(ctx, sql, insLogsArgs...); err != nil {
-		// 		log.Println("insLogs Err:", err, sql, insLogsArgs)
-		// 		log.Panic(err)
-		// 		return err
-		// 	}
-		// 	insLogsRows = make([]string, 0, batchSize)
-		// 	insLogsArgs = make([]any, 0, 2*len(idxCtx.Txos)+1)
-		// 	insLogsArgs = append(insLogsArgs, idxCtx.Score)
-		// }
-		// if vout == len(idxCtx.Txos)-1 || len(insDataRows) >= batchSize {
-		// 	sql := "INSERT INTO txo_data(outpoint, tag, data) VALUES " +
-		// 		strings.Join(insDataRows, ", ") +
-		// 		" ON CONFLICT (outpoint, tag) DO UPDATE SET data = EXCLUDED.data"
-		// 	if _, err := p.DB.Exec(ctx, sql, insDataArgs...); err != nil {
-		// 		log.Println("insData Err:", err, sql, insDataArgs)
-		// 		log.Panic(err)
-		// 		return err
-		// 	}
-		// 	insDataRows = make([]string, 0, batchSize)
-		// 	insDataArgs = make([]any, 0, 3*len(idxCtx.Txos))
-		// }
-		if err := p.saveTxo(idxCtx.Ctx, txo, idxCtx.Height, idxCtx.Idx); err != nil {
-			log.Panic(err)
+	outpoints, datas, err := prepareTxos(idxCtx)
+	if err != nil {
+		return err
+	} else if err = p.transact(idxCtx, func(t pgx.Tx) error {
+		return p.saveTxos(idxCtx.Ctx, t, idxCtx, outpoints, datas)
+	}); err != nil {
+		return err
+	}
+	publishTxos(idxCtx, outpoints)
+	return nil
+}
+
+func (p *PGStore) SaveSpends(idxCtx *idx.IndexContext) error {
+	var ownerKeys []string
+	if err := p.transact(idxCtx, func(t pgx.Tx) (err error) {
+		ownerKeys, err = p.saveSpends(idxCtx.Ctx, t, idxCtx)
+		return
+	}); err != nil {
+		return err
+	}
+	publishSpends(idxCtx, ownerKeys)
+	return nil
+}
+
+func (p *PGStore) Commit(idxCtx *idx.IndexContext, logKeys ...string) error {
+	ctx := idxCtx.Ctx
+	outpoints, datas, err := prepareTxos(idxCtx)
+	if err != nil {
+		return err
+	}
+	var ownerKeys []string
+	if err := p.transact(idxCtx, func(t pgx.Tx) (err error) {
+		if err = p.saveTxos(ctx, t, idxCtx, outpoints, datas); err != nil {
+			return
+		} else if ownerKeys, err = p.saveSpends(ctx, t, idxCtx); err != nil {
+			return
+		} else if err = saveIncrs(ctx, t, idxCtx.TxidHex, idxCtx.Incrs); err != nil {
+			return
+		} else if err = saveWrites(ctx, t, idxCtx.TxidHex, idxCtx.Writes); err != nil {
+			return
+		} else if len(logKeys) > 0 {
+			if _, err = t.Exec(ctx, `INSERT INTO logs(search_key, member, score)
+				SELECT search_key, $2, $3
+				FROM unnest($1::text[]) search_key
+				ON CONFLICT (search_key, member) DO UPDATE SET score = $3`,
+				logKeys,
+				idxCtx.TxidHex,
+				idxCtx.Score,
+			); err != nil {
+				log.Println("insLogs Err:", idxCtx.TxidHex, err)
+				return
+			}
+		}
+		return
+	}); err != nil {
+		return err
+	}
+	publishTxos(idxCtx, outpoints)
+	publishSpends(idxCtx, ownerKeys)
+	return nil
+}
+
+// saveIncrs applies incrs in place of the increments journaled by a previous
+// commit of txid, then journals incrs
+func saveIncrs(ctx context.Context, t pgx.Tx, txid string, incrs []*idx.Incr) error {
+	journal := idx.IncrKey(txid)
+	prev := make([]*idx.Log, 0)
+	if rows, err := t.Query(ctx, `SELECT member, score FROM logs
+		WHERE search_key = $1
+		FOR UPDATE`,
+		journal,
+	); err != nil {
+		return err
+	} else {
+		defer rows.Close()
+		for rows.Next() {
+			l := &idx.Log{}
+			if err := rows.Scan(&l.Member, &l.Score); err != nil {
+				return err
+			}
+			prev = append(prev, l)
+		}
+		if err := rows.Err(); err != nil {
 			return err
 		}
 	}
+	if err := applyIncrs(ctx, t, idx.NetIncrs(prev, incrs)); err != nil {
+		return err
+	} else if _, err := t.Exec(ctx, `DELETE FROM logs WHERE search_key = $1`, journal); err != nil {
+		return err
+	}
+	for _, incr := range idx.MergeIncrs(incrs) {
+		if _, err := t.Exec(ctx, `INSERT INTO logs(search_key, member, score)
+			VALUES ($1, $2, $3)`,
+			journal,
+			incr.JournalMember(),
+			incr.Delta,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveWrites restores the entries journaled by a previous commit of txid, then
+// applies writes and journals the entries they replace
+func saveWrites(ctx context.Context, t pgx.Tx, txid string, writes []*idx.Write) error {
+	journal := idx.WriteKey(txid)
+	prev := make([]*idx.Log, 0)
+	if rows, err := t.Query(ctx, `SELECT member, score FROM logs
+		WHERE search_key = $1
+		FOR UPDATE`,
+		journal,
+	); err != nil {
+		return err
+	} else {
+		defer rows.Close()
+		for rows.Next() {
+			l := &idx.Log{}
+			if err := rows.Scan(&l.Member, &l.Score); err != nil {
+				return err
+			}
+			prev = append(prev, l)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	apply, entries, err := idx.PlanWrites(prev, writes, func(key string, member string) (*float64, error) {
+		var score float64
+		if err := t.QueryRow(ctx, `SELECT score FROM logs
+			WHERE search_key = $1 AND member = $2`,
+			key,
+			member,
+		).Scan(&score); err == pgx.ErrNoRows {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		return &score, nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, w := range apply {
+		if w.Delete {
+			if _, err := t.Exec(ctx, `DELETE FROM logs
+				WHERE search_key = $1 AND member = $2`,
+				w.Key,
+				w.Member,
+			); err != nil {
+				return err
+			}
+		} else if _, err := t.Exec(ctx, `INSERT INTO logs(search_key, member, score)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (search_key, member) DO UPDATE SET score = $3`,
+			w.Key,
+			w.Member,
+			w.Score,
+		); err != nil {
+			return err
+		}
+	}
+	if _, err := t.Exec(ctx, `DELETE FROM logs WHERE search_key = $1`, journal); err != nil {
+		return err
+	}
+	for _, l := range entries {
+		if _, err := t.Exec(ctx, `INSERT INTO logs(search_key, member, score)
+			VALUES ($1, $2, $3)`,
+			journal,
+			l.Member,
+			l.Score,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	// for vout, txo := range idxCtx.Txos {
-	// 	for _, event := range txo.Events {
-	// 		evt.Publish(ctx, event, outpoints[vout])
-	// 	}
-	// }
+// applyIncrs moves the scores of each increment, removing members which fall to zero or below
+func applyIncrs(ctx context.Context, t pgx.Tx, incrs []*idx.Incr) error {
+	for _, incr := range incrs {
+		if _, err := t.Exec(ctx, `INSERT INTO logs(search_key, member, score)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (search_key, member) DO UPDATE SET score = logs.score + $3`,
+			incr.Key,
+			incr.Member,
+			incr.Delta,
+		); err != nil {
+			return err
+		} else if _, err := t.Exec(ctx, `DELETE FROM logs
+			WHERE search_key = $1 AND member = $2 AND score <= 0`,
+			incr.Key,
+			incr.Member,
+		); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func (p *PGStore) saveTxo(ctx context.Context, txo *idx.Txo, height uint32, blkIdx uint64) (err error) {
-	outpoint := txo.Outpoint.String()
-	score := idx.HeightScore(height, blkIdx)
-
-	txo.Events = make([]string, 0, 100)
-	datas := make(map[string]any, len(txo.Data))
-	for tag, data := range txo.Data {
-		if data != nil {
-			txo.Events = append(txo.Events, evt.TagKey(tag))
-			for _, event := range data.Events {
-				txo.Events = append(txo.Events, evt.EventKey(tag, event))
+// transact runs fn in a database transaction, retrying on unique violations and deadlocks
+func (p *PGStore) transact(idxCtx *idx.IndexContext, fn func(t pgx.Tx) error) (err error) {
+	for i := range 3 {
+		if err = pgx.BeginFunc(idxCtx.Ctx, p.DB, fn); err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && (pgErr.Code == "23505" || pgErr.Code == "40P01") {
+				log.Println("Conflict. Retrying:", idxCtx.TxidHex, i, err)
+				time.Sleep(10 * time.Millisecond)
+				continue
 			}
-			if data.Data != nil {
-				if datas[tag], err = data.MarshalJSON(); err != nil {
-					log.Panic(err)
-					return err
+		}
+		break
+	}
+	return
+}
+
+// prepareTxos builds the events of each txo and marshals its data ahead of a write
+func prepareTxos(idxCtx *idx.IndexContext) (outpoints []string, datas []map[string][]byte, err error) {
+	outpoints = make([]string, 0, len(idxCtx.Txos))
+	datas = make([]map[string][]byte, 0, len(idxCtx.Txos))
+	for _, txo := range idxCtx.Txos {
+		outpoints = append(outpoints, txo.Outpoint.String())
+
+		txo.Events = make([]string, 0, 100)
+		eventSet := make(map[string]struct{}, 100)
+		addEvent := func(event string) {
+			if _, ok := eventSet[event]; !ok {
+				eventSet[event] = struct{}{}
+				txo.Events = append(txo.Events, event)
+			}
+		}
+		txoData := make(map[string][]byte, len(txo.Data))
+		for tag, data := range txo.Data {
+			if data != nil {
+				addEvent(evt.TagKey(tag))
+				for _, event := range data.Events {
+					addEvent(evt.EventKey(tag, event))
+				}
+				for _, dep := range data.Deps {
+					addEvent(idx.DependentsKey(tag, dep.String()))
+				}
+				if data.Data != nil {
+					if txoData[tag], err = data.MarshalJSON(); err != nil {
+						return nil, nil, err
+					}
 				}
 			}
 		}
+		for _, owner := range txo.Owners {
+			if owner == "" {
+				continue
+			}
+			addEvent(idx.OwnerKey(owner))
+		}
+		datas = append(datas, txoData)
 	}
-	for _, owner := range txo.Owners {
-		if owner == "" {
-			continue
+	return
+}
+
+func publishTxos(idxCtx *idx.IndexContext, outpoints []string) {
+	for vout, txo := range idxCtx.Txos {
+		for _, event := range txo.Events {
+			evt.Publish(idxCtx.Ctx, event, outpoints[vout])
 		}
-		txo.Events = append(txo.Events, idx.OwnerKey(owner))
 	}
+}
 
-	if _, err := p.insert(ctx, `INSERT INTO txos(outpoint, height, idx, satoshis, owners)
-			VALUES ($1, $2, $3, $4, $5)
-			ON CONFLICT (outpoint)
-			DO UPDATE SET height = $2, idx = $3, satoshis = $4, owners = $5`,
-		outpoint,
-		height,
-		blkIdx,
-		*txo.Satoshis,
-		txo.Owners,
-	); err != nil {
-		log.Panicln("insTxo Err:", err)
-		return err
+func publishSpends(idxCtx *idx.IndexContext, ownerKeys []string) {
+	for _, ownerKey := range ownerKeys {
+		evt.Publish(idxCtx.Ctx, ownerKey, idxCtx.TxidHex)
 	}
+}
 
-	if len(txo.Events) > 0 {
-		if _, err := p.insert(ctx, `INSERT INTO logs(search_key, member, score)
-			SELECT search_key, $2, $3
-			FROM unnest($1::text[]) search_key
-			ON CONFLICT (search_key, member) DO UPDATE SET score = $3`,
-			txo.Events,
-			outpoint,
+// saveTxos writes the txos, their data and event logs of an IndexContext within t
+func (p *PGStore) saveTxos(ctx context.Context, t pgx.Tx, idxCtx *idx.IndexContext, outpoints []string, datas []map[string][]byte) error {
+	score := idx.HeightScore(idxCtx.Height, idxCtx.Idx)
+	txoRows := make([]string, 0, saveBatchSize)
+	txoArgs := make([]any, 0, 3*saveBatchSize+2)
+	logKeys := make([]string, 0, saveBatchSize)
+	logMembers := make([]string, 0, saveBatchSize)
+	dataOutpoints := make([]string, 0, saveBatchSize)
+	dataTags := make([]string, 0, saveBatchSize)
+	dataValues := make([]string, 0, saveBatchSize)
+
+	flushTxos := func() error {
+		if len(txoRows) == 0 {
+			return nil
+		}
+		if _, err := t.Exec(ctx, `INSERT INTO txos(outpoint, height, idx, satoshis, owners)
+			VALUES `+strings.Join(txoRows, ", ")+`
+			ON CONFLICT (outpoint) DO UPDATE SET
+				height = EXCLUDED.height,
+				idx = EXCLUDED.idx,
+				satoshis = EXCLUDED.satoshis,
+				owners = EXCLUDED.owners`,
+			append([]any{idxCtx.Height, idxCtx.Idx}, txoArgs...)...,
+		); err != nil {
+			log.Println("insTxos Err:", idxCtx.TxidHex, err)
+			return err
+		}
+		txoRows = txoRows[:0]
+		txoArgs = txoArgs[:0]
+		return nil
+	}
+	flushLogs := func() error {
+		if len(logKeys) == 0 {
+			return nil
+		}
+		if _, err := t.Exec(ctx, `INSERT INTO logs(search_key, member, score)
+			SELECT search_key, member, $3
+			FROM unnest($1::text[], $2::text[]) AS l(search_key, member)
+			ON CONFLICT (search_key, member) DO UPDATE SET score = EXCLUDED.score`,
+			logKeys,
+			logMembers,
 			score,
 		); err != nil {
-			log.Panicln("insTxo Err:", err)
+			log.Println("insLogs Err:", idxCtx.TxidHex, err)
 			return err
 		}
+		logKeys = logKeys[:0]
+		logMembers = logMembers[:0]
+		return nil
 	}
-	for tag, data := range datas {
-		if _, err := p.insert(ctx, `INSERT INTO txo_data(outpoint, tag, data)
-				VALUES ($1, $2, $3)
-				ON CONFLICT (outpoint, tag) DO UPDATE SET data = $3`,
-			outpoint,
-			tag,
-			data,
+	flushData := func() error {
+		if len(dataOutpoints) == 0 {
+			return nil
+		}
+		if _, err := t.Exec(ctx, `INSERT INTO txo_data(outpoint, tag, data)
+			SELECT outpoint, tag, data::jsonb
+			FROM unnest($1::text[], $2::text[], $3::text[]) AS d(outpoint, tag, data)
+			ON CONFLICT (outpoint, tag) DO UPDATE SET data = EXCLUDED.data`,
+			dataOutpoints,
+			dataTags,
+			dataValues,
 		); err != nil {
-			log.Panic(err)
+			log.Println("insData Err:", idxCtx.TxidHex, err)
 			return err
 		}
+		dataOutpoints = dataOutpoints[:0]
+		dataTags = dataTags[:0]
+		dataValues = dataValues[:0]
+		return nil
 	}
 
-	for _, event := range txo.Events {
-		evt.Publish(ctx, event, outpoint)
+	for vout, txo := range idxCtx.Txos {
+		outpoint := outpoints[vout]
+		txoRows = append(txoRows, fmt.Sprintf("($%d, $1, $2, $%d, $%d)", len(txoArgs)+3, len(txoArgs)+4, len(txoArgs)+5))
+		txoArgs = append(txoArgs, outpoint, txo.Satoshis, txo.Owners)
+		if len(txoRows) >= saveBatchSize {
+			if err := flushTxos(); err != nil {
+				return err
+			}
+		}
+
+		for _, event := range txo.Events {
+			logKeys = append(logKeys, event)
+			logMembers = append(logMembers, outpoint)
+			if tag, dep, ok := idx.ParseDependentsKey(event); ok {
+				logKeys = append(logKeys, idx.DepsKey(tag, outpoint))
+				logMembers = append(logMembers, dep)
+			}
+		}
+		if len(logKeys) >= saveBatchSize {
+			if err := flushLogs(); err != nil {
+				return err
+			}
+		}
+
+		for tag, data := range datas[vout] {
+			dataOutpoints = append(dataOutpoints, outpoint)
+			dataTags = append(dataTags, tag)
+			dataValues = append(dataValues, string(data))
+		}
+		if len(dataOutpoints) >= saveBatchSize {
+			if err := flushData(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := flushTxos(); err != nil {
+		return err
+	} else if err := flushLogs(); err != nil {
+		return err
+	} else if err := flushData(); err != nil {
+		return err
 	}
 	return nil
 }
 
-func (p *PGStore) SaveSpends(idxCtx *idx.IndexContext) error {
+// saveSpends marks the inputs of an IndexContext as spent and logs the txid to the spent owners within t
+func (p *PGStore) saveSpends(ctx context.Context, t pgx.Tx, idxCtx *idx.IndexContext) (ownerKeys []string, err error) {
 	score := idx.HeightScore(idxCtx.Height, idxCtx.Idx)
-	spends := make(map[string]string, len(idxCtx.Spends))
-	outpoints := make([]any, 0, len(idxCtx.Spends))
+	outpoints := make([]string, 0, len(idxCtx.Spends))
 	owners := make(map[string]struct{}, 10)
-	ownerKyes := make([]string, 0, 10)
+	ownerKeys = make([]string, 0, 10)
 	for _, spend := range idxCtx.Spends {
-		outpoint := spend.Outpoint.String()
-		spends[outpoint] = idxCtx.TxidHex
-		outpoints = append(outpoints, outpoint)
+		outpoints = append(outpoints, spend.Outpoint.String())
 		for _, owner := range spend.Owners {
 			if _, ok := owners[owner]; !ok && owner != "" {
 				owners[owner] = struct{}{}
-				ownerKyes = append(ownerKyes, idx.OwnerKey(owner))
+				ownerKeys = append(ownerKeys, idx.OwnerKey(owner))
 			}
 		}
 	}
-	if _, err := p.insert(idxCtx.Ctx, `INSERT INTO txos(outpoint, spend)
+	if _, err = t.Exec(ctx, `INSERT INTO txos(outpoint, spend)
 		SELECT outpoint, $2
 		FROM unnest($1::text[]) outpoint
 		ON CONFLICT (outpoint) DO UPDATE SET spend = $2`,
 		outpoints,
 		idxCtx.TxidHex,
 	); err != nil {
-		log.Panic(err)
-		return err
-	} else if _, err := p.insert(idxCtx.Ctx, `INSERT INTO logs(search_key, member, score)
+		log.Println("insSpends Err:", idxCtx.TxidHex, err)
+		return nil, err
+	} else if _, err = t.Exec(ctx, `INSERT INTO logs(search_key, member, score)
 		SELECT search_key, $2, $3
 		FROM unnest($1::text[]) search_key
 		ON CONFLICT (search_key, member) DO UPDATE SET score = $3`,
-		ownerKyes,
+		ownerKeys,
 		idxCtx.TxidHex,
 		score,
 	); err != nil {
-		log.Panic(err)
-		return err
-	}
-
-	for _, ownerKey := range ownerKyes {
-		evt.Publish(idxCtx.Ctx, ownerKey, idxCtx.TxidHex)
+		log.Println("insLogs Err:", idxCtx.TxidHex, err)
+		return nil, err
 	}
-	return nil
+	return ownerKeys, nil
 }
 
 func (p *PGStore) RollbackSpend(ctx context.Context, spend *idx.Txo, txid string) error {
@@ -420,18 +646,15 @@ func (p *PGStore) RollbackSpend(ctx context.Context, spend *idx.Txo, txid string
 		spend.Outpoint.String(),
 		txid,
 	); err != nil {
-		log.Panic(err)
+		log.Println("rollbackSpend Err:", txid, err)
+		return err
+	} else if _, err := p.DB.Exec(ctx, `DELETE FROM logs
+		WHERE member = $1`,
+		txid,
+	); err != nil {
+		log.Println("rollbackLogs Err:", txid, err)
 		return err
-	} else {
-		if _, err := p.DB.Exec(ctx, `DELETE FROM logs
-			WHERE member = $1`,
-			txid,
-		); err != nil {
-			log.Panic(err)
-			return err
-		}
 	}
-
 	return nil
 }
 
@@ -444,7 +667,7 @@ func (p *PGStore) GetSpend(ctx context.Context, outpoint string, refresh bool) (
 		return spend, err
 	}
 	if spend == "" && refresh {
-		if spend, err = jb.GetSpend(outpoint); err != nil {
+		if spend, err = jb.GetSpendFrom(ctx, p.source(), outpoint); err != nil {
 			return
 		} else if spend != "" {
 			if _, err = p.SetNewSpend(ctx, outpoint, spend); err != nil {
@@ -456,7 +679,7 @@ func (p *PGStore) GetSpend(ctx context.Context, outpoint string, refresh bool) (
 }
 
 func (p *PGStore) GetSpends(ctx context.Context, outpoints []string, refresh bool) ([]string, error) {
-	spends := make([]string, 0, len(outpoints))
+	spendMap := make(map[string]string, len(outpoints))
 	if rows, err := p.DB.Query(ctx, `SELECT outpoint, spend FROM txos 
 		WHERE outpoint = ANY($1)`,
 		outpoints,
@@ -472,17 +695,20 @@ func (p *PGStore) GetSpends(ctx context.Context, outpoints []string, refresh boo
 				log.Panic(err)
 				return nil, err
 			}
-			if spend == "" && refresh {
-				if spend, err = jb.GetSpend(outpoint); err != nil {
-					return nil, err
-				} else if spend != "" {
-					if _, err = p.SetNewSpend(ctx, outpoint, spend); err != nil {
-						return nil, err
-					}
-				}
+			spendMap[outpoint] = spend
+		}
+		rows.Close()
+	}
+	spends := make([]string, 0, len(outpoints))
+	for _, outpoint := range outpoints {
+		spend := spendMap[outpoint]
+		if spend == "" && refresh {
+			var err error
+			if spend, err = p.GetSpend(ctx, outpoint, true); err != nil {
+				return nil, err
 			}
-			spends = append(spends, spend)
 		}
+		spends = append(spends, spend)
 	}
 	return spends, nil
 }
@@ -516,45 +742,51 @@ func (p *PGStore) UnsetSpends(ctx context.Context, outpoints []string) error {
 }
 
 func (p *PGStore) Rollback(ctx context.Context, txid string) error {
-	if t, err := p.DB.Begin(ctx); err != nil {
-		log.Panic(err)
+	t, err := p.DB.Begin(ctx)
+	if err != nil {
 		return err
-	} else {
-		defer t.Rollback(ctx)
-		txidPattern := fmt.Sprintf("%s%%", txid)
-		if _, err = t.Exec(ctx, `UPDATE txos
-			SET spend = ''
-			WHERE spend = $1`,
-			txid,
-		); err != nil {
-			log.Panic(err)
-			return err
-		} else if _, err = t.Exec(ctx, `DELETE FROM logs
-			WHERE member LIKE $1`,
-			txidPattern,
-		); err != nil {
-			log.Panic(err)
-			return err
-		} else if _, err = t.Exec(ctx, `DELETE FROM txo_data
-			WHERE outpoint LIKE $1`,
-			txidPattern,
-		); err != nil {
-			log.Panic(err)
-			return err
-		} else if _, err = t.Exec(ctx, `DELETE FROM txos
-			WHERE outpoint LIKE $1`,
-			txidPattern,
-		); err != nil {
-			log.Panic(err)
-			return err
-		}
-
-		if err = t.Commit(ctx); err != nil {
-			log.Panic(err)
-			return err
-		}
 	}
-	return nil
+	defer t.Rollback(ctx)
+	txidPattern := fmt.Sprintf("%s%%", txid)
+	if err = saveIncrs(ctx, t, txid, nil); err != nil {
+		log.Println("rollbackIncrs Err:", txid, err)
+		return err
+	} else if err = saveWrites(ctx, t, txid, nil); err != nil {
+		log.Println("rollbackWrites Err:", txid, err)
+		return err
+	} else if _, err = t.Exec(ctx, `UPDATE txos
+		SET spend = ''
+		WHERE spend = $1`,
+		txid,
+	); err != nil {
+		log.Println("rollbackSpends Err:", txid, err)
+		return err
+	} else if _, err = t.Exec(ctx, `DELETE FROM logs
+		WHERE member LIKE $1`,
+		txidPattern,
+	); err != nil {
+		log.Println("rollbackLogs Err:", txid, err)
+		return err
+	} else if _, err = t.Exec(ctx, `DELETE FROM logs
+		WHERE search_key LIKE 'dep:%:' || $1`,
+		txidPattern,
+	); err != nil {
+		log.Println("rollbackDeps Err:", txid, err)
+		return err
+	} else if _, err = t.Exec(ctx, `DELETE FROM txo_data
+		WHERE outpoint LIKE $1`,
+		txidPattern,
+	); err != nil {
+		log.Println("rollbackData Err:", txid, err)
+		return err
+	} else if _, err = t.Exec(ctx, `DELETE FROM txos
+		WHERE outpoint LIKE $1`,
+		txidPattern,
+	); err != nil {
+		log.Println("rollbackTxos Err:", txid, err)
+		return err
+	}
+	return t.Commit(ctx)
 }
 
 // func (p *PGStore) RollbackTxo(ctx context.Context, txo *idx.Txo) error {
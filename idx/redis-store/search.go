@@ -16,28 +16,34 @@ import (
 
 func BuildQuery(cfg *idx.SearchCfg) *redis.ZRangeBy {
 	query := &redis.ZRangeBy{}
-	query.Count = int64(cfg.Limit)
+	// results filtered after the query are limited once filtered
+	if len(cfg.Keys) == 1 && !cfg.FilterSpent && !cfg.OutpointsOnly {
+		query.Count = int64(cfg.Limit)
+	} else if len(cfg.Keys) > 1 && cfg.ComparisonType == idx.ComparisonOR && !cfg.FilterSpent && !cfg.OutpointsOnly {
+		query.Count = int64(cfg.Limit)
+	}
 
+	from, to := cfg.Range()
 	if cfg.Reverse {
-		if cfg.From != nil {
-			query.Max = fmt.Sprintf("(%f", *cfg.From)
+		if from != nil {
+			query.Max = fmt.Sprintf("(%f", *from)
 		} else {
 			query.Max = "+inf"
 		}
 		query.Min = "-inf"
 	} else {
-		if cfg.From != nil {
-			query.Min = fmt.Sprintf("(%f", *cfg.From)
+		if from != nil {
+			query.Min = fmt.Sprintf("(%f", *from)
 		} else {
 			query.Min = "-inf"
 		}
 		query.Max = "+inf"
 	}
-	if cfg.To != nil {
+	if to != nil {
 		if cfg.Reverse {
-			query.Min = fmt.Sprintf("(%f", *cfg.To)
+			query.Min = fmt.Sprintf("(%f", *to)
 		} else {
-			query.Max = fmt.Sprintf("(%f", *cfg.To)
+			query.Max = fmt.Sprintf("(%f", *to)
 		}
 	}
 
@@ -51,7 +57,6 @@ type record struct {
 
 func (r *RedisStore) Search(ctx context.Context, cfg *idx.SearchCfg) (records []*idx.Log, err error) {
 	query := BuildQuery(cfg)
-	// outpointCounts := make(map[string]int)
 	outpointSet := make(map[string]*record)
 	keyCount := len(cfg.Keys)
 	records = make([]*idx.Log, 0, keyCount*int(cfg.Limit))
@@ -72,13 +77,17 @@ func (r *RedisStore) Search(ctx context.Context, cfg *idx.SearchCfg) (records []
 				continue
 			}
 			if keyCount > 1 {
-				if _, exists := outpointSet[outpoint]; !exists {
+				if rec, exists := outpointSet[outpoint]; !exists {
 					outpointSet[outpoint] = &record{
 						score: result.Score,
-						count: 0,
+						count: 1,
+					}
+				} else {
+					rec.count++
+					if result.Score < rec.score {
+						rec.score = result.Score
 					}
 				}
-				outpointSet[outpoint].count++
 			} else {
 				records = append(records, &idx.Log{
 					Member: outpoint,
@@ -98,20 +107,38 @@ func (r *RedisStore) Search(ctx context.Context, cfg *idx.SearchCfg) (records []
 			})
 		}
 		slices.SortFunc(records, func(a, b *idx.Log) int {
+			cmp := 0
+			if a.Score < b.Score {
+				cmp = -1
+			} else if a.Score > b.Score {
+				cmp = 1
+			} else if a.Member < b.Member {
+				cmp = -1
+			} else if a.Member > b.Member {
+				cmp = 1
+			}
 			if cfg.Reverse {
-				if a.Score > b.Score {
-					return -1
-				} else if a.Score < b.Score {
-					return 1
-				}
-			} else {
-				if a.Score < b.Score {
-					return -1
-				} else if a.Score > b.Score {
-					return 1
-				}
+				return -cmp
 			}
-			return 0
+			return cmp
+		})
+	}
+
+	if cfg.FilterSpent && len(records) > 0 {
+		outpoints := make([]string, 0, len(records))
+		for _, record := range records {
+			outpoints = append(outpoints, record.Member)
+		}
+		if outpoints, err = r.filterSpent(ctx, outpoints, cfg.RefreshSpends); err != nil {
+			return nil, err
+		}
+		unspent := make(map[string]struct{}, len(outpoints))
+		for _, outpoint := range outpoints {
+			unspent[outpoint] = struct{}{}
+		}
+		records = slices.DeleteFunc(records, func(l *idx.Log) bool {
+			_, ok := unspent[l.Member]
+			return !ok
 		})
 	}
 
@@ -132,7 +159,7 @@ func (r *RedisStore) filterSpent(ctx context.Context, outpoints []string, refres
 		for i, outpoint := range outpoints {
 			if spends[i] == nil {
 				if refresh {
-					if spend, err := jb.GetSpend(outpoint); err != nil {
+					if spend, err := jb.GetSpendFrom(ctx, r.source(), outpoint); err != nil {
 						return nil, err
 					} else if spend != "" {
 						if _, err := r.SetNewSpend(ctx, outpoint, spend); err != nil {
@@ -181,17 +208,11 @@ func (r *RedisStore) SearchTxos(ctx context.Context, cfg *idx.SearchCfg) (txos [
 		return nil, err
 	}
 	outpoints := make([]string, 0, len(results))
-	resultMap := make(map[string]*idx.Log, len(results))
 	for _, result := range results {
-		resultMap[result.Member] = result
 		outpoints = append(outpoints, result.Member)
 	}
 	var spends []string
-	if cfg.FilterSpent {
-		if outpoints, err = r.filterSpent(ctx, outpoints, cfg.RefreshSpends); err != nil {
-			return nil, err
-		}
-	} else if cfg.IncludeSpend {
+	if cfg.IncludeSpend && !cfg.IncludeTxo {
 		if spends, err = r.GetSpends(ctx, outpoints, cfg.RefreshSpends); err != nil {
 			return nil, err
 		}
@@ -203,8 +224,7 @@ func (r *RedisStore) SearchTxos(ctx context.Context, cfg *idx.SearchCfg) (txos [
 		}
 	} else {
 		txos = make([]*idx.Txo, 0, len(results))
-		for _, outpoint := range outpoints {
-			result := resultMap[outpoint]
+		for i, result := range results {
 			txo := &idx.Txo{
 				Height: uint32(result.Score / 1000000000),
 				Idx:    uint64(result.Score) % 1000000000,
@@ -212,9 +232,9 @@ func (r *RedisStore) SearchTxos(ctx context.Context, cfg *idx.SearchCfg) (txos [
 				Data:   make(map[string]*idx.IndexData),
 			}
 			if cfg.IncludeSpend {
-				txo.Spend = spends[0]
+				txo.Spend = spends[i]
 			}
-			if txo.Outpoint, err = lib.NewOutpointFromString(outpoint); err != nil {
+			if txo.Outpoint, err = lib.NewOutpointFromString(result.Member); err != nil {
 				return nil, err
 			} else if txo.Data, err = r.LoadData(ctx, txo.Outpoint.String(), cfg.IncludeTags); err != nil {
 				return nil, err
@@ -235,15 +255,14 @@ func (r *RedisStore) SearchTxos(ctx context.Context, cfg *idx.SearchCfg) (txos [
 
 func (r *RedisStore) SearchBalance(ctx context.Context, cfg *idx.SearchCfg) (balance uint64, err error) {
 	cfg.OutpointsOnly = true
+	cfg.FilterSpent = true
 	if outpoints, err := r.SearchMembers(ctx, cfg); err != nil {
 		return 0, err
-	} else if outpoints, err = r.filterSpent(ctx, outpoints, cfg.RefreshSpends); err != nil {
-		return 0, err
 	} else if txos, err := r.LoadTxos(ctx, outpoints, nil, false, false); err != nil {
 		return 0, err
 	} else {
 		for _, txo := range txos {
-			if txo.Satoshis != nil {
+			if txo != nil && txo.Satoshis != nil {
 				balance += *txo.Satoshis
 			}
 		}
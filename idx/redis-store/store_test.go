@@ -0,0 +1,21 @@
+package redisstore_test
+
+import (
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/shruggr/1sat-indexer/v5/idx"
+	redisstore "github.com/shruggr/1sat-indexer/v5/idx/redis-store"
+	"github.com/shruggr/1sat-indexer/v5/idxtest"
+)
+
+func TestRedisStore(t *testing.T) {
+	idxtest.TestStore(t, func(t *testing.T) idx.TxoStore {
+		mr := miniredis.RunT(t)
+		store, err := redisstore.NewRedisStore("redis://" + mr.Addr())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return store
+	})
+}
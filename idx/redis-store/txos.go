@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"strconv"
 
 	"github.com/redis/go-redis/v9"
 	"github.com/shruggr/1sat-indexer/v5/evt"
@@ -15,6 +16,15 @@ import (
 
 type RedisStore struct {
 	DB *redis.Client
+	// Source is consulted for spends when a refresh is requested. Defaults to jb.Source.
+	Source jb.TxSource
+}
+
+func (r *RedisStore) source() jb.TxSource {
+	if r.Source != nil {
+		return r.Source
+	}
+	return jb.Source
 }
 
 func NewRedisStore(connString string) (*RedisStore, error) {
@@ -31,12 +41,10 @@ func (r *RedisStore) LoadTxo(ctx context.Context, outpoint string, tags []string
 	if result, err := r.DB.HGet(ctx, TxosKey, outpoint).Bytes(); err == redis.Nil {
 		return nil, nil
 	} else if err != nil {
-		log.Panic(err)
 		return nil, err
 	} else {
 		txo := &idx.Txo{}
 		if err := msgpack.Unmarshal(result, txo); err != nil {
-			log.Panic(err)
 			return nil, err
 		}
 		txo.Score = idx.HeightScore(txo.Height, txo.Idx)
@@ -127,7 +135,6 @@ func (r *RedisStore) LoadData(ctx context.Context, outpoint string, tags []strin
 	}
 	data = make(idx.IndexDataMap, len(tags))
 	if datas, err := r.DB.HMGet(ctx, TxoDataKey(outpoint), tags...).Result(); err != nil {
-		log.Panic(err)
 		return nil, err
 	} else {
 		for i, tag := range tags {
@@ -142,15 +149,34 @@ func (r *RedisStore) LoadData(ctx context.Context, outpoint string, tags []strin
 }
 
 func (r *RedisStore) SaveTxos(idxCtx *idx.IndexContext) (err error) {
+	if _, err := r.DB.TxPipelined(idxCtx.Ctx, func(pipe redis.Pipeliner) error {
+		return r.saveTxos(idxCtx, pipe)
+	}); err != nil {
+		return err
+	}
+	r.publishTxos(idxCtx)
+	return nil
+}
+
+func (r *RedisStore) saveTxos(idxCtx *idx.IndexContext, pipe redis.Pipeliner) error {
 	for _, txo := range idxCtx.Txos {
-		if err := r.saveTxo(idxCtx.Ctx, txo, idxCtx.Height, idxCtx.Idx); err != nil {
+		if err := r.saveTxo(idxCtx.Ctx, pipe, txo, idxCtx.Height, idxCtx.Idx); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (r *RedisStore) saveTxo(ctx context.Context, txo *idx.Txo, height uint32, blkIdx uint64) (err error) {
+func (r *RedisStore) publishTxos(idxCtx *idx.IndexContext) {
+	for _, txo := range idxCtx.Txos {
+		outpoint := txo.Outpoint.String()
+		for _, event := range txo.Events {
+			evt.Publish(idxCtx.Ctx, event, outpoint)
+		}
+	}
+}
+
+func (r *RedisStore) saveTxo(ctx context.Context, pipe redis.Pipeliner, txo *idx.Txo, height uint32, blkIdx uint64) (err error) {
 	outpoint := txo.Outpoint.String()
 	score := idx.HeightScore(height, blkIdx)
 
@@ -161,8 +187,10 @@ func (r *RedisStore) saveTxo(ctx context.Context, txo *idx.Txo, height uint32, b
 		for _, event := range data.Events {
 			txo.Events = append(txo.Events, evt.EventKey(tag, event))
 		}
+		for _, dep := range data.Deps {
+			txo.Events = append(txo.Events, idx.DependentsKey(tag, dep.String()))
+		}
 		if datas[tag], err = data.MarshalJSON(); err != nil {
-			log.Panic(err)
 			return err
 		}
 	}
@@ -175,71 +203,111 @@ func (r *RedisStore) saveTxo(ctx context.Context, txo *idx.Txo, height uint32, b
 
 	if mp, err := msgpack.Marshal(txo); err != nil {
 		log.Println("Marshal Txo", err)
-		log.Panic(err)
 		return err
-	} else if _, err := r.DB.Pipelined(ctx, func(pipe redis.Pipeliner) (err error) {
-		if err := pipe.HSet(ctx, TxosKey, outpoint, mp).Err(); err != nil {
-			log.Println("HSET Txo", err)
-			log.Panic(err)
+	} else if err := pipe.HSet(ctx, TxosKey, outpoint, mp).Err(); err != nil {
+		log.Println("HSET Txo", err)
+		return err
+	}
+
+	if len(datas) > 0 {
+		if err := pipe.HSet(ctx, TxoDataKey(outpoint), datas).Err(); err != nil {
+			log.Println("HSET TxoData", err)
 			return err
 		}
-
-		if len(datas) > 0 {
-			if err := pipe.HSet(ctx, TxoDataKey(outpoint), datas).Err(); err != nil {
-				log.Panic(err)
-				log.Println("HSET TxoData", err)
-				return err
-			}
+	}
+	for _, event := range txo.Events {
+		if err := pipe.ZAdd(ctx, event, redis.Z{
+			Score:  score,
+			Member: outpoint,
+		}).Err(); err != nil {
+			log.Println("ZADD Event", event, err)
+			return err
 		}
-		for _, event := range txo.Events {
-			if err := pipe.ZAdd(ctx, event, redis.Z{
+		if tag, dep, ok := idx.ParseDependentsKey(event); ok {
+			if err := pipe.ZAdd(ctx, idx.DepsKey(tag, outpoint), redis.Z{
 				Score:  score,
-				Member: outpoint,
+				Member: dep,
 			}).Err(); err != nil {
-				log.Println("ZADD Event", event, err)
-				log.Panic(err)
+				log.Println("ZADD Deps", event, err)
 				return err
 			}
 		}
-		return nil
-	}); err != nil {
-		log.Panic(err)
-		return err
 	}
+	return nil
+}
 
-	for _, event := range txo.Events {
-		evt.Publish(ctx, event, outpoint)
+func (r *RedisStore) SaveSpends(idxCtx *idx.IndexContext) (err error) {
+	if _, err := r.DB.TxPipelined(idxCtx.Ctx, func(pipe redis.Pipeliner) error {
+		return r.saveSpends(idxCtx, pipe)
+	}); err != nil {
+		return err
 	}
 
 	return nil
 }
 
-func (r *RedisStore) SaveSpends(idxCtx *idx.IndexContext) (err error) {
+func (r *RedisStore) saveSpends(idxCtx *idx.IndexContext, pipe redis.Pipeliner) error {
+	if len(idxCtx.Spends) == 0 {
+		return nil
+	}
 	score := idx.HeightScore(idxCtx.Height, idxCtx.Idx)
-	if _, err := r.DB.Pipelined(idxCtx.Ctx, func(pipe redis.Pipeliner) error {
-		spends := make(map[string]string, len(idxCtx.Spends))
-		outpoints := make([]interface{}, 0, len(idxCtx.Spends))
-		owners := make(map[string]struct{}, 10)
-		for _, spend := range idxCtx.Spends {
-			outpoint := spend.Outpoint.String()
-			spends[outpoint] = idxCtx.TxidHex
-			outpoints = append(outpoints, outpoint)
-			for _, owner := range spend.Owners {
-				if owner == "" {
-					continue
-				}
-				owners[owner] = struct{}{}
+	spends := make(map[string]string, len(idxCtx.Spends))
+	outpoints := make([]interface{}, 0, len(idxCtx.Spends))
+	owners := make(map[string]struct{}, 10)
+	for _, spend := range idxCtx.Spends {
+		outpoint := spend.Outpoint.String()
+		spends[outpoint] = idxCtx.TxidHex
+		outpoints = append(outpoints, outpoint)
+		for _, owner := range spend.Owners {
+			if owner == "" {
+				continue
 			}
+			owners[owner] = struct{}{}
 		}
-		if err := pipe.HMSet(idxCtx.Ctx, SpendsKey, spends).Err(); err != nil {
-			return err
-		} else if err := pipe.SAdd(idxCtx.Ctx, InputsKey(idxCtx.TxidHex), outpoints...).Err(); err != nil {
+	}
+	if err := pipe.HMSet(idxCtx.Ctx, SpendsKey, spends).Err(); err != nil {
+		return err
+	} else if err := pipe.SAdd(idxCtx.Ctx, InputsKey(idxCtx.TxidHex), outpoints...).Err(); err != nil {
+		return err
+	}
+
+	for owner := range owners {
+		if err := pipe.ZAdd(idxCtx.Ctx, idx.OwnerKey(owner), redis.Z{
+			Score:  score,
+			Member: idxCtx.TxidHex,
+		}).Err(); err != nil {
 			return err
 		}
+	}
+	return nil
+}
 
-		for owner, _ := range owners {
-			if err := pipe.ZAdd(idxCtx.Ctx, idx.OwnerKey(owner), redis.Z{
-				Score:  score,
+func (r *RedisStore) Commit(idxCtx *idx.IndexContext, logKeys ...string) error {
+	journal := idx.IncrKey(idxCtx.TxidHex)
+	if err := r.transactJournal(idxCtx.Ctx, idxCtx.TxidHex, func(pipe redis.Pipeliner, prev []*idx.Log) error {
+		if err := r.saveTxos(idxCtx, pipe); err != nil {
+			return err
+		} else if err := r.saveSpends(idxCtx, pipe); err != nil {
+			return err
+		} else if err := applyIncrs(idxCtx.Ctx, pipe, idx.NetIncrs(prev, idxCtx.Incrs)); err != nil {
+			return err
+		} else if err := pipe.Del(idxCtx.Ctx, journal).Err(); err != nil {
+			return err
+		}
+		for _, incr := range idx.MergeIncrs(idxCtx.Incrs) {
+			if err := pipe.ZAdd(idxCtx.Ctx, journal, redis.Z{
+				Score:  incr.Delta,
+				Member: incr.JournalMember(),
+			}).Err(); err != nil {
+				return err
+			}
+		}
+		if err := applyWrites(idxCtx.Ctx, pipe, idxCtx.TxidHex, idxCtx.Writes); err != nil {
+			return err
+		}
+		for _, key := range logKeys {
+			if err := pipe.ZAdd(idxCtx.Ctx, key, redis.Z{
+				Score:  idxCtx.Score,
 				Member: idxCtx.TxidHex,
 			}).Err(); err != nil {
 				return err
@@ -247,10 +315,9 @@ func (r *RedisStore) SaveSpends(idxCtx *idx.IndexContext) (err error) {
 		}
 		return nil
 	}); err != nil {
-		log.Panic(err)
 		return err
 	}
-
+	r.publishTxos(idxCtx)
 	return nil
 }
 
@@ -320,7 +387,7 @@ func (r *RedisStore) GetSpend(ctx context.Context, outpoint string, refresh bool
 	if spend, err = r.DB.HGet(ctx, SpendsKey, outpoint).Result(); err != nil && err != redis.Nil {
 		return "", err
 	} else if spend == "" && refresh {
-		if spend, err = jb.GetSpend(outpoint); err != nil {
+		if spend, err = jb.GetSpendFrom(ctx, r.source(), outpoint); err != nil {
 			return
 		} else if spend != "" {
 			if _, err = r.SetNewSpend(ctx, outpoint, spend); err != nil {
@@ -342,7 +409,7 @@ func (r *RedisStore) GetSpends(ctx context.Context, outpoints []string, refresh
 			if record != nil {
 				spend = record.(string)
 			} else if refresh {
-				if spend, err = jb.GetSpend(outpoint); err != nil {
+				if spend, err = jb.GetSpendFrom(ctx, r.source(), outpoint); err != nil {
 					return nil, err
 				} else if spend != "" {
 					if _, err = r.SetNewSpend(ctx, outpoint, spend); err != nil {
@@ -364,12 +431,115 @@ func (r *RedisStore) UnsetSpends(ctx context.Context, outpoints []string) error
 	return r.DB.HDel(ctx, SpendsKey, outpoints...).Err()
 }
 
+// journal loads the increments committed for a transaction
+// journalRetries bounds how often a commit or rollback is retried when the journal of its
+// txid changes underneath it
+const journalRetries = 10
+
+// transactJournal runs fn in a MULTI with the increments journaled for txid, watching the
+// journal so a concurrent commit or rollback of the same txid is retried against its result
+func (r *RedisStore) transactJournal(ctx context.Context, txid string, fn func(pipe redis.Pipeliner, prev []*idx.Log) error) (err error) {
+	journal := idx.IncrKey(txid)
+	for range journalRetries {
+		if err = r.DB.Watch(ctx, func(tx *redis.Tx) error {
+			if prev, err := loadJournal(ctx, tx, journal); err != nil {
+				return err
+			} else {
+				_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+					return fn(pipe, prev)
+				})
+				return err
+			}
+		}, journal); err != redis.TxFailedErr {
+			return err
+		}
+	}
+	return err
+}
+
+func loadJournal(ctx context.Context, db redis.Cmdable, key string) ([]*idx.Log, error) {
+	if zs, err := db.ZRangeWithScores(ctx, key, 0, -1).Result(); err != nil {
+		return nil, err
+	} else {
+		logs := make([]*idx.Log, 0, len(zs))
+		for _, z := range zs {
+			logs = append(logs, &idx.Log{Member: z.Member.(string), Score: z.Score})
+		}
+		return logs, nil
+	}
+}
+
+// applyIncrs moves the scores of each increment, removing members which fall to zero or below
+func applyIncrs(ctx context.Context, pipe redis.Pipeliner, incrs []*idx.Incr) error {
+	keys := make(map[string]struct{}, len(incrs))
+	for _, incr := range incrs {
+		if err := pipe.ZIncrBy(ctx, incr.Key, incr.Delta, incr.Member).Err(); err != nil {
+			return err
+		}
+		keys[incr.Key] = struct{}{}
+	}
+	for key := range keys {
+		if err := pipe.ZRemRangeByScore(ctx, key, "-inf", "0").Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeScript restores the entries journaled in KEYS[1] by a previous commit, then
+// applies the writes in ARGV, as groups of key, member, score and delete flag, and
+// journals the entries they replace. Journal members are encoded as by idx.JournalWrite.
+var writeScript = redis.NewScript(`
+local prev = redis.call('ZRANGE', KEYS[1], 0, -1, 'WITHSCORES')
+for i = 1, #prev, 2 do
+	local entry = prev[i]
+	local sep = string.find(entry, '|', 2, true)
+	local key = string.sub(entry, 2, sep - 1)
+	local member = string.sub(entry, sep + 1)
+	if string.sub(entry, 1, 1) == '+' then
+		redis.call('ZADD', key, prev[i + 1], member)
+	else
+		redis.call('ZREM', key, member)
+	end
+end
+redis.call('DEL', KEYS[1])
+for i = 1, #ARGV, 4 do
+	local key, member = ARGV[i], ARGV[i + 1]
+	local score = redis.call('ZSCORE', key, member)
+	if score then
+		redis.call('ZADD', KEYS[1], score, '+' .. key .. '|' .. member)
+	else
+		redis.call('ZADD', KEYS[1], 0, '-' .. key .. '|' .. member)
+	end
+	if ARGV[i + 3] == '1' then
+		redis.call('ZREM', key, member)
+	else
+		redis.call('ZADD', key, ARGV[i + 2], member)
+	end
+end
+return 0
+`)
+
+// applyWrites queues writeScript for the writes committed with txid
+func applyWrites(ctx context.Context, pipe redis.Pipeliner, txid string, writes []*idx.Write) error {
+	merged := idx.MergeWrites(writes)
+	args := make([]interface{}, 0, 4*len(merged))
+	for _, w := range merged {
+		del := "0"
+		if w.Delete {
+			del = "1"
+		}
+		args = append(args, w.Key, w.Member, strconv.FormatFloat(w.Score, 'f', -1, 64), del)
+	}
+	return writeScript.Eval(ctx, pipe, []string{idx.WriteKey(txid)}, args...).Err()
+}
+
 func (r *RedisStore) Rollback(ctx context.Context, txid string) error {
 	if outpoints, err := r.DB.SMembers(ctx, InputsKey(txid)).Result(); err != nil {
-		log.Panic(err)
+		log.Println("Rollback", txid, err)
 		return err
 	} else if txos, err := r.LoadTxosByTxid(ctx, txid, nil, false, false); err != nil {
-		log.Panic(err)
+		log.Println("Rollback", txid, err)
 		return err
 	} else {
 		deletes := make([]string, 0, len(outpoints))
@@ -384,13 +554,43 @@ func (r *RedisStore) Rollback(ctx context.Context, txid string) error {
 		}
 		if len(deletes) > 0 {
 			if err := r.DB.HDel(ctx, SpendsKey, deletes...).Err(); err != nil {
-				log.Panic(err)
+				log.Println("Rollback", txid, err)
+				return err
+			} else if spends, err := r.LoadTxos(ctx, deletes, nil, false, false); err != nil {
+				log.Println("Rollback", txid, err)
+				return err
+			} else {
+				for _, spend := range spends {
+					if spend == nil {
+						continue
+					}
+					for _, owner := range spend.Owners {
+						if owner == "" {
+							continue
+						} else if err := r.DB.ZRem(ctx, idx.OwnerKey(owner), txid).Err(); err != nil {
+							log.Println("Rollback", txid, err)
+							return err
+						}
+					}
+				}
+			}
+		}
+		if err := r.DB.Del(ctx, InputsKey(txid)).Err(); err != nil {
+			log.Println("Rollback", txid, err)
+			return err
+		} else if err := r.transactJournal(ctx, txid, func(pipe redis.Pipeliner, prev []*idx.Log) error {
+			if err := applyIncrs(ctx, pipe, idx.NetIncrs(prev, nil)); err != nil {
+				return err
+			} else if err := applyWrites(ctx, pipe, txid, nil); err != nil {
 				return err
 			}
+			return pipe.Del(ctx, idx.IncrKey(txid)).Err()
+		}); err != nil {
+			return err
 		}
 		for _, txo := range txos {
 			if err = r.RollbackTxo(ctx, txo); err != nil {
-				log.Panic(err)
+				log.Println("Rollback", txid, err)
 				return err
 			}
 		}
@@ -407,22 +607,17 @@ func (r *RedisStore) RollbackTxo(ctx context.Context, txo *idx.Txo) error {
 			}
 			if err := pipe.ZRem(ctx, idx.OwnerKey(owner), outpoint).Err(); err != nil {
 				log.Println("ZRem Owner", err)
-				log.Panic(err)
 				return err
 			}
 		}
-		for tag, data := range txo.Data {
-			tagKey := evt.TagKey(tag)
-			if err := pipe.ZRem(ctx, tagKey, outpoint).Err(); err != nil {
-				log.Println("ZRem Tag", tagKey, err)
-				log.Panic(err)
+		for _, event := range txo.Events {
+			if err := pipe.ZRem(ctx, event, outpoint).Err(); err != nil {
+				log.Println("ZRem Event", event, err)
 				return err
 			}
-			for _, event := range data.Events {
-				eventKey := evt.EventKey(tag, event)
-				if err := pipe.ZRem(ctx, eventKey, outpoint).Err(); err != nil {
-					log.Panic(err)
-					log.Println("ZADD Event", eventKey, err)
+			if tag, dep, ok := idx.ParseDependentsKey(event); ok {
+				if err := pipe.ZRem(ctx, idx.DepsKey(tag, outpoint), dep).Err(); err != nil {
+					log.Println("ZRem Deps", event, err)
 					return err
 				}
 			}
@@ -430,16 +625,14 @@ func (r *RedisStore) RollbackTxo(ctx context.Context, txo *idx.Txo) error {
 
 		if err := pipe.HDel(ctx, TxosKey, outpoint).Err(); err != nil {
 			log.Println("HDEL Txo", err)
-			log.Panic(err)
 			return err
 		} else if err := pipe.Del(ctx, TxoDataKey(outpoint)).Err(); err != nil {
 			log.Println("HDEL TxoData", err)
-			log.Panic(err)
 			return err
 		}
 		return nil
 	}); err != nil {
-		log.Panic(err)
+		log.Println("RollbackTxo", outpoint, err)
 		return err
 	}
 	return nil
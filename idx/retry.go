@@ -0,0 +1,82 @@
+package idx
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// RetryPolicy controls how often a queued transaction is retried after a failed
+// ingest before it is moved to the dead-letter log.
+type RetryPolicy struct {
+	MaxAttempts uint32
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 8,
+	BaseDelay:   5 * time.Second,
+	MaxDelay:    time.Hour,
+}
+
+// Backoff returns the delay before the given attempt, doubling from BaseDelay up to MaxDelay
+func (p *RetryPolicy) Backoff(attempt uint32) time.Duration {
+	delay := p.BaseDelay
+	for i := uint32(1); i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, p.MaxDelay)
+}
+
+func (cfg *IngestCtx) retryPolicy() *RetryPolicy {
+	if cfg.RetryPolicy != nil {
+		return cfg.RetryPolicy
+	}
+	return &DefaultRetryPolicy
+}
+
+// Retry records a failed ingest of txid. Transient failures are pushed back into
+// the queue with an exponential backoff. Permanent failures, and transactions which
+// have exhausted their attempts, are moved to the dead-letter log.
+func (cfg *IngestCtx) Retry(ctx context.Context, txid string, ingestErr error) error {
+	return cfg.RetryIn(ctx, cfg.Key, txid, ingestErr)
+}
+
+// RetryIn is Retry for transactions queued under queueKey rather than the ingest Key
+func (cfg *IngestCtx) RetryIn(ctx context.Context, queueKey string, txid string, ingestErr error) error {
+	policy := cfg.retryPolicy()
+	attempts, err := cfg.Store.LogScore(ctx, AttemptsKey(cfg.Tag), txid)
+	if err != nil {
+		return err
+	}
+	attempt := uint32(attempts) + 1
+	if IsPermanent(ingestErr) || attempt >= policy.MaxAttempts {
+		log.Printf("[QUEUE] Dead-lettering %s after %d attempts: %v", txid, attempt, ingestErr)
+		if err := cfg.Store.Log(ctx, AttemptsKey(cfg.Tag), txid, float64(attempt)); err != nil {
+			return err
+		} else if err := cfg.Store.Log(ctx, DeadLetterKey(cfg.Tag), txid, float64(time.Now().UnixNano())); err != nil {
+			return err
+		}
+		return cfg.Store.Delog(ctx, queueKey, txid)
+	}
+
+	delay := policy.Backoff(attempt)
+	if cfg.Verbose {
+		log.Printf("[QUEUE] Retrying %s in %s, attempt %d: %v", txid, delay, attempt, ingestErr)
+	}
+	if err := cfg.Store.Log(ctx, AttemptsKey(cfg.Tag), txid, float64(attempt)); err != nil {
+		return err
+	}
+	return cfg.Store.Log(ctx, queueKey, txid, float64(time.Now().Add(delay).UnixNano()))
+}
+
+// Requeue moves a dead-lettered txid back onto queueKey and resets its attempts
+func Requeue(ctx context.Context, store TxoStore, tag string, queueKey string, txid string) error {
+	if err := store.Log(ctx, queueKey, txid, float64(time.Now().UnixNano())); err != nil {
+		return err
+	} else if err := store.Delog(ctx, AttemptsKey(tag), txid); err != nil {
+		return err
+	}
+	return store.Delog(ctx, DeadLetterKey(tag), txid)
+}
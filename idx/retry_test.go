@@ -0,0 +1,66 @@
+package idx_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/shruggr/1sat-indexer/v5/idx"
+	memstore "github.com/shruggr/1sat-indexer/v5/idx/mem-store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoff(t *testing.T) {
+	policy := &idx.RetryPolicy{MaxAttempts: 10, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+	assert.Equal(t, time.Second, policy.Backoff(1))
+	assert.Equal(t, 2*time.Second, policy.Backoff(2))
+	assert.Equal(t, 8*time.Second, policy.Backoff(4))
+	assert.Equal(t, 10*time.Second, policy.Backoff(5))
+	assert.Equal(t, 10*time.Second, policy.Backoff(100))
+}
+
+func TestRetry(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.NewMemStore()
+	cfg := &idx.IngestCtx{
+		Tag:         "test",
+		Key:         idx.QueueKey("test"),
+		Store:       store,
+		RetryPolicy: &idx.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Minute, MaxDelay: time.Hour},
+	}
+	require.NoError(t, store.Log(ctx, cfg.Key, "tx", 1))
+
+	// transient failures push the txid into the future
+	before := float64(time.Now().UnixNano())
+	require.NoError(t, cfg.Retry(ctx, "tx", errors.New("timeout")))
+	score, _ := store.LogScore(ctx, cfg.Key, "tx")
+	assert.Greater(t, score, before+float64(59*time.Second))
+	attempts, _ := store.LogScore(ctx, idx.AttemptsKey(cfg.Tag), "tx")
+	assert.Equal(t, float64(1), attempts)
+
+	require.NoError(t, cfg.Retry(ctx, "tx", errors.New("timeout")))
+	next, _ := store.LogScore(ctx, cfg.Key, "tx")
+	assert.Greater(t, next, score)
+
+	// the retry budget is exhausted on the third attempt
+	require.NoError(t, cfg.Retry(ctx, "tx", errors.New("timeout")))
+	score, _ = store.LogScore(ctx, cfg.Key, "tx")
+	assert.Zero(t, score)
+	score, _ = store.LogScore(ctx, idx.DeadLetterKey(cfg.Tag), "tx")
+	assert.NotZero(t, score)
+
+	require.NoError(t, idx.Requeue(ctx, store, cfg.Tag, cfg.Key, "tx"))
+	score, _ = store.LogScore(ctx, cfg.Key, "tx")
+	assert.NotZero(t, score)
+	score, _ = store.LogScore(ctx, idx.DeadLetterKey(cfg.Tag), "tx")
+	assert.Zero(t, score)
+	attempts, _ = store.LogScore(ctx, idx.AttemptsKey(cfg.Tag), "tx")
+	assert.Zero(t, attempts)
+
+	// permanent failures are dead-lettered immediately
+	require.NoError(t, cfg.Retry(ctx, "tx", idx.NewIngestError("tx", idx.ErrMalformedData, errors.New("bad"))))
+	score, _ = store.LogScore(ctx, idx.DeadLetterKey(cfg.Tag), "tx")
+	assert.NotZero(t, score)
+}
@@ -11,7 +11,7 @@ func (s *SQLiteStore) AcctsByOwners(ctx context.Context, owners []string) ([]str
 	if len(owners) == 0 {
 		return nil, nil
 	}
-	query := `SELECT account FROM owner_accounts WHERE owner IN (` + placeholders(len(owners)) + `)`
+	query := `SELECT DISTINCT account FROM owner_accounts WHERE owner IN (` + placeholders(len(owners)) + `)`
 	rows, err := s.READDB.QueryContext(ctx, query, toInterfaceSlice(owners)...)
 	if err != nil {
 		log.Panic(err)
@@ -15,7 +15,7 @@ import (
 func (s *SQLiteStore) Search(ctx context.Context, cfg *idx.SearchCfg) (results []*idx.Log, err error) {
 	var sqlBuilder strings.Builder
 	args := make([]interface{}, 0, 3)
-	if cfg.ComparisonType == idx.ComparisonAND && len(cfg.Keys) > 1 {
+	if len(cfg.Keys) > 1 {
 		sqlBuilder.WriteString(`SELECT logs.member, min(logs.score) as score FROM logs `)
 	} else {
 		sqlBuilder.WriteString(`SELECT logs.member, logs.score FROM logs `)
@@ -31,37 +31,43 @@ func (s *SQLiteStore) Search(ctx context.Context, cfg *idx.SearchCfg) (results [
 		args = append(args, toInterfaceSlice(cfg.Keys)...)
 		sqlBuilder.WriteString(`WHERE search_key IN (` + placeholders(len(cfg.Keys)) + `) `)
 	}
-	if cfg.From != nil {
-		args = append(args, *cfg.From)
+	if cfg.OutpointsOnly {
+		sqlBuilder.WriteString("AND length(logs.member) > 64 ")
+	}
+	from, to := cfg.Range()
+	if from != nil {
+		args = append(args, *from)
 		if cfg.Reverse {
-			sqlBuilder.WriteString("AND score < ? ")
+			sqlBuilder.WriteString("AND logs.score < ? ")
 		} else {
-			sqlBuilder.WriteString("AND score > ? ")
+			sqlBuilder.WriteString("AND logs.score > ? ")
 		}
 	}
 
-	if cfg.To != nil {
-		args = append(args, *cfg.To)
+	if to != nil {
+		args = append(args, *to)
 		if cfg.Reverse {
-			sqlBuilder.WriteString("AND score > ? ")
+			sqlBuilder.WriteString("AND logs.score > ? ")
 		} else {
-			sqlBuilder.WriteString("AND score < ? ")
+			sqlBuilder.WriteString("AND logs.score < ? ")
 		}
 	}
 
-	if cfg.ComparisonType == idx.ComparisonAND && len(cfg.Keys) > 1 {
-		args = append(args, len(cfg.Keys))
+	if len(cfg.Keys) > 1 {
 		sqlBuilder.WriteString("GROUP BY logs.member ")
-		sqlBuilder.WriteString("HAVING COUNT(1) = ? ")
+		if cfg.ComparisonType == idx.ComparisonAND {
+			args = append(args, len(cfg.Keys))
+			sqlBuilder.WriteString("HAVING COUNT(1) = ? ")
+		}
 	}
 
 	if cfg.Reverse {
-		sqlBuilder.WriteString("ORDER BY score DESC ")
+		sqlBuilder.WriteString("ORDER BY score DESC, member DESC ")
 	} else {
-		sqlBuilder.WriteString("ORDER BY score ASC ")
+		sqlBuilder.WriteString("ORDER BY score ASC, member ASC ")
 	}
 
-	if cfg.Limit > 0 {
+	if cfg.Limit > 0 && !(cfg.FilterSpent && cfg.RefreshSpends) {
 		args = append(args, cfg.Limit)
 		sqlBuilder.WriteString("LIMIT ? ")
 	}
@@ -82,22 +88,33 @@ func (s *SQLiteStore) Search(ctx context.Context, cfg *idx.SearchCfg) (results [
 		log.Println("Query time", time.Since(start))
 	}
 	results = make([]*idx.Log, 0, cfg.Limit)
+	pending := make([][2]string, 0)
 	for rows.Next() {
 		var result idx.Log
 		if err = rows.Scan(&result.Member, &result.Score); err != nil {
 			return nil, err
 		}
-		if cfg.RefreshSpends {
-			log.Println("Refreshing spends", result.Member)
-			if spend, err := jb.GetSpend(result.Member); err != nil {
+		if cfg.FilterSpent && cfg.RefreshSpends {
+			if cfg.Limit > 0 && len(results) >= int(cfg.Limit) {
+				break
+			} else if spend, err := jb.GetSpendFrom(ctx, s.source(), result.Member); err != nil {
 				return nil, err
 			} else if spend != "" {
-				s.SetNewSpend(ctx, result.Member, spend)
+				if cfg.Verbose {
+					log.Println("Spent from JB", result.Member, spend)
+				}
+				pending = append(pending, [2]string{result.Member, spend})
 				continue
 			}
 		}
 		results = append(results, &result)
 	}
+	rows.Close()
+	for _, p := range pending {
+		if _, err := s.SetNewSpend(ctx, p[0], p[1]); err != nil {
+			return nil, err
+		}
+	}
 	if cfg.Verbose {
 		log.Println("Results", len(results))
 	}
@@ -117,18 +134,8 @@ func (s *SQLiteStore) SearchMembers(ctx context.Context, cfg *idx.SearchCfg) (re
 }
 
 func (s *SQLiteStore) SearchOutpoints(ctx context.Context, cfg *idx.SearchCfg) (results []string, err error) {
-	items, err := s.Search(ctx, cfg)
-	if err != nil {
-		return nil, err
-	}
-	outpoints := make([]string, 0, len(items))
-	for _, item := range items {
-		if len(item.Member) < 65 {
-			continue
-		}
-		outpoints = append(outpoints, item.Member)
-	}
-	return outpoints, nil
+	cfg.OutpointsOnly = true
+	return s.SearchMembers(ctx, cfg)
 }
 
 func (s *SQLiteStore) SearchTxos(ctx context.Context, cfg *idx.SearchCfg) (txos []*idx.Txo, err error) {
@@ -162,6 +169,11 @@ func (s *SQLiteStore) SearchTxos(ctx context.Context, cfg *idx.SearchCfg) (txos
 			if txo.Data == nil {
 				txo.Data = make(idx.IndexDataMap)
 			}
+			if cfg.IncludeSpend {
+				if txo.Spend, err = s.GetSpend(ctx, result.Member, cfg.RefreshSpends); err != nil {
+					return nil, err
+				}
+			}
 			if cfg.IncludeScript {
 				if err := txo.LoadScript(ctx); err != nil {
 					return nil, err
@@ -185,7 +197,7 @@ func (s *SQLiteStore) SearchBalance(ctx context.Context, cfg *idx.SearchCfg) (ba
 		return 0, err
 	}
 	for _, txo := range txos {
-		if txo.Satoshis != nil {
+		if txo != nil && txo.Satoshis != nil {
 			balance += *txo.Satoshis
 		}
 	}
@@ -258,7 +270,7 @@ func (s *SQLiteStore) Balance(ctx context.Context, key string) (balance int64, e
 func (s *SQLiteStore) CountMembers(ctx context.Context, key string) (count uint64, err error) {
 	row := s.READDB.QueryRowContext(ctx, `SELECT COUNT(1)
         FROM logs
-        WHERE key = ?`,
+        WHERE search_key = ?`,
 		key,
 	)
 	if err = row.Scan(&count); err != nil {
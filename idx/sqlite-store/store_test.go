@@ -0,0 +1,38 @@
+package sqlitestore_test
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/shruggr/1sat-indexer/v5/idx"
+	sqlitestore "github.com/shruggr/1sat-indexer/v5/idx/sqlite-store"
+	"github.com/shruggr/1sat-indexer/v5/idxtest"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	migration, err := os.ReadFile("../../migration/sqlite/1_blockchain.up.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	idxtest.TestStore(t, func(t *testing.T) idx.TxoStore {
+		dbPath := filepath.Join(t.TempDir(), "test.db")
+		if db, err := sql.Open("sqlite3", dbPath); err != nil {
+			t.Fatal(err)
+		} else if _, err := db.Exec(string(migration)); err != nil {
+			t.Fatal(err)
+		} else {
+			db.Close()
+		}
+		store, err := sqlitestore.NewSQLiteStore(dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			store.READDB.Close()
+			store.WRITEDB.Close()
+		})
+		return store
+	})
+}
@@ -17,6 +17,15 @@ import (
 type SQLiteStore struct {
 	WRITEDB *sql.DB
 	READDB  *sql.DB
+	// Source is consulted for spends when a refresh is requested. Defaults to jb.Source.
+	Source jb.TxSource
+}
+
+func (s *SQLiteStore) source() jb.TxSource {
+	if s.Source != nil {
+		return s.Source
+	}
+	return jb.Source
 }
 
 var getTxo *sql.Stmt
@@ -27,6 +36,7 @@ var insData *sql.Stmt
 var putLogOnce *sql.Stmt
 var getLogScore *sql.Stmt
 var setSpend *sql.Stmt
+var setNewSpend *sql.Stmt
 var getSpend *sql.Stmt
 var insOwnerAcct *sql.Stmt
 
@@ -56,7 +66,7 @@ func NewSQLiteStore(connString string) (*SQLiteStore, error) {
 		return nil, err
 	}
 
-	if getTxo, err = readDb.Prepare(`SELECT outpoint, height, idx, satoshis, spend
+	if getTxo, err = readDb.Prepare(`SELECT outpoint, height, idx, satoshis, owners, spend
         FROM txos WHERE outpoint = ? AND satoshis IS NOT NULL`); err != nil {
 		log.Panic(err)
 		return nil, err
@@ -78,7 +88,7 @@ func NewSQLiteStore(connString string) (*SQLiteStore, error) {
 		return nil, err
 	} else if getTxosByTxid, err = readDb.Prepare(`SELECT outpoint
 		FROM txos
-		WHERE outpoint LIKE ?`); err != nil {
+		WHERE outpoint LIKE ? AND satoshis IS NOT NULL`); err != nil {
 		log.Panic(err)
 		return nil, err
 	} else if putLogOnce, err = writeDb.Prepare(`INSERT INTO logs(search_key, member, score)
@@ -95,6 +105,12 @@ func NewSQLiteStore(connString string) (*SQLiteStore, error) {
 		ON CONFLICT (outpoint) DO UPDATE SET spend = ?`); err != nil {
 		log.Panic(err)
 		return nil, err
+	} else if setNewSpend, err = writeDb.Prepare(`INSERT INTO txos(outpoint, spend)
+		VALUES (?, ?)
+		ON CONFLICT (outpoint) DO UPDATE SET spend = ?
+		WHERE txos.spend = ''`); err != nil {
+		log.Panic(err)
+		return nil, err
 	} else if getSpend, err = readDb.Prepare(`SELECT spend FROM txos
 		WHERE outpoint = ?`); err != nil {
 		log.Panic(err)
@@ -116,11 +132,11 @@ func (s *SQLiteStore) LoadTxo(ctx context.Context, outpoint string, tags []strin
 	// )
 	txo := &idx.Txo{}
 	var sats sql.NullInt64
+	var owners sql.NullString
 	var spendTxid string
-	if err := row.Scan(&txo.Outpoint, &txo.Height, &txo.Idx, &sats, &spendTxid); err == sql.ErrNoRows {
+	if err := row.Scan(&txo.Outpoint, &txo.Height, &txo.Idx, &sats, &owners, &spendTxid); err == sql.ErrNoRows {
 		return nil, nil
 	} else if err != nil {
-		log.Panic(err)
 		return nil, err
 	} else {
 		if spend {
@@ -130,9 +146,13 @@ func (s *SQLiteStore) LoadTxo(ctx context.Context, outpoint string, tags []strin
 			satoshis := uint64(sats.Int64)
 			txo.Satoshis = &satoshis
 		}
+		if owners.Valid {
+			if err = json.Unmarshal([]byte(owners.String), &txo.Owners); err != nil {
+				return nil, err
+			}
+		}
 		txo.Score = idx.HeightScore(txo.Height, txo.Idx)
 		if txo.Data, err = s.LoadData(ctx, txo.Outpoint.String(), tags); err != nil {
-			log.Panic(err)
 			return nil, err
 		} else if txo.Data == nil {
 			txo.Data = make(idx.IndexDataMap)
@@ -152,7 +172,7 @@ func (s *SQLiteStore) LoadTxos(ctx context.Context, outpoints []string, tags []s
 	}
 	rows, err := s.READDB.QueryContext(ctx, `SELECT outpoint, height, idx, satoshis, owners, spend
         FROM txos 
-        WHERE outpoint IN (`+placeholders(len(outpoints))+`)`,
+        WHERE outpoint IN (`+placeholders(len(outpoints))+`) AND satoshis IS NOT NULL`,
 		toInterfaceSlice(outpoints)...,
 	)
 	if err != nil {
@@ -160,19 +180,21 @@ func (s *SQLiteStore) LoadTxos(ctx context.Context, outpoints []string, tags []s
 		return nil, err
 	}
 	defer rows.Close()
-	txos := make([]*idx.Txo, 0, len(outpoints))
+	txoMap := make(map[string]*idx.Txo, len(outpoints))
 	for rows.Next() {
 		txo := &idx.Txo{
 			Data: make(idx.IndexDataMap),
 		}
 		var spendTxid string
-		var owners string
+		var owners sql.NullString
 		if err = rows.Scan(&txo.Outpoint, &txo.Height, &txo.Idx, &txo.Satoshis, &owners, &spendTxid); err != nil {
 			log.Panic(err)
 			return nil, err
-		} else if err = json.Unmarshal([]byte(owners), &txo.Owners); err != nil {
-			log.Panic(err)
-			return nil, err
+		} else if owners.Valid {
+			if err = json.Unmarshal([]byte(owners.String), &txo.Owners); err != nil {
+				log.Panic(err)
+				return nil, err
+			}
 		}
 		if spend {
 			txo.Spend = spendTxid
@@ -189,7 +211,11 @@ func (s *SQLiteStore) LoadTxos(ctx context.Context, outpoints []string, tags []s
 				return nil, err
 			}
 		}
-		txos = append(txos, txo)
+		txoMap[txo.Outpoint.String()] = txo
+	}
+	txos := make([]*idx.Txo, 0, len(outpoints))
+	for _, outpoint := range outpoints {
+		txos = append(txos, txoMap[outpoint])
 	}
 	return txos, nil
 }
@@ -208,6 +234,7 @@ func (s *SQLiteStore) LoadTxosByTxid(ctx context.Context, txid string, tags []st
 			log.Panic(err)
 			return nil, err
 		}
+		outpoints = append(outpoints, outpoint)
 	}
 	return s.LoadTxos(ctx, outpoints, tags, script, spend)
 }
@@ -224,7 +251,6 @@ func (s *SQLiteStore) LoadData(ctx context.Context, outpoint string, tags []stri
         WHERE outpoint=? AND tag IN (`+placeholders(len(tags))+`)`,
 		args...,
 	); err != nil {
-		log.Panic(err)
 		return nil, err
 	} else {
 		defer rows.Close()
@@ -232,7 +258,6 @@ func (s *SQLiteStore) LoadData(ctx context.Context, outpoint string, tags []stri
 			var tag string
 			var dataStr string
 			if err = rows.Scan(&tag, &dataStr); err != nil {
-				log.Panic(err)
 				return nil, err
 			}
 			data[tag] = &idx.IndexData{
@@ -245,34 +270,235 @@ func (s *SQLiteStore) LoadData(ctx context.Context, outpoint string, tags []stri
 
 func (s *SQLiteStore) SaveTxos(idxCtx *idx.IndexContext) (err error) {
 	ctx := idxCtx.Ctx
-	t, err := s.WRITEDB.Begin()
+	t, err := s.WRITEDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
 	defer t.Rollback()
 
-	insTxo, err := t.PrepareContext(ctx, `INSERT INTO txos(outpoint, height, idx, satoshis, owners)
-		VALUES (?, ?, ?, ?, ?)
-		ON CONFLICT (outpoint)
-		DO UPDATE SET height = ?, idx = ?, satoshis = ?, owners = ?`)
+	outpoints, err := s.saveTxos(ctx, t, idxCtx)
 	if err != nil {
-		log.Panic(err)
+		return err
+	} else if err = t.Commit(); err != nil {
+		return err
 	}
-	defer insTxo.Close()
+	for vout, txo := range idxCtx.Txos {
+		for _, event := range txo.Events {
+			evt.Publish(ctx, event, outpoints[vout])
+		}
+	}
+	return nil
+}
 
-	insLog, err := t.PrepareContext(ctx, `INSERT INTO logs(search_key, member, score)
-		VALUES (?, ?, ?)
-		ON CONFLICT (search_key, member) DO UPDATE SET score = ?`)
+func (s *SQLiteStore) SaveSpends(idxCtx *idx.IndexContext) error {
+	ctx := idxCtx.Ctx
+	t, err := s.WRITEDB.BeginTx(ctx, nil)
 	if err != nil {
-		log.Panic(err)
+		return err
+	}
+	defer t.Rollback()
+
+	ownerKeys, err := s.saveSpends(ctx, t, idxCtx)
+	if err != nil {
+		return err
+	} else if err = t.Commit(); err != nil {
+		return err
+	}
+	for _, ownerKey := range ownerKeys {
+		evt.Publish(ctx, ownerKey, idxCtx.TxidHex)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Commit(idxCtx *idx.IndexContext, logKeys ...string) error {
+	ctx := idxCtx.Ctx
+	t, err := s.WRITEDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer t.Rollback()
+
+	outpoints, err := s.saveTxos(ctx, t, idxCtx)
+	if err != nil {
+		return err
 	}
+	ownerKeys, err := s.saveSpends(ctx, t, idxCtx)
+	if err != nil {
+		return err
+	} else if err := saveIncrs(ctx, t, idxCtx.TxidHex, idxCtx.Incrs); err != nil {
+		return err
+	} else if err := saveWrites(ctx, t, idxCtx.TxidHex, idxCtx.Writes); err != nil {
+		return err
+	}
+	insLog := t.StmtContext(ctx, insLog)
 	defer insLog.Close()
+	for _, key := range logKeys {
+		if _, err := insLog.ExecContext(ctx, key, idxCtx.TxidHex, idxCtx.Score, idxCtx.Score); err != nil {
+			log.Println("insert logs Err:", err)
+			return err
+		}
+	}
+	if err := t.Commit(); err != nil {
+		return err
+	}
 
-	insData, err := t.PrepareContext(ctx, `INSERT INTO txo_data(outpoint, tag, data)
-		VALUES (?, ?, ?)
-		ON CONFLICT (outpoint, tag) DO UPDATE SET data = ?`)
+	for vout, txo := range idxCtx.Txos {
+		for _, event := range txo.Events {
+			evt.Publish(ctx, event, outpoints[vout])
+		}
+	}
+	for _, ownerKey := range ownerKeys {
+		evt.Publish(ctx, ownerKey, idxCtx.TxidHex)
+	}
+	return nil
+}
+
+// saveIncrs applies incrs in place of the increments journaled by a previous
+// commit of txid, then journals incrs
+func saveIncrs(ctx context.Context, t *sql.Tx, txid string, incrs []*idx.Incr) error {
+	journal := idx.IncrKey(txid)
+	prev := make([]*idx.Log, 0)
+	if rows, err := t.QueryContext(ctx, `SELECT member, score FROM logs
+        WHERE search_key = ?`,
+		journal,
+	); err != nil {
+		return err
+	} else {
+		defer rows.Close()
+		for rows.Next() {
+			l := &idx.Log{}
+			if err := rows.Scan(&l.Member, &l.Score); err != nil {
+				return err
+			}
+			prev = append(prev, l)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	if err := applyIncrs(ctx, t, idx.NetIncrs(prev, incrs)); err != nil {
+		return err
+	} else if _, err := t.ExecContext(ctx, `DELETE FROM logs WHERE search_key = ?`, journal); err != nil {
+		return err
+	}
+	for _, incr := range idx.MergeIncrs(incrs) {
+		if _, err := t.ExecContext(ctx, `INSERT INTO logs(search_key, member, score)
+            VALUES (?, ?, ?)`,
+			journal,
+			incr.JournalMember(),
+			incr.Delta,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// saveWrites restores the entries journaled by a previous commit of txid, then
+// applies writes and journals the entries they replace
+func saveWrites(ctx context.Context, t *sql.Tx, txid string, writes []*idx.Write) error {
+	journal := idx.WriteKey(txid)
+	prev := make([]*idx.Log, 0)
+	if rows, err := t.QueryContext(ctx, `SELECT member, score FROM logs
+        WHERE search_key = ?`,
+		journal,
+	); err != nil {
+		return err
+	} else {
+		defer rows.Close()
+		for rows.Next() {
+			l := &idx.Log{}
+			if err := rows.Scan(&l.Member, &l.Score); err != nil {
+				return err
+			}
+			prev = append(prev, l)
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	apply, entries, err := idx.PlanWrites(prev, writes, func(key string, member string) (*float64, error) {
+		var score float64
+		if err := t.QueryRowContext(ctx, `SELECT score FROM logs
+            WHERE search_key = ? AND member = ?`,
+			key,
+			member,
+		).Scan(&score); err == sql.ErrNoRows {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+		return &score, nil
+	})
 	if err != nil {
-		log.Panic(err)
+		return err
 	}
+	for _, w := range apply {
+		if w.Delete {
+			if _, err := t.ExecContext(ctx, `DELETE FROM logs
+                WHERE search_key = ? AND member = ?`,
+				w.Key,
+				w.Member,
+			); err != nil {
+				return err
+			}
+		} else if _, err := t.ExecContext(ctx, `INSERT INTO logs(search_key, member, score)
+            VALUES (?, ?, ?)
+            ON CONFLICT (search_key, member) DO UPDATE SET score = excluded.score`,
+			w.Key,
+			w.Member,
+			w.Score,
+		); err != nil {
+			return err
+		}
+	}
+	if _, err := t.ExecContext(ctx, `DELETE FROM logs WHERE search_key = ?`, journal); err != nil {
+		return err
+	}
+	for _, l := range entries {
+		if _, err := t.ExecContext(ctx, `INSERT INTO logs(search_key, member, score)
+            VALUES (?, ?, ?)`,
+			journal,
+			l.Member,
+			l.Score,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyIncrs moves the scores of each increment, removing members which fall to zero or below
+func applyIncrs(ctx context.Context, t *sql.Tx, incrs []*idx.Incr) error {
+	for _, incr := range incrs {
+		if _, err := t.ExecContext(ctx, `INSERT INTO logs(search_key, member, score)
+            VALUES (?, ?, ?)
+            ON CONFLICT (search_key, member) DO UPDATE SET score = score + excluded.score`,
+			incr.Key,
+			incr.Member,
+			incr.Delta,
+		); err != nil {
+			return err
+		} else if _, err := t.ExecContext(ctx, `DELETE FROM logs
+            WHERE search_key = ? AND member = ? AND score <= 0`,
+			incr.Key,
+			incr.Member,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SQLiteStore) saveTxos(ctx context.Context, t *sql.Tx, idxCtx *idx.IndexContext) (outpoints []string, err error) {
+	insTxo := t.StmtContext(ctx, insTxo)
+	defer insTxo.Close()
+	insLog := t.StmtContext(ctx, insLog)
+	defer insLog.Close()
+	insData := t.StmtContext(ctx, insData)
 	defer insData.Close()
-	outpoints := make([]string, 0, len(idxCtx.Txos))
+
+	outpoints = make([]string, 0, len(idxCtx.Txos))
 	for _, txo := range idxCtx.Txos {
 		outpoint := txo.Outpoint.String()
 		outpoints = append(outpoints, outpoint)
@@ -286,10 +512,12 @@ func (s *SQLiteStore) SaveTxos(idxCtx *idx.IndexContext) (err error) {
 				for _, event := range data.Events {
 					txo.Events = append(txo.Events, evt.EventKey(tag, event))
 				}
+				for _, dep := range data.Deps {
+					txo.Events = append(txo.Events, idx.DependentsKey(tag, dep.String()))
+				}
 				if data.Data != nil {
 					if datas[tag], err = data.MarshalJSON(); err != nil {
-						log.Panic(err)
-						return err
+						return nil, err
 					}
 				}
 			}
@@ -303,7 +531,7 @@ func (s *SQLiteStore) SaveTxos(idxCtx *idx.IndexContext) (err error) {
 
 		owners, err := json.Marshal(txo.Owners)
 		if err != nil {
-			log.Panic(err)
+			return nil, err
 		}
 		if _, err := insTxo.ExecContext(ctx,
 			outpoint,
@@ -316,8 +544,8 @@ func (s *SQLiteStore) SaveTxos(idxCtx *idx.IndexContext) (err error) {
 			*txo.Satoshis,
 			string(owners),
 		); err != nil {
-			log.Panicln("insert txos Err:", err)
-			return err
+			log.Println("insert txos Err:", err)
+			return nil, err
 		}
 
 		for _, event := range txo.Events {
@@ -327,8 +555,19 @@ func (s *SQLiteStore) SaveTxos(idxCtx *idx.IndexContext) (err error) {
 				score,
 				score,
 			); err != nil {
-				log.Panicln("insert logs Err:", err)
-				return err
+				log.Println("insert logs Err:", err)
+				return nil, err
+			}
+			if tag, dep, ok := idx.ParseDependentsKey(event); ok {
+				if _, err := insLog.ExecContext(ctx,
+					idx.DepsKey(tag, outpoint),
+					dep,
+					score,
+					score,
+				); err != nil {
+					log.Println("insert logs Err:", err)
+					return nil, err
+				}
 			}
 		}
 
@@ -339,65 +578,40 @@ func (s *SQLiteStore) SaveTxos(idxCtx *idx.IndexContext) (err error) {
 				data,
 				data,
 			); err != nil {
-				log.Panicln("insert txo_data Err:", err)
-				return err
+				log.Println("insert txo_data Err:", err)
+				return nil, err
 			}
 		}
 	}
-	if err := t.Commit(); err != nil {
-		log.Panic(err)
-	}
-	for vout, txo := range idxCtx.Txos {
-		for _, event := range txo.Events {
-			evt.Publish(ctx, event, outpoints[vout])
-		}
-	}
-	return nil
+	return outpoints, nil
 }
 
-func (s *SQLiteStore) SaveSpends(idxCtx *idx.IndexContext) error {
+func (s *SQLiteStore) saveSpends(ctx context.Context, t *sql.Tx, idxCtx *idx.IndexContext) (ownerKeys []string, err error) {
+	insLog := t.StmtContext(ctx, insLog)
+	defer insLog.Close()
+	setSpend := t.StmtContext(ctx, setSpend)
+	defer setSpend.Close()
+
 	score := idx.HeightScore(idxCtx.Height, idxCtx.Idx)
-	spends := make(map[string]string, len(idxCtx.Spends))
 	owners := make(map[string]struct{}, 10)
-	ownerKeys := make([]string, 0, 10)
+	ownerKeys = make([]string, 0, 10)
 	for _, spend := range idxCtx.Spends {
 		outpoint := spend.Outpoint.String()
-		spends[outpoint] = idxCtx.TxidHex
 		for _, owner := range spend.Owners {
 			if _, ok := owners[owner]; !ok && owner != "" {
 				owners[owner] = struct{}{}
 				ownerKey := idx.OwnerKey(owner)
 				ownerKeys = append(ownerKeys, ownerKey)
-				if _, err := insLog.ExecContext(idxCtx.Ctx, ownerKey, idxCtx.TxidHex, score, score); err != nil {
-					// if _, err := s.DB.ExecContext(idxCtx.Ctx, `INSERT INTO logs(search_key, member, score)
-					// 	VALUES (?, ?, ?)
-					// 	ON CONFLICT (search_key, member) DO UPDATE SET score = ?`,
-					// 	ownerKey,
-					// 	idxCtx.TxidHex,
-					// 	score,
-					// 	score,
-					// ); err != nil {
-					log.Panic(err)
-					return err
+				if _, err := insLog.ExecContext(ctx, ownerKey, idxCtx.TxidHex, score, score); err != nil {
+					return nil, err
 				}
 			}
 		}
-		if _, err := setSpend.ExecContext(idxCtx.Ctx, outpoint, idxCtx.TxidHex, idxCtx.TxidHex); err != nil {
-			// if _, err := s.DB.ExecContext(idxCtx.Ctx, `INSERT INTO txos(outpoint, spend)
-			// 	VALUES (?, ?)
-			// 	ON CONFLICT (outpoint) DO UPDATE SET spend = ?`,
-			// 	outpoint,
-			// 	idxCtx.TxidHex,
-			// 	idxCtx.TxidHex,
-			// ); err != nil {
-			log.Panic(err)
+		if _, err := setSpend.ExecContext(ctx, outpoint, idxCtx.TxidHex, idxCtx.TxidHex); err != nil {
+			return nil, err
 		}
 	}
-
-	for _, ownerKey := range ownerKeys {
-		evt.Publish(idxCtx.Ctx, ownerKey, idxCtx.TxidHex)
-	}
-	return nil
+	return ownerKeys, nil
 }
 
 func (s *SQLiteStore) RollbackSpend(ctx context.Context, spend *idx.Txo, txid string) error {
@@ -428,7 +642,7 @@ func (s *SQLiteStore) GetSpend(ctx context.Context, outpoint string, refresh boo
 		return spend, err
 	}
 	if spend == "" && refresh {
-		if spend, err = jb.GetSpend(outpoint); err != nil {
+		if spend, err = jb.GetSpendFrom(ctx, s.source(), outpoint); err != nil {
 			return
 		} else if spend != "" {
 			if _, err = s.SetNewSpend(ctx, outpoint, spend); err != nil {
@@ -440,10 +654,13 @@ func (s *SQLiteStore) GetSpend(ctx context.Context, outpoint string, refresh boo
 }
 
 func (s *SQLiteStore) GetSpends(ctx context.Context, outpoints []string, refresh bool) ([]string, error) {
-	spends := make([]string, 0, len(outpoints))
+	if len(outpoints) == 0 {
+		return []string{}, nil
+	}
+	spendMap := make(map[string]string, len(outpoints))
 	if rows, err := s.READDB.QueryContext(ctx, `SELECT outpoint, spend FROM txos 
-        WHERE outpoint IN (?)`,
-		outpoints,
+        WHERE outpoint IN (`+placeholders(len(outpoints))+`)`,
+		toInterfaceSlice(outpoints)...,
 	); err != nil {
 		log.Panic(err)
 		return nil, err
@@ -456,32 +673,29 @@ func (s *SQLiteStore) GetSpends(ctx context.Context, outpoints []string, refresh
 				log.Panic(err)
 				return nil, err
 			}
-			if spend == "" && refresh {
-				if spend, err = jb.GetSpend(outpoint); err != nil {
+			spendMap[outpoint] = spend
+		}
+	}
+	spends := make([]string, 0, len(outpoints))
+	for _, outpoint := range outpoints {
+		spend := spendMap[outpoint]
+		if spend == "" && refresh {
+			var err error
+			if spend, err = jb.GetSpendFrom(ctx, s.source(), outpoint); err != nil {
+				return nil, err
+			} else if spend != "" {
+				if _, err = s.SetNewSpend(ctx, outpoint, spend); err != nil {
 					return nil, err
-				} else if spend != "" {
-					if _, err = s.SetNewSpend(ctx, outpoint, spend); err != nil {
-						return nil, err
-					}
 				}
 			}
-			spends = append(spends, spend)
 		}
+		spends = append(spends, spend)
 	}
 	return spends, nil
 }
 
 func (s *SQLiteStore) SetNewSpend(ctx context.Context, outpoint, txid string) (bool, error) {
-	if result, err := setSpend.ExecContext(ctx, outpoint, txid, txid); err != nil {
-		// if result, err := s.DB.ExecContext(ctx, `INSERT INTO txos(outpoint, spend)
-		//     VALUES (?, ?)
-		//     ON CONFLICT (outpoint) DO UPDATE
-		//         SET spend = ?
-		//         WHERE txos.spend = ''`,
-		// 	outpoint,
-		// 	txid,
-		// 	txid,
-		// ); err != nil {
+	if result, err := setNewSpend.ExecContext(ctx, outpoint, txid, txid); err != nil {
 		log.Panicln("insert Err:", err)
 		return false, err
 	} else if changes, err := result.RowsAffected(); err != nil {
@@ -493,10 +707,13 @@ func (s *SQLiteStore) SetNewSpend(ctx context.Context, outpoint, txid string) (b
 }
 
 func (s *SQLiteStore) UnsetSpends(ctx context.Context, outpoints []string) error {
+	if len(outpoints) == 0 {
+		return nil
+	}
 	if _, err := s.WRITEDB.ExecContext(ctx, `UPDATE txos 
         SET spend = ''
-        WHERE outpoint IN (?)`,
-		outpoints,
+        WHERE outpoint IN (`+placeholders(len(outpoints))+`)`,
+		toInterfaceSlice(outpoints)...,
 	); err != nil {
 		log.Panic(err)
 		return err
@@ -513,7 +730,11 @@ func (s *SQLiteStore) Rollback(ctx context.Context, txid string) error {
 	defer tx.Rollback()
 
 	txidPattern := fmt.Sprintf("%s%%", txid)
-	if _, err = tx.ExecContext(ctx, `UPDATE txos
+	if err = saveIncrs(ctx, tx, txid, nil); err != nil {
+		return err
+	} else if err = saveWrites(ctx, tx, txid, nil); err != nil {
+		return err
+	} else if _, err = tx.ExecContext(ctx, `UPDATE txos
         SET spend = ''
         WHERE spend = ?`,
 		txid,
@@ -526,6 +747,12 @@ func (s *SQLiteStore) Rollback(ctx context.Context, txid string) error {
 	); err != nil {
 		log.Panic(err)
 		return err
+	} else if _, err = tx.ExecContext(ctx, `DELETE FROM logs
+        WHERE search_key LIKE 'dep:%:' || ?`,
+		txidPattern,
+	); err != nil {
+		log.Panic(err)
+		return err
 	} else if _, err = tx.ExecContext(ctx, `DELETE FROM txo_data
         WHERE outpoint LIKE ?`,
 		txidPattern,
@@ -33,6 +33,23 @@ type SearchCfg struct {
 	Verbose        bool
 }
 
+// Range returns the exclusive From and To bounds of the search, narrowed
+// to exclude mined or mempool scores when requested
+func (cfg *SearchCfg) Range() (from *float64, to *float64) {
+	from, to = cfg.From, cfg.To
+	lower, upper := &from, &to
+	if cfg.Reverse {
+		lower, upper = &to, &from
+	}
+	if cfg.ExcludeMempool && (*upper == nil || **upper > MempoolScore) {
+		*upper = &MempoolScore
+	}
+	if cfg.ExcludeMined && (*lower == nil || **lower < MempoolScore) {
+		*lower = &MempoolScore
+	}
+	return
+}
+
 type Log struct {
 	Member string
 	Score  float64
@@ -50,6 +67,9 @@ type TxoStore interface {
 	// RollbackTxo(ctx context.Context, txo *Txo) error
 	SaveTxos(idxCtx *IndexContext) error
 	SaveSpends(idxCtx *IndexContext) error
+	// Commit atomically saves the txos and spends of idxCtx and logs its txid to each of logKeys.
+	// Either all of it is persisted or none of it is.
+	Commit(idxCtx *IndexContext, logKeys ...string) error
 	// SaveSpend(ctx context.Context, spend *Txo, txid string, height uint32, idx uint64) error
 	// RollbackSpend(ctx context.Context, spend *Txo, txid string) error
 	GetSpend(ctx context.Context, outpoint string, refresh bool) (string, error)
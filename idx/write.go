@@ -0,0 +1,109 @@
+package idx
+
+import (
+	"slices"
+	"strings"
+)
+
+// Write logs Member in Key under Score, or removes it when Delete is set, when a
+// transaction is committed. Unlike an Incr the score is written as given, so zero
+// and negative scores are kept.
+type Write struct {
+	Key    string
+	Member string
+	Score  float64
+	Delete bool
+}
+
+// WriteKey journals the entries replaced by the writes committed for txid, so
+// committing the transaction again or rolling it back restores them first
+func WriteKey(txid string) string {
+	return "wrt:" + txid
+}
+
+// Log adds a write logging member in key under score when the transaction is committed
+func (idxCtx *IndexContext) Log(key string, member string, score float64) {
+	idxCtx.Writes = append(idxCtx.Writes, &Write{
+		Key:    key,
+		Member: member,
+		Score:  score,
+	})
+}
+
+// Delog adds a write removing member from key when the transaction is committed
+func (idxCtx *IndexContext) Delog(key string, member string) {
+	idxCtx.Writes = append(idxCtx.Writes, &Write{
+		Key:    key,
+		Member: member,
+		Delete: true,
+	})
+}
+
+// JournalMember identifies the key and member of a write
+func (w *Write) JournalMember() string {
+	return w.Key + "|" + w.Member
+}
+
+// JournalWrite records the entry a write replaced in WriteKey: its score, or that
+// the member was absent when prev is nil
+func JournalWrite(w *Write, prev *float64) *Log {
+	if prev == nil {
+		return &Log{Member: "-" + w.JournalMember()}
+	}
+	return &Log{Member: "+" + w.JournalMember(), Score: *prev}
+}
+
+// ParseJournalWrite reads a WriteKey entry back as the write which restores it
+func ParseJournalWrite(l *Log) *Write {
+	key, member, _ := strings.Cut(l.Member[1:], "|")
+	return &Write{
+		Key:    key,
+		Member: member,
+		Score:  l.Score,
+		Delete: l.Member[0] == '-',
+	}
+}
+
+// MergeWrites keeps the last write to each key and member, in a stable order
+func MergeWrites(writes []*Write) []*Write {
+	merged := make(map[string]*Write, len(writes))
+	for _, w := range writes {
+		merged[w.JournalMember()] = w
+	}
+	result := make([]*Write, 0, len(merged))
+	for _, w := range merged {
+		result = append(result, w)
+	}
+	slices.SortFunc(result, func(a, b *Write) int {
+		return strings.Compare(a.JournalMember(), b.JournalMember())
+	})
+	return result
+}
+
+// PlanWrites returns the writes which restore the entries journaled by a previous
+// commit, prev, followed by writes, and the journal which replaces prev. current
+// returns the score of an entry before the restores, or nil when it is absent.
+func PlanWrites(prev []*Log, writes []*Write, current func(key string, member string) (*float64, error)) (apply []*Write, journal []*Log, err error) {
+	restored := make(map[string]*Write, len(prev))
+	for _, l := range prev {
+		w := ParseJournalWrite(l)
+		restored[w.JournalMember()] = w
+		apply = append(apply, w)
+	}
+	slices.SortFunc(apply, func(a, b *Write) int {
+		return strings.Compare(a.JournalMember(), b.JournalMember())
+	})
+	for _, w := range MergeWrites(writes) {
+		var before *float64
+		if r, ok := restored[w.JournalMember()]; ok {
+			if !r.Delete {
+				before = &r.Score
+			}
+		} else if before, err = current(w.Key, w.Member); err != nil {
+			return nil, nil, err
+		}
+		journal = append(journal, JournalWrite(w, before))
+		apply = append(apply, w)
+	}
+	return apply, journal, nil
+}
@@ -0,0 +1,768 @@
+package idxtest
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/bsv-blockchain/go-sdk/chainhash"
+	"github.com/bsv-blockchain/go-sdk/script"
+	"github.com/bsv-blockchain/go-sdk/transaction"
+	"github.com/bsv-blockchain/go-sdk/transaction/template/p2pkh"
+	"github.com/shruggr/1sat-indexer/v5/evt"
+	"github.com/shruggr/1sat-indexer/v5/idx"
+	"github.com/shruggr/1sat-indexer/v5/jb"
+	"github.com/shruggr/1sat-indexer/v5/lib"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	storeTag   = "test"
+	ownerAddr  = "1Fj8GVwfV6GnjpqN7t8GvxugKN4SAyER9X"
+	buyerAddr  = "138eimThBg6FZa6QWiZ7cR76mu4BGdkLXF"
+	testHeight = 850000
+)
+
+// TestStore runs the TxoStore conformance suite. newStore is called for
+// each subtest and must return an empty store.
+func TestStore(t *testing.T, newStore func(t *testing.T) idx.TxoStore) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, store idx.TxoStore)
+	}{
+		{"Log", testLog},
+		{"SearchOrder", testSearchOrder},
+		{"SearchPaging", testSearchPaging},
+		{"SearchComparison", testSearchComparison},
+		{"SearchOutpointsOnly", testSearchOutpointsOnly},
+		{"SearchMempool", testSearchMempool},
+		{"SaveTxos", testSaveTxos},
+		{"Spends", testSpends},
+		{"Commit", testCommit},
+		{"FilterSpent", testFilterSpent},
+		{"RefreshSpends", testRefreshSpends},
+		{"SearchTxns", testSearchTxns},
+		{"Rollback", testRollback},
+		{"Incrs", testIncrs},
+		{"Writes", testWrites},
+		{"ConcurrentCommits", testConcurrentCommits},
+		{"LargeTx", testLargeTx},
+		{"Deps", testDeps},
+		{"Accounts", testAccounts},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStore(t))
+		})
+	}
+}
+
+func score(height uint32, blkIdx uint64) float64 {
+	return idx.HeightScore(height, blkIdx)
+}
+
+func members(logs []*idx.Log) []string {
+	m := make([]string, 0, len(logs))
+	for _, l := range logs {
+		m = append(m, l.Member)
+	}
+	return m
+}
+
+func search(t *testing.T, store idx.TxoStore, cfg *idx.SearchCfg) []string {
+	t.Helper()
+	logs, err := store.Search(context.Background(), cfg)
+	require.NoError(t, err)
+	return members(logs)
+}
+
+func logAll(t *testing.T, store idx.TxoStore, key string, logs map[string]float64) {
+	t.Helper()
+	for member, score := range logs {
+		require.NoError(t, store.Log(context.Background(), key, member, score))
+	}
+}
+
+func testLog(t *testing.T, store idx.TxoStore) {
+	ctx := context.Background()
+	require.NoError(t, store.Log(ctx, "log", "a", 1))
+	require.NoError(t, store.LogMany(ctx, "log", []idx.Log{{Member: "b", Score: 2}, {Member: "c", Score: 3}}))
+
+	s, err := store.LogScore(ctx, "log", "b")
+	require.NoError(t, err)
+	assert.Equal(t, float64(2), s)
+
+	s, err = store.LogScore(ctx, "log", "missing")
+	require.NoError(t, err)
+	assert.Equal(t, float64(0), s)
+
+	logged, err := store.LogOnce(ctx, "log", "a", 10)
+	require.NoError(t, err)
+	assert.False(t, logged)
+	s, _ = store.LogScore(ctx, "log", "a")
+	assert.Equal(t, float64(1), s)
+
+	logged, err = store.LogOnce(ctx, "log", "d", 4)
+	require.NoError(t, err)
+	assert.True(t, logged)
+
+	require.NoError(t, store.Log(ctx, "log", "a", 5))
+	s, _ = store.LogScore(ctx, "log", "a")
+	assert.Equal(t, float64(5), s)
+
+	count, err := store.CountMembers(ctx, "log")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), count)
+
+	require.NoError(t, store.Delog(ctx, "log", "a", "b"))
+	count, _ = store.CountMembers(ctx, "log")
+	assert.Equal(t, uint64(2), count)
+	assert.Equal(t, []string{"c", "d"}, search(t, store, &idx.SearchCfg{Keys: []string{"log"}}))
+}
+
+func testSearchOrder(t *testing.T, store idx.TxoStore) {
+	logAll(t, store, "order", map[string]float64{"a": 1, "b": 2, "c": 3, "d": 4, "e": 5})
+	keys := []string{"order"}
+	f := func(v float64) *float64 { return &v }
+
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, search(t, store, &idx.SearchCfg{Keys: keys}))
+	assert.Equal(t, []string{"e", "d", "c", "b", "a"}, search(t, store, &idx.SearchCfg{Keys: keys, Reverse: true}))
+	assert.Equal(t, []string{"a", "b"}, search(t, store, &idx.SearchCfg{Keys: keys, Limit: 2}))
+	assert.Equal(t, []string{"e", "d"}, search(t, store, &idx.SearchCfg{Keys: keys, Limit: 2, Reverse: true}))
+
+	// From and To are exclusive
+	assert.Equal(t, []string{"c", "d", "e"}, search(t, store, &idx.SearchCfg{Keys: keys, From: f(2)}))
+	assert.Equal(t, []string{"c", "d"}, search(t, store, &idx.SearchCfg{Keys: keys, From: f(2), To: f(5)}))
+	assert.Equal(t, []string{"c", "b", "a"}, search(t, store, &idx.SearchCfg{Keys: keys, From: f(4), Reverse: true}))
+	assert.Equal(t, []string{"d", "c", "b"}, search(t, store, &idx.SearchCfg{Keys: keys, From: f(5), To: f(1), Reverse: true}))
+
+	logs, err := store.Search(context.Background(), &idx.SearchCfg{Keys: keys, Limit: 1, From: f(3)})
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	assert.Equal(t, idx.Log{Member: "d", Score: 4}, *logs[0])
+
+	assert.Empty(t, search(t, store, &idx.SearchCfg{Keys: []string{"missing"}}))
+}
+
+func testSearchPaging(t *testing.T, store idx.TxoStore) {
+	logs := map[string]float64{}
+	expected := []string{}
+	for i := 0; i < 7; i++ {
+		member := string(rune('a' + i))
+		logs[member] = score(testHeight+uint32(i), uint64(i))
+		expected = append(expected, member)
+	}
+	logAll(t, store, "paging", logs)
+
+	for _, reverse := range []bool{false, true} {
+		results := []string{}
+		cfg := &idx.SearchCfg{Keys: []string{"paging"}, Limit: 3, Reverse: reverse}
+		for page := 0; page < 5; page++ {
+			logs, err := store.Search(context.Background(), cfg)
+			require.NoError(t, err)
+			if len(logs) == 0 {
+				break
+			}
+			results = append(results, members(logs)...)
+			cfg.From = &logs[len(logs)-1].Score
+		}
+		if reverse {
+			for i, j := 0, len(results)-1; i < j; i, j = i+1, j-1 {
+				results[i], results[j] = results[j], results[i]
+			}
+		}
+		assert.Equal(t, expected, results, "reverse=%v", reverse)
+	}
+}
+
+func testSearchComparison(t *testing.T, store idx.TxoStore) {
+	logAll(t, store, "k1", map[string]float64{"a": 1, "b": 2, "c": 3})
+	logAll(t, store, "k2", map[string]float64{"b": 2, "c": 3, "d": 4})
+	keys := []string{"k1", "k2"}
+
+	assert.Equal(t, []string{"a", "b", "c", "d"}, search(t, store, &idx.SearchCfg{Keys: keys}))
+	assert.Equal(t, []string{"d", "c", "b", "a"}, search(t, store, &idx.SearchCfg{Keys: keys, Reverse: true}))
+	assert.Equal(t, []string{"a", "b"}, search(t, store, &idx.SearchCfg{Keys: keys, Limit: 2}))
+	assert.Equal(t, []string{"b", "c"}, search(t, store, &idx.SearchCfg{Keys: keys, ComparisonType: idx.ComparisonAND}))
+	assert.Equal(t, []string{"c", "b"}, search(t, store, &idx.SearchCfg{Keys: keys, ComparisonType: idx.ComparisonAND, Reverse: true}))
+	assert.Equal(t, []string{"b"}, search(t, store, &idx.SearchCfg{Keys: keys, ComparisonType: idx.ComparisonAND, Limit: 1}))
+	assert.Empty(t, search(t, store, &idx.SearchCfg{Keys: []string{"k1", "missing"}, ComparisonType: idx.ComparisonAND}))
+}
+
+func testSearchOutpointsOnly(t *testing.T, store idx.TxoStore) {
+	tx := newTx(0, 1000)
+	txid := tx.TxID().String()
+	outpoint := lib.NewOutpointFromHash(tx.TxID(), 0).String()
+	logAll(t, store, "mixed", map[string]float64{txid: 1, outpoint: 2})
+
+	assert.Equal(t, []string{txid, outpoint}, search(t, store, &idx.SearchCfg{Keys: []string{"mixed"}}))
+	assert.Equal(t, []string{outpoint}, search(t, store, &idx.SearchCfg{Keys: []string{"mixed"}, OutpointsOnly: true}))
+	assert.Equal(t, []string{outpoint}, search(t, store, &idx.SearchCfg{Keys: []string{"mixed"}, OutpointsOnly: true, Limit: 1}))
+
+	outpoints, err := store.SearchOutpoints(context.Background(), &idx.SearchCfg{Keys: []string{"mixed"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{outpoint}, outpoints)
+}
+
+func testSearchMempool(t *testing.T, store idx.TxoStore) {
+	logAll(t, store, "pool", map[string]float64{
+		"mined1":  score(testHeight, 1),
+		"mined2":  score(testHeight+1, 0),
+		"mempool": score(0, 0),
+	})
+	keys := []string{"pool"}
+
+	assert.Equal(t, []string{"mined1", "mined2", "mempool"}, search(t, store, &idx.SearchCfg{Keys: keys}))
+	assert.Equal(t, []string{"mined1", "mined2"}, search(t, store, &idx.SearchCfg{Keys: keys, ExcludeMempool: true}))
+	assert.Equal(t, []string{"mined2", "mined1"}, search(t, store, &idx.SearchCfg{Keys: keys, ExcludeMempool: true, Reverse: true}))
+	assert.Equal(t, []string{"mempool"}, search(t, store, &idx.SearchCfg{Keys: keys, ExcludeMined: true}))
+	assert.Equal(t, []string{"mempool"}, search(t, store, &idx.SearchCfg{Keys: keys, ExcludeMined: true, Reverse: true}))
+	assert.Empty(t, search(t, store, &idx.SearchCfg{Keys: keys, ExcludeMined: true, ExcludeMempool: true}))
+}
+
+var fundHash, _ = chainhash.NewHashFromHex("4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b")
+
+// newTx builds a transaction spending vin of a fixed funding txid, paying
+// the owner then the buyer for each amount
+func newTx(vin uint32, amounts ...uint64) *transaction.Transaction {
+	return newSpend(lib.NewOutpointFromHash(fundHash, vin), amounts...)
+}
+
+func newSpend(outpoint *lib.Outpoint, amounts ...uint64) *transaction.Transaction {
+	tx := transaction.NewTransaction()
+	tx.Inputs = append(tx.Inputs, &transaction.TransactionInput{
+		SourceTXID:       outpoint.TxidHash(),
+		SourceTxOutIndex: outpoint.Vout(),
+		SequenceNumber:   0xffffffff,
+		UnlockingScript:  &script.Script{},
+	})
+	for i, amount := range amounts {
+		addr := ownerAddr
+		if i%2 == 1 {
+			addr = buyerAddr
+		}
+		address, _ := script.NewAddressFromString(addr)
+		lockingScript, _ := p2pkh.Lock(address)
+		tx.AddOutput(&transaction.TransactionOutput{
+			Satoshis:      amount,
+			LockingScript: lockingScript,
+		})
+	}
+	return tx
+}
+
+type testData struct {
+	Vout uint32 `json:"vout"`
+}
+
+// ingest parses tx at the given height, tags each output with test data and
+// an event, then saves it to the store
+func ingest(t *testing.T, store idx.TxoStore, tx *transaction.Transaction, height uint32, blkIdx uint64) *idx.IndexContext {
+	t.Helper()
+	idxCtx := idx.NewIndexContext(context.Background(), store, tx, nil, idx.AncestorConfig{})
+	idxCtx.Height = height
+	idxCtx.Idx = blkIdx
+	idxCtx.Score = score(height, blkIdx)
+	require.NoError(t, idxCtx.ParseTxn())
+	for vout, txo := range idxCtx.Txos {
+		txo.Data[storeTag] = &idx.IndexData{
+			Data:   &testData{Vout: uint32(vout)},
+			Events: []*evt.Event{{Id: "vout", Value: lib.NewOutpointFromHash(idxCtx.Txid, uint32(vout)).String()}},
+		}
+	}
+	require.NoError(t, idxCtx.Save())
+	return idxCtx
+}
+
+func outpoints(txid *chainhash.Hash, vouts ...uint32) []string {
+	ops := make([]string, 0, len(vouts))
+	for _, vout := range vouts {
+		ops = append(ops, lib.NewOutpointFromHash(txid, vout).String())
+	}
+	return ops
+}
+
+func testSaveTxos(t *testing.T, store idx.TxoStore) {
+	ctx := context.Background()
+	tx := newTx(0, 1000, 2000)
+	ingest(t, store, tx, testHeight, 5)
+	ops := outpoints(tx.TxID(), 0, 1)
+
+	txo, err := store.LoadTxo(ctx, ops[0], []string{storeTag}, false, false)
+	require.NoError(t, err)
+	require.NotNil(t, txo)
+	assert.Equal(t, ops[0], txo.Outpoint.String())
+	assert.Equal(t, uint32(testHeight), txo.Height)
+	assert.Equal(t, uint64(5), txo.Idx)
+	assert.Equal(t, score(testHeight, 5), txo.Score)
+	require.NotNil(t, txo.Satoshis)
+	assert.Equal(t, uint64(1000), *txo.Satoshis)
+	assert.Equal(t, []string{ownerAddr}, txo.Owners)
+	require.Contains(t, txo.Data, storeTag)
+	raw, ok := txo.Data[storeTag].Data.(json.RawMessage)
+	require.True(t, ok, "data is loaded as json.RawMessage")
+	assert.JSONEq(t, `{"vout":0}`, string(raw))
+
+	txo, err = store.LoadTxo(ctx, lib.NewOutpointFromHash(tx.TxID(), 2).String(), nil, false, false)
+	require.NoError(t, err)
+	assert.Nil(t, txo)
+
+	txos, err := store.LoadTxos(ctx, []string{ops[1], ops[0]}, nil, false, false)
+	require.NoError(t, err)
+	require.Len(t, txos, 2)
+	assert.Equal(t, ops[1], txos[0].Outpoint.String())
+	assert.Equal(t, ops[0], txos[1].Outpoint.String())
+	assert.Equal(t, []string{buyerAddr}, txos[0].Owners)
+
+	txos, err = store.LoadTxosByTxid(ctx, tx.TxID().String(), nil, false, false)
+	require.NoError(t, err)
+	loaded := []string{}
+	for _, txo := range txos {
+		loaded = append(loaded, txo.Outpoint.String())
+	}
+	assert.ElementsMatch(t, ops, loaded)
+
+	data, err := store.LoadData(ctx, ops[1], []string{storeTag})
+	require.NoError(t, err)
+	require.Contains(t, data, storeTag)
+	assert.JSONEq(t, `{"vout":1}`, string(data[storeTag].Data.(json.RawMessage)))
+
+	assert.Equal(t, ops, search(t, store, &idx.SearchCfg{Keys: []string{evt.TagKey(storeTag)}}))
+	assert.Equal(t, ops[1:], search(t, store, &idx.SearchCfg{Keys: []string{evt.EventKey(storeTag, &evt.Event{Id: "vout", Value: ops[1]})}}))
+	assert.Equal(t, ops[:1], search(t, store, &idx.SearchCfg{Keys: []string{idx.OwnerKey(ownerAddr)}}))
+	assert.Equal(t, ops[1:], search(t, store, &idx.SearchCfg{Keys: []string{idx.OwnerKey(buyerAddr)}}))
+
+	logs, err := store.Search(ctx, &idx.SearchCfg{Keys: []string{evt.TagKey(storeTag)}})
+	require.NoError(t, err)
+	for _, l := range logs {
+		assert.Equal(t, score(testHeight, 5), l.Score)
+	}
+
+	txs, err := store.SearchTxos(ctx, &idx.SearchCfg{Keys: []string{evt.TagKey(storeTag)}, IncludeTags: []string{storeTag}})
+	require.NoError(t, err)
+	require.Len(t, txs, 2)
+	assert.Equal(t, uint32(testHeight), txs[0].Height)
+	assert.Equal(t, uint64(5), txs[0].Idx)
+	assert.Contains(t, txs[1].Data, storeTag)
+
+	txs, err = store.SearchTxos(ctx, &idx.SearchCfg{Keys: []string{evt.TagKey(storeTag)}, IncludeTxo: true})
+	require.NoError(t, err)
+	require.Len(t, txs, 2)
+	assert.Equal(t, uint64(2000), *txs[1].Satoshis)
+}
+
+func testCommit(t *testing.T, store idx.TxoStore) {
+	ctx := context.Background()
+	fund := newTx(0, 1000)
+	ingest(t, store, fund, testHeight, 0)
+	fundOp := outpoints(fund.TxID(), 0)[0]
+
+	spend := newSpend(lib.NewOutpointFromHash(fund.TxID(), 0), 900)
+	spendTxid := spend.TxID().String()
+	idxCtx := idx.NewIndexContext(ctx, store, spend, nil, idx.AncestorConfig{})
+	idxCtx.Height = testHeight + 1
+	idxCtx.Score = score(testHeight+1, 0)
+	require.NoError(t, idxCtx.ParseTxn())
+	require.NoError(t, store.Commit(idxCtx, idx.PendingTxLog, idx.LogKey(storeTag)))
+
+	// outputs, spends and progress logs are all written by a single commit
+	txo, err := store.LoadTxo(ctx, outpoints(spend.TxID(), 0)[0], nil, false, false)
+	require.NoError(t, err)
+	require.NotNil(t, txo)
+	s, err := store.GetSpend(ctx, fundOp, false)
+	require.NoError(t, err)
+	assert.Equal(t, spendTxid, s)
+	for _, key := range []string{idx.PendingTxLog, idx.LogKey(storeTag)} {
+		logScore, err := store.LogScore(ctx, key, spendTxid)
+		require.NoError(t, err)
+		assert.Equal(t, score(testHeight+1, 0), logScore, key)
+	}
+}
+
+func testSpends(t *testing.T, store idx.TxoStore) {
+	ctx := context.Background()
+	fund := newTx(0, 1000, 2000)
+	ingest(t, store, fund, testHeight, 0)
+	ops := outpoints(fund.TxID(), 0, 1)
+
+	spend := newSpend(lib.NewOutpointFromHash(fund.TxID(), 0), 900)
+	spendTxid := spend.TxID().String()
+	ingest(t, store, spend, testHeight+1, 0)
+
+	s, err := store.GetSpend(ctx, ops[0], false)
+	require.NoError(t, err)
+	assert.Equal(t, spendTxid, s)
+
+	spends, err := store.GetSpends(ctx, []string{ops[1], ops[0]}, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"", spendTxid}, spends)
+
+	txo, err := store.LoadTxo(ctx, ops[0], nil, false, true)
+	require.NoError(t, err)
+	assert.Equal(t, spendTxid, txo.Spend)
+
+	// SetNewSpend never replaces a known spend
+	set, err := store.SetNewSpend(ctx, ops[0], "other")
+	require.NoError(t, err)
+	assert.False(t, set)
+	s, _ = store.GetSpend(ctx, ops[0], false)
+	assert.Equal(t, spendTxid, s)
+
+	set, err = store.SetNewSpend(ctx, ops[1], "other")
+	require.NoError(t, err)
+	assert.True(t, set)
+	s, _ = store.GetSpend(ctx, ops[1], false)
+	assert.Equal(t, "other", s)
+
+	require.NoError(t, store.UnsetSpends(ctx, []string{ops[1]}))
+	s, _ = store.GetSpend(ctx, ops[1], false)
+	assert.Equal(t, "", s)
+
+	// the spending txid is logged against the owners of its inputs
+	assert.ElementsMatch(t, []string{ops[0], spendTxid, outpoints(spend.TxID(), 0)[0]}, search(t, store, &idx.SearchCfg{Keys: []string{idx.OwnerKey(ownerAddr)}}))
+}
+
+func testFilterSpent(t *testing.T, store idx.TxoStore) {
+	ctx := context.Background()
+	fund := newTx(0, 1000, 2000, 3000)
+	ingest(t, store, fund, testHeight, 0)
+	ops := outpoints(fund.TxID(), 0, 1, 2)
+	spend := newSpend(lib.NewOutpointFromHash(fund.TxID(), 0), 900)
+	ingest(t, store, spend, testHeight+1, 0)
+	spendOps := outpoints(spend.TxID(), 0)
+
+	tagKey := []string{evt.TagKey(storeTag)}
+	assert.Equal(t, append(ops, spendOps...), search(t, store, &idx.SearchCfg{Keys: tagKey}))
+	assert.Equal(t, append(ops[1:], spendOps...), search(t, store, &idx.SearchCfg{Keys: tagKey, FilterSpent: true}))
+	assert.Equal(t, ops[1:3], search(t, store, &idx.SearchCfg{Keys: tagKey, FilterSpent: true, Limit: 2}))
+
+	// txids are never unspent outputs
+	assert.Equal(t, append(ops[2:], spendOps...), search(t, store, &idx.SearchCfg{Keys: []string{idx.OwnerKey(ownerAddr)}, FilterSpent: true}))
+
+	unspent, err := store.SearchOutpoints(ctx, &idx.SearchCfg{Keys: tagKey, FilterSpent: true})
+	require.NoError(t, err)
+	assert.Equal(t, append(ops[1:], spendOps...), unspent)
+
+	txos, err := store.SearchTxos(ctx, &idx.SearchCfg{Keys: tagKey, FilterSpent: true, IncludeTxo: true})
+	require.NoError(t, err)
+	require.Len(t, txos, 3)
+	assert.Equal(t, ops[1], txos[0].Outpoint.String())
+
+	txos, err = store.SearchTxos(ctx, &idx.SearchCfg{Keys: tagKey, IncludeSpend: true})
+	require.NoError(t, err)
+	require.Len(t, txos, 4)
+	assert.Equal(t, spend.TxID().String(), txos[0].Spend)
+	assert.Equal(t, "", txos[1].Spend)
+
+	txos, err = store.SearchTxos(ctx, &idx.SearchCfg{Keys: tagKey, IncludeSpend: true, IncludeTxo: true})
+	require.NoError(t, err)
+	require.Len(t, txos, 4)
+	assert.Equal(t, spend.TxID().String(), txos[0].Spend)
+
+	balance, err := store.SearchBalance(ctx, &idx.SearchCfg{Keys: []string{idx.OwnerKey(ownerAddr)}})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3000+900), balance)
+	balance, err = store.SearchBalance(ctx, &idx.SearchCfg{Keys: tagKey})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2000+3000+900), balance)
+}
+
+func testRefreshSpends(t *testing.T, store idx.TxoStore) {
+	ctx := context.Background()
+	fund := newTx(0, 1000, 2000)
+	ingest(t, store, fund, testHeight, 0)
+	ops := outpoints(fund.TxID(), 0, 1)
+
+	src := jb.NewMemorySource()
+	src.AddTx(newSpend(lib.NewOutpointFromHash(fund.TxID(), 1), 1900))
+	prev := jb.Source
+	jb.Source = src
+	defer func() { jb.Source = prev }()
+
+	tagKey := []string{evt.TagKey(storeTag)}
+	assert.Equal(t, ops, search(t, store, &idx.SearchCfg{Keys: tagKey, FilterSpent: true}))
+	assert.Equal(t, ops[:1], search(t, store, &idx.SearchCfg{Keys: tagKey, FilterSpent: true, RefreshSpends: true}))
+
+	s, err := store.GetSpend(ctx, ops[1], false)
+	require.NoError(t, err)
+	assert.NotEmpty(t, s)
+
+	s, err = store.GetSpend(ctx, ops[0], true)
+	require.NoError(t, err)
+	assert.Equal(t, "", s)
+}
+
+func testSearchTxns(t *testing.T, store idx.TxoStore) {
+	ctx := context.Background()
+	fund := newTx(0, 1000, 2000, 3000)
+	ingest(t, store, fund, testHeight, 3)
+	spend := newSpend(lib.NewOutpointFromHash(fund.TxID(), 0), 900)
+	ingest(t, store, spend, testHeight+1, 0)
+
+	txns, err := store.SearchTxns(ctx, &idx.SearchCfg{Keys: []string{idx.OwnerKey(ownerAddr)}})
+	require.NoError(t, err)
+	require.Len(t, txns, 2)
+	assert.Equal(t, fund.TxID().String(), txns[0].Txid)
+	assert.Equal(t, uint32(testHeight), txns[0].Height)
+	assert.Equal(t, uint64(3), txns[0].Idx)
+	assert.Contains(t, txns[0].Outputs, uint32(0))
+	assert.Contains(t, txns[0].Outputs, uint32(2))
+	assert.NotContains(t, txns[0].Outputs, uint32(1))
+	assert.Equal(t, spend.TxID().String(), txns[1].Txid)
+	assert.Contains(t, txns[1].Outputs, uint32(0))
+}
+
+func testRollback(t *testing.T, store idx.TxoStore) {
+	ctx := context.Background()
+	fund := newTx(0, 1000, 2000)
+	ingest(t, store, fund, testHeight, 0)
+	ops := outpoints(fund.TxID(), 0, 1)
+	spend := newSpend(lib.NewOutpointFromHash(fund.TxID(), 0), 900)
+	ingest(t, store, spend, testHeight+1, 0)
+	spendOps := outpoints(spend.TxID(), 0)
+	tagKey := []string{evt.TagKey(storeTag)}
+	ownerKey := []string{idx.OwnerKey(ownerAddr)}
+
+	require.NoError(t, store.Rollback(ctx, spend.TxID().String()))
+
+	s, err := store.GetSpend(ctx, ops[0], false)
+	require.NoError(t, err)
+	assert.Equal(t, "", s)
+	txo, err := store.LoadTxo(ctx, spendOps[0], nil, false, false)
+	require.NoError(t, err)
+	assert.Nil(t, txo)
+	txos, err := store.LoadTxosByTxid(ctx, spend.TxID().String(), nil, false, false)
+	require.NoError(t, err)
+	assert.Empty(t, txos)
+	assert.Equal(t, ops, search(t, store, &idx.SearchCfg{Keys: tagKey}))
+	assert.Empty(t, search(t, store, &idx.SearchCfg{Keys: []string{evt.EventKey(storeTag, &evt.Event{Id: "vout", Value: spendOps[0]})}}))
+	assert.Equal(t, ops[:1], search(t, store, &idx.SearchCfg{Keys: ownerKey}))
+	assert.Equal(t, ops, search(t, store, &idx.SearchCfg{Keys: tagKey, FilterSpent: true}))
+
+	require.NoError(t, store.Rollback(ctx, fund.TxID().String()))
+	txo, err = store.LoadTxo(ctx, ops[0], nil, false, false)
+	require.NoError(t, err)
+	assert.Nil(t, txo)
+	assert.Empty(t, search(t, store, &idx.SearchCfg{Keys: tagKey}))
+	assert.Empty(t, search(t, store, &idx.SearchCfg{Keys: ownerKey}))
+	assert.Empty(t, search(t, store, &idx.SearchCfg{Keys: []string{idx.OwnerKey(buyerAddr)}}))
+}
+
+func testIncrs(t *testing.T, store idx.TxoStore) {
+	ctx := context.Background()
+	key := "test:totals"
+	commit := func(tx *transaction.Transaction, incrs ...*idx.Incr) {
+		t.Helper()
+		idxCtx := idx.NewIndexContext(ctx, store, tx, nil, idx.AncestorConfig{})
+		idxCtx.Height = testHeight
+		idxCtx.Score = score(testHeight, 0)
+		require.NoError(t, idxCtx.ParseTxn())
+		for _, incr := range incrs {
+			idxCtx.Incr(incr.Key, incr.Member, incr.Delta)
+		}
+		require.NoError(t, idxCtx.Save())
+	}
+	totals := func() map[string]float64 {
+		t.Helper()
+		logs, err := store.Search(ctx, &idx.SearchCfg{Keys: []string{key}})
+		require.NoError(t, err)
+		totals := make(map[string]float64, len(logs))
+		for _, l := range logs {
+			totals[l.Member] = l.Score
+		}
+		return totals
+	}
+
+	first := newTx(0, 1000)
+	second := newTx(1, 1000)
+	commit(first, &idx.Incr{Key: key, Member: ownerAddr, Delta: 100}, &idx.Incr{Key: key, Member: buyerAddr, Delta: 50})
+	commit(second, &idx.Incr{Key: key, Member: ownerAddr, Delta: 25})
+	assert.Equal(t, map[string]float64{ownerAddr: 125, buyerAddr: 50}, totals())
+
+	// Committing a transaction again replaces its increments rather than adding to them
+	commit(first, &idx.Incr{Key: key, Member: ownerAddr, Delta: 100})
+	assert.Equal(t, map[string]float64{ownerAddr: 125}, totals())
+
+	// Totals falling to zero are removed
+	commit(second, &idx.Incr{Key: key, Member: ownerAddr, Delta: -100})
+	assert.Equal(t, map[string]float64{}, totals())
+
+	require.NoError(t, store.Rollback(ctx, second.TxID().String()))
+	assert.Equal(t, map[string]float64{ownerAddr: 100}, totals())
+	require.NoError(t, store.Rollback(ctx, first.TxID().String()))
+	assert.Equal(t, map[string]float64{}, totals())
+}
+
+func testWrites(t *testing.T, store idx.TxoStore) {
+	ctx := context.Background()
+	key := "test:writes"
+	commit := func(tx *transaction.Transaction, writes ...*idx.Write) {
+		t.Helper()
+		idxCtx := idx.NewIndexContext(ctx, store, tx, nil, idx.AncestorConfig{})
+		idxCtx.Height = testHeight
+		idxCtx.Score = score(testHeight, 0)
+		require.NoError(t, idxCtx.ParseTxn())
+		for _, w := range writes {
+			if w.Delete {
+				idxCtx.Delog(w.Key, w.Member)
+			} else {
+				idxCtx.Log(w.Key, w.Member, w.Score)
+			}
+		}
+		require.NoError(t, idxCtx.Save())
+	}
+	entries := func() map[string]float64 {
+		t.Helper()
+		logs, err := store.Search(ctx, &idx.SearchCfg{Keys: []string{key}})
+		require.NoError(t, err)
+		entries := make(map[string]float64, len(logs))
+		for _, l := range logs {
+			entries[l.Member] = l.Score
+		}
+		return entries
+	}
+
+	first := newTx(0, 1000)
+	second := newTx(1, 1000)
+	// Zero and negative scores are written as given
+	commit(first, &idx.Write{Key: key, Member: "zero", Score: 0}, &idx.Write{Key: key, Member: "negative", Score: -5})
+	assert.Equal(t, map[string]float64{"zero": 0, "negative": -5}, entries())
+
+	// Committing a transaction again leaves the same entries
+	commit(first, &idx.Write{Key: key, Member: "zero", Score: 0}, &idx.Write{Key: key, Member: "negative", Score: -5})
+	assert.Equal(t, map[string]float64{"zero": 0, "negative": -5}, entries())
+
+	// A later transaction replaces and removes entries of an earlier one
+	commit(second, &idx.Write{Key: key, Member: "zero", Score: 7}, &idx.Write{Key: key, Member: "negative", Delete: true})
+	assert.Equal(t, map[string]float64{"zero": 7}, entries())
+	commit(second, &idx.Write{Key: key, Member: "zero", Score: 8}, &idx.Write{Key: key, Member: "negative", Delete: true})
+	assert.Equal(t, map[string]float64{"zero": 8}, entries())
+
+	// Rolling back restores the entries each transaction replaced
+	require.NoError(t, store.Rollback(ctx, second.TxID().String()))
+	assert.Equal(t, map[string]float64{"zero": 0, "negative": -5}, entries())
+	require.NoError(t, store.Rollback(ctx, first.TxID().String()))
+	assert.Equal(t, map[string]float64{}, entries())
+}
+
+func testConcurrentCommits(t *testing.T, store idx.TxoStore) {
+	ctx := context.Background()
+	key := "test:totals"
+	tx := newTx(0, 1000, 2000)
+
+	// Commits of the same transaction racing each other apply its increments once
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			idxCtx := idx.NewIndexContext(ctx, store, tx, nil, idx.AncestorConfig{})
+			idxCtx.Height = testHeight
+			idxCtx.Score = score(testHeight, 0)
+			if err := idxCtx.ParseTxn(); err != nil {
+				errs <- err
+				return
+			}
+			idxCtx.Incr(key, ownerAddr, 100)
+			errs <- idxCtx.Save(idx.LogKey(storeTag))
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	total, err := store.LogScore(ctx, key, ownerAddr)
+	require.NoError(t, err)
+	assert.Equal(t, float64(100), total)
+	txos, err := store.LoadTxosByTxid(ctx, tx.TxID().String(), nil, false, false)
+	require.NoError(t, err)
+	assert.Len(t, txos, 2)
+
+	require.NoError(t, store.Rollback(ctx, tx.TxID().String()))
+	total, err = store.LogScore(ctx, key, ownerAddr)
+	require.NoError(t, err)
+	assert.Zero(t, total)
+}
+
+func testLargeTx(t *testing.T, store idx.TxoStore) {
+	ctx := context.Background()
+	// Enough outputs to span several write batches
+	amounts := make([]uint64, 2500)
+	for i := range amounts {
+		amounts[i] = uint64(i + 1)
+	}
+	tx := newTx(0, amounts...)
+	ingest(t, store, tx, testHeight, 0)
+	txid := tx.TxID()
+
+	txos, err := store.LoadTxosByTxid(ctx, txid.String(), []string{storeTag}, false, false)
+	require.NoError(t, err)
+	require.Len(t, txos, len(amounts))
+	for _, txo := range txos {
+		assert.Equal(t, amounts[txo.Outpoint.Vout()], *txo.Satoshis)
+		assert.Contains(t, txo.Data, storeTag)
+	}
+	assert.Len(t, search(t, store, &idx.SearchCfg{Keys: []string{evt.TagKey(storeTag)}}), len(amounts))
+	last := outpoints(txid, uint32(len(amounts)-1))
+	assert.Equal(t, last, search(t, store, &idx.SearchCfg{Keys: []string{evt.EventKey(storeTag, &evt.Event{Id: "vout", Value: last[0]})}}))
+
+	require.NoError(t, store.Rollback(ctx, txid.String()))
+	txos, err = store.LoadTxosByTxid(ctx, txid.String(), nil, false, false)
+	require.NoError(t, err)
+	assert.Empty(t, txos)
+	assert.Empty(t, search(t, store, &idx.SearchCfg{Keys: []string{evt.TagKey(storeTag)}}))
+}
+
+func testDeps(t *testing.T, store idx.TxoStore) {
+	ctx := context.Background()
+	fund := newTx(0, 1000, 2000)
+	ingest(t, store, fund, testHeight, 0)
+	ops := outpoints(fund.TxID(), 0, 1)
+
+	spend := newSpend(lib.NewOutpointFromHash(fund.TxID(), 0), 900)
+	idxCtx := idx.NewIndexContext(ctx, store, spend, nil, idx.AncestorConfig{})
+	idxCtx.Height = testHeight + 1
+	idxCtx.Score = score(testHeight+1, 0)
+	require.NoError(t, idxCtx.ParseTxn())
+	idxCtx.Txos[0].Data[storeTag] = &idx.IndexData{
+		Data: &testData{},
+		Deps: []*lib.Outpoint{lib.NewOutpointFromHash(fund.TxID(), 0), lib.NewOutpointFromHash(fund.TxID(), 1)},
+	}
+	require.NoError(t, idxCtx.Save())
+	spendOp := outpoints(spend.TxID(), 0)[0]
+
+	assert.Equal(t, ops, search(t, store, &idx.SearchCfg{Keys: []string{idx.DepsKey(storeTag, spendOp)}}))
+	assert.Equal(t, []string{spendOp}, search(t, store, &idx.SearchCfg{Keys: []string{idx.DependentsKey(storeTag, ops[0])}}))
+	assert.Equal(t, []string{spendOp}, search(t, store, &idx.SearchCfg{Keys: []string{idx.DependentsKey(storeTag, ops[1])}}))
+	assert.Empty(t, search(t, store, &idx.SearchCfg{Keys: []string{idx.DepsKey("other", spendOp)}}))
+
+	require.NoError(t, store.Rollback(ctx, spend.TxID().String()))
+	assert.Empty(t, search(t, store, &idx.SearchCfg{Keys: []string{idx.DepsKey(storeTag, spendOp)}}))
+	assert.Empty(t, search(t, store, &idx.SearchCfg{Keys: []string{idx.DependentsKey(storeTag, ops[0])}}))
+}
+
+func testAccounts(t *testing.T, store idx.TxoStore) {
+	ctx := context.Background()
+	require.NoError(t, store.UpdateAccount(ctx, "acct", []string{ownerAddr, buyerAddr, ""}))
+
+	owners, err := store.AcctOwners(ctx, "acct")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{ownerAddr, buyerAddr}, owners)
+
+	accts, err := store.AcctsByOwners(ctx, []string{ownerAddr, buyerAddr})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"acct"}, accts)
+
+	accts, err = store.AcctsByOwners(ctx, []string{"unknown"})
+	require.NoError(t, err)
+	assert.Empty(t, accts)
+
+	count, err := store.CountMembers(ctx, idx.OwnerSyncKey)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), count)
+}
@@ -0,0 +1,107 @@
+package idxtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+
+	"github.com/shruggr/1sat-indexer/v5/evt"
+	"github.com/shruggr/1sat-indexer/v5/idx"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+type DataSnapshot struct {
+	Data   json.RawMessage `json:"data"`
+	Events []string        `json:"events,omitempty"`
+	Deps   []string        `json:"deps,omitempty"`
+}
+
+type TxoSnapshot struct {
+	Outpoint string                   `json:"outpoint"`
+	Satoshis uint64                   `json:"satoshis"`
+	Owners   []string                 `json:"owners,omitempty"`
+	Data     map[string]*DataSnapshot `json:"data,omitempty"`
+}
+
+type TxSnapshot struct {
+	Txid   string         `json:"txid"`
+	Height uint32         `json:"height"`
+	Idx    uint64         `json:"idx"`
+	Spends []*TxoSnapshot `json:"spends,omitempty"`
+	Txos   []*TxoSnapshot `json:"txos"`
+}
+
+// Snapshot captures the indexed data, events, deps and owners of every output.
+// Scores are omitted since mempool scores depend on the current time.
+func Snapshot(idxCtx *idx.IndexContext) *TxSnapshot {
+	snap := &TxSnapshot{
+		Txid:   idxCtx.TxidHex,
+		Height: idxCtx.Height,
+		Idx:    idxCtx.Idx,
+	}
+	for _, spend := range idxCtx.Spends {
+		if len(spend.Data) > 0 {
+			snap.Spends = append(snap.Spends, txoSnapshot(spend))
+		}
+	}
+	for _, txo := range idxCtx.Txos {
+		snap.Txos = append(snap.Txos, txoSnapshot(txo))
+	}
+	return snap
+}
+
+func txoSnapshot(txo *idx.Txo) *TxoSnapshot {
+	snap := &TxoSnapshot{
+		Outpoint: txo.Outpoint.String(),
+		Owners:   txo.Owners,
+	}
+	if txo.Satoshis != nil {
+		snap.Satoshis = *txo.Satoshis
+	}
+	for tag, data := range txo.Data {
+		if snap.Data == nil {
+			snap.Data = make(map[string]*DataSnapshot, len(txo.Data))
+		}
+		d := &DataSnapshot{}
+		if raw, ok := data.Data.(json.RawMessage); ok {
+			d.Data = raw
+		} else if b, err := json.Marshal(data.Data); err == nil {
+			d.Data = b
+		}
+		for _, e := range data.Events {
+			d.Events = append(d.Events, evt.EventKey(tag, e))
+		}
+		for _, dep := range data.Deps {
+			d.Deps = append(d.Deps, dep.String())
+		}
+		snap.Data[tag] = d
+	}
+	return snap
+}
+
+// Golden compares the snapshot of idxCtx against testdata/<name>.golden.json.
+// Run tests with -update to rewrite the golden files.
+func (h *Harness) Golden(name string, idxCtx *idx.IndexContext) {
+	h.T.Helper()
+	actual, err := json.MarshalIndent(Snapshot(idxCtx), "", "  ")
+	if err != nil {
+		h.T.Fatal(err)
+	}
+	actual = append(actual, '\n')
+
+	path := filepath.Join(h.Dir, name+".golden.json")
+	if *update {
+		if err := os.WriteFile(path, actual, 0644); err != nil {
+			h.T.Fatal(err)
+		}
+		return
+	}
+	if expected, err := os.ReadFile(path); err != nil {
+		h.T.Fatalf("%s: %v (run with -update to create)", path, err)
+	} else if !bytes.Equal(expected, actual) {
+		h.T.Errorf("%s does not match golden file\nexpected:\n%s\nactual:\n%s", name, expected, actual)
+	}
+}
@@ -0,0 +1,177 @@
+// Package idxtest runs indexers against transaction fixtures without any
+// network or database dependencies.
+//
+// Fixtures are read from a testdata directory. Files named <name>.hex or
+// <name>.bin hold a raw transaction and <name>.beef holds a BEEF encoded
+// transaction along with its ancestors. Transactions are referenced in tests
+// by fixture name or txid.
+package idxtest
+
+import (
+	"context"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bsv-blockchain/go-sdk/transaction"
+	"github.com/shruggr/1sat-indexer/v5/evt"
+	"github.com/shruggr/1sat-indexer/v5/idx"
+	memstore "github.com/shruggr/1sat-indexer/v5/idx/mem-store"
+	"github.com/shruggr/1sat-indexer/v5/jb"
+	"github.com/shruggr/1sat-indexer/v5/lib"
+)
+
+type Harness struct {
+	T        testing.TB
+	Dir      string
+	Source   *jb.MemorySource
+	Store    *memstore.MemStore
+	Ctx      *idx.IngestCtx
+	fixtures map[string]*transaction.Transaction
+}
+
+// New creates a harness running the indexers over the fixtures in ./testdata
+func New(t testing.TB, indexers ...idx.Indexer) *Harness {
+	t.Helper()
+	h := &Harness{
+		T:        t,
+		Dir:      "testdata",
+		Source:   jb.NewMemorySource(),
+		Store:    memstore.NewMemStore(),
+		fixtures: make(map[string]*transaction.Transaction),
+	}
+	h.Store.Source = h.Source
+	h.Ctx = &idx.IngestCtx{
+		Tag:         "test",
+		Indexers:    indexers,
+		Network:     lib.Mainnet,
+		Concurrency: 1,
+		Store:       h.Store,
+		Source:      h.Source,
+		AncestorConfig: idx.AncestorConfig{
+			Load:  true,
+			Parse: true,
+		},
+	}
+	if _, err := os.Stat(h.Dir); err == nil {
+		h.LoadDir(h.Dir)
+	}
+	return h
+}
+
+// LoadDir adds every fixture in dir to the harness source
+func (h *Harness) LoadDir(dir string) {
+	h.T.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		h.T.Fatal(err)
+	}
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".hex" && ext != ".bin" && ext != ".beef") {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			h.T.Fatal(err)
+		}
+		if ext == ".hex" {
+			if b, err = hex.DecodeString(strings.TrimSpace(string(b))); err != nil {
+				h.T.Fatalf("%s: %v", entry.Name(), err)
+			}
+		} else if ext == ".beef" {
+			if decoded, err := hex.DecodeString(strings.TrimSpace(string(b))); err == nil {
+				b = decoded
+			}
+		}
+		var tx *transaction.Transaction
+		if ext == ".beef" {
+			tx, err = transaction.NewTransactionFromBEEF(b)
+		} else {
+			tx, err = transaction.NewTransactionFromBytes(b)
+		}
+		if err != nil {
+			h.T.Fatalf("%s: %v", entry.Name(), err)
+		}
+		h.AddTx(strings.TrimSuffix(entry.Name(), ext), tx)
+	}
+}
+
+// AddTx registers a transaction under a fixture name
+func (h *Harness) AddTx(name string, tx *transaction.Transaction) {
+	h.fixtures[name] = tx
+	h.Source.AddTx(tx)
+}
+
+// Tx returns the transaction for a fixture name or txid
+func (h *Harness) Tx(ref string) *transaction.Transaction {
+	h.T.Helper()
+	if tx, ok := h.fixtures[ref]; ok {
+		return tx
+	} else if tx, err := jb.LoadTxFrom(context.Background(), h.Source, ref, true); err != nil {
+		h.T.Fatalf("fixture %s: %v", ref, err)
+		return nil
+	} else {
+		return tx
+	}
+}
+
+// Txid returns the txid of a fixture
+func (h *Harness) Txid(ref string) string {
+	h.T.Helper()
+	return h.Tx(ref).TxID().String()
+}
+
+// Outpoint returns the outpoint string for a fixture output
+func (h *Harness) Outpoint(ref string, vout uint32) string {
+	h.T.Helper()
+	return lib.NewOutpointFromHash(h.Tx(ref).TxID(), vout).String()
+}
+
+// Parse runs the indexers over a fixture without saving it.
+// Ancestors are parsed from the fixtures when they have not been ingested.
+func (h *Harness) Parse(ref string) *idx.IndexContext {
+	h.T.Helper()
+	if idxCtx, err := h.Ctx.ParseTx(context.Background(), h.Tx(ref), h.Ctx.AncestorConfig); err != nil {
+		h.T.Fatalf("parse %s: %v", ref, err)
+		return nil
+	} else {
+		return idxCtx
+	}
+}
+
+// Ingest parses a fixture and saves it to the harness store
+func (h *Harness) Ingest(ref string) *idx.IndexContext {
+	h.T.Helper()
+	if idxCtx, err := h.Ctx.IngestTx(context.Background(), h.Tx(ref), h.Ctx.AncestorConfig); err != nil {
+		h.T.Fatalf("ingest %s: %v", ref, err)
+		return nil
+	} else {
+		return idxCtx
+	}
+}
+
+// Data returns the indexed data for tag on an output, failing the test when missing
+func (h *Harness) Data(idxCtx *idx.IndexContext, vout uint32, tag string) *idx.IndexData {
+	h.T.Helper()
+	if int(vout) >= len(idxCtx.Txos) {
+		h.T.Fatalf("%s_%d: no such output", idxCtx.TxidHex, vout)
+	} else if data, ok := idxCtx.Txos[vout].Data[tag]; ok {
+		return data
+	}
+	h.T.Fatalf("%s_%d: missing %s data", idxCtx.TxidHex, vout, tag)
+	return nil
+}
+
+// Events returns the event keys for tag on an output
+func Events(idxCtx *idx.IndexContext, vout uint32, tag string) []string {
+	events := []string{}
+	if data, ok := idxCtx.Txos[vout].Data[tag]; ok {
+		for _, e := range data.Events {
+			events = append(events, evt.EventKey(tag, e))
+		}
+	}
+	return events
+}
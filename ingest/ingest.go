@@ -15,6 +15,7 @@ import (
 	"github.com/shruggr/1sat-indexer/v5/evt"
 	"github.com/shruggr/1sat-indexer/v5/idx"
 	"github.com/shruggr/1sat-indexer/v5/jb"
+	"github.com/shruggr/1sat-indexer/v5/metrics"
 )
 
 var ctx = context.Background()
@@ -40,6 +41,9 @@ func Start(ctx context.Context, ingestCtx *idx.IngestCtx, bcast *broadcaster.Arc
 	for chaintip := range blk.C {
 		log.Println("Chaintip", chaintip.Height, chaintip.Hash)
 		immutableScore = idx.HeightScore(chaintip.Height-10, 0)
+		if err := checkReorg(ctx, chaintip); err != nil {
+			log.Println("[REORG] Check error:", err)
+		}
 		AuditTransactions(ctx, rollback)
 	}
 }
@@ -74,7 +78,7 @@ func startArcCallbackListener(ctx context.Context, redisClient *redis.Client) {
 			// Scenario 1: Arc Success - Transaction mined with MerklePath
 			if arcResp.MerklePath != "" {
 				go func(txidStr, merklePath string) {
-					tx, err := jb.LoadTx(ctx, txidStr, false)
+					tx, err := jb.LoadTxFrom(ctx, ingest.TxSource(), txidStr, false)
 					if err != nil {
 						log.Printf("Error loading tx %s: %v", txidStr, err)
 						return
@@ -218,11 +222,13 @@ func auditNegativeScores(ctx context.Context, wg *sync.WaitGroup, limiter chan s
 			}()
 
 			// Check if transaction exists in JungleBus
-			_, err := jb.LoadTx(ctx, txid, false)
+			_, err := jb.LoadTxFrom(ctx, ingest.TxSource(), txid, false)
 			if err == jb.ErrNotFound {
 				log.Println("Archive Missing", txid)
 				if err := ingest.Store.Delog(ctx, idx.PendingTxLog, txid); err != nil {
 					log.Printf("Delog error for %s: %v", txid, err)
+				} else {
+					metrics.AuditOutcomes.WithLabelValues(metrics.AuditRemoved).Inc()
 				}
 				return
 			} else if err != nil {
@@ -231,7 +237,7 @@ func auditNegativeScores(ctx context.Context, wg *sync.WaitGroup, limiter chan s
 			}
 
 			// Check if Arc has the transaction
-			status, err := arc.Status(txid)
+			status, err := arcStatus(txid)
 			if err != nil {
 				log.Printf("Arc status check error for %s: %v", txid, err)
 				return
@@ -243,6 +249,8 @@ func auditNegativeScores(ctx context.Context, wg *sync.WaitGroup, limiter chan s
 				log.Println("Removing unconfirmed tx:", txid)
 				if err := ingest.Store.Delog(ctx, idx.PendingTxLog, txid); err != nil {
 					log.Printf("Error removing %s from PendingTxLog: %v", txid, err)
+				} else {
+					metrics.AuditOutcomes.WithLabelValues(metrics.AuditRemoved).Inc()
 				}
 			} else if status.Status == 200 {
 				// Arc has it - publish to Redis, Arc callback listener will handle ingestion
@@ -250,6 +258,8 @@ func auditNegativeScores(ctx context.Context, wg *sync.WaitGroup, limiter chan s
 					log.Printf("Error marshaling Arc status for %s: %v", txid, err)
 				} else if err := evt.Publish(ctx, "arc", string(jsonData)); err != nil {
 					log.Printf("Error publishing Arc status for %s: %v", txid, err)
+				} else {
+					metrics.AuditOutcomes.WithLabelValues(metrics.AuditRepublished).Inc()
 				}
 			}
 		}(item.Member)
@@ -291,7 +301,7 @@ func auditMinedTransactions(ctx context.Context, rollback bool, wg *sync.WaitGro
 			}()
 
 			// Load transaction with MerklePath
-			tx, err := jb.LoadTx(ctx, txid, true)
+			tx, err := jb.LoadTxFrom(ctx, ingest.TxSource(), txid, true)
 			if err == jb.ErrNotFound {
 				// Transaction was supposedly mined >10 blocks ago but doesn't exist
 				// This is a reorg victim - rollback
@@ -301,6 +311,7 @@ func auditMinedTransactions(ctx context.Context, rollback bool, wg *sync.WaitGro
 						log.Printf("Rollback error for %s: %v", txid, err)
 						return
 					}
+					metrics.AuditOutcomes.WithLabelValues(metrics.AuditRolledBack).Inc()
 					if err := ingest.Store.Log(ctx, idx.RollbackTxLog, txid, score); err != nil {
 						log.Printf("Log to RollbackTxLog error for %s: %v", txid, err)
 					}
@@ -333,7 +344,7 @@ func auditMinedTransactions(ctx context.Context, rollback bool, wg *sync.WaitGro
 
 			// If not present or not valid, try Arc
 			if !valid {
-				status, err := arc.Status(txid)
+				status, err := arcStatus(txid)
 				if err != nil {
 					log.Printf("Arc status error for %s: %v", txid, err)
 					return
@@ -367,6 +378,7 @@ func auditMinedTransactions(ctx context.Context, rollback bool, wg *sync.WaitGro
 						log.Printf("Rollback error for %s: %v", txid, err)
 						return
 					}
+					metrics.AuditOutcomes.WithLabelValues(metrics.AuditRolledBack).Inc()
 					if err := ingest.Store.Log(ctx, idx.RollbackTxLog, txid, score); err != nil {
 						log.Printf("Log to RollbackTxLog error for %s: %v", txid, err)
 					}
@@ -382,6 +394,7 @@ func auditMinedTransactions(ctx context.Context, rollback bool, wg *sync.WaitGro
 				log.Printf("IngestTx error for %s: %v", txid, err)
 				return
 			}
+			metrics.AuditOutcomes.WithLabelValues(metrics.AuditReingested).Inc()
 
 			// Check if transaction is now immutable (>10 blocks deep)
 			newScore := idx.HeightScore(tx.MerklePath.BlockHeight, 0)
@@ -391,6 +404,7 @@ func auditMinedTransactions(ctx context.Context, rollback bool, wg *sync.WaitGro
 					log.Printf("Log to ImmutableTxLog error for %s: %v", txid, err)
 					return
 				}
+				metrics.AuditOutcomes.WithLabelValues(metrics.AuditArchived).Inc()
 				if err := ingest.Store.Delog(ctx, idx.PendingTxLog, txid); err != nil {
 					log.Printf("Delog from PendingTxLog error for %s: %v", txid, err)
 				}
@@ -440,7 +454,7 @@ func auditMempool(ctx context.Context, rollback bool, wg *sync.WaitGroup, limite
 			isOld := age > OldMempoolTimeout
 
 			// Load transaction with proof
-			tx, err := jb.LoadTx(ctx, txid, true)
+			tx, err := jb.LoadTxFrom(ctx, ingest.TxSource(), txid, true)
 			if err == jb.ErrNotFound {
 				// Transaction doesn't exist in JungleBus
 				// All transactions here are >2min old (filtered by query)
@@ -452,6 +466,7 @@ func auditMempool(ctx context.Context, rollback bool, wg *sync.WaitGroup, limite
 							log.Printf("Rollback error for %s: %v", txid, err)
 							return
 						}
+						metrics.AuditOutcomes.WithLabelValues(metrics.AuditRolledBack).Inc()
 						if err := ingest.Store.Log(ctx, idx.RollbackTxLog, txid, score); err != nil {
 							log.Printf("Log to RollbackTxLog error for %s: %v", txid, err)
 						}
@@ -463,6 +478,8 @@ func auditMempool(ctx context.Context, rollback bool, wg *sync.WaitGroup, limite
 					log.Printf("Removing missing mempool tx (age: %v) from queue: %s", age.Round(time.Second), txid)
 					if err := ingest.Store.Delog(ctx, idx.PendingTxLog, txid); err != nil {
 						log.Printf("Delog error for %s: %v", txid, err)
+					} else {
+						metrics.AuditOutcomes.WithLabelValues(metrics.AuditRemoved).Inc()
 					}
 				}
 				return
@@ -490,7 +507,7 @@ func auditMempool(ctx context.Context, rollback bool, wg *sync.WaitGroup, limite
 
 			// If not present or not valid, try Arc
 			if !valid {
-				status, err := arc.Status(txid)
+				status, err := arcStatus(txid)
 				if err != nil {
 					log.Printf("Arc status error for %s: %v", txid, err)
 					return
@@ -524,6 +541,7 @@ func auditMempool(ctx context.Context, rollback bool, wg *sync.WaitGroup, limite
 						log.Printf("Rollback error for %s: %v", txid, err)
 						return
 					}
+					metrics.AuditOutcomes.WithLabelValues(metrics.AuditRolledBack).Inc()
 					if err := ingest.Store.Log(ctx, idx.RollbackTxLog, txid, score); err != nil {
 						log.Printf("Log to RollbackTxLog error for %s: %v", txid, err)
 					}
@@ -539,6 +557,7 @@ func auditMempool(ctx context.Context, rollback bool, wg *sync.WaitGroup, limite
 				log.Printf("IngestTx error for %s: %v", txid, err)
 				return
 			}
+			metrics.AuditOutcomes.WithLabelValues(metrics.AuditReingested).Inc()
 
 			// Check if now immutable
 			newScore := idx.HeightScore(tx.MerklePath.BlockHeight, 0)
@@ -548,6 +567,7 @@ func auditMempool(ctx context.Context, rollback bool, wg *sync.WaitGroup, limite
 					log.Printf("Log to ImmutableTxLog error for %s: %v", txid, err)
 					return
 				}
+				metrics.AuditOutcomes.WithLabelValues(metrics.AuditArchived).Inc()
 				if err := ingest.Store.Delog(ctx, idx.PendingTxLog, txid); err != nil {
 					log.Printf("Delog from PendingTxLog error for %s: %v", txid, err)
 				}
@@ -556,6 +576,14 @@ func auditMempool(ctx context.Context, rollback bool, wg *sync.WaitGroup, limite
 	}
 }
 
+// arcStatus queries Arc for the status of txid, recording the request latency
+func arcStatus(txid string) (*broadcaster.ArcResponse, error) {
+	start := time.Now()
+	status, err := arc.Status(txid)
+	metrics.ObserveRequest(metrics.ServiceArc, "status", start, err)
+	return status, err
+}
+
 func processQueue(ctx context.Context, cfg *idx.IngestCtx) {
 	limiter := make(chan struct{}, cfg.Concurrency)
 	errors := make(chan error)
@@ -576,6 +604,13 @@ func processQueue(ctx context.Context, cfg *idx.IngestCtx) {
 			return
 
 		case now := <-ticker.C:
+			if len(cfg.Key) > 0 {
+				if depth, err := cfg.Store.CountMembers(ctx, cfg.Key); err != nil {
+					log.Println("Queue depth error:", err)
+				} else {
+					metrics.QueueDepth.WithLabelValues(cfg.Key).Set(float64(depth))
+				}
+			}
 			duration := time.Since(statusTime)
 			log.Printf("Ingested %d in %ds - %.02ftx/s height %d", ingestcount, int(duration.Seconds()), float64(ingestcount)/duration.Seconds(), int(lastScore/1000000000))
 			ingestcount = 0
@@ -629,7 +664,7 @@ func processQueue(ctx context.Context, cfg *idx.IngestCtx) {
 								wg.Done()
 								done <- txid
 							}()
-							if tx, err := jb.LoadTx(ctx, txid, true); err == jb.ErrNotFound {
+							if tx, err := jb.LoadTxFrom(ctx, cfg.TxSource(), txid, true); err == jb.ErrNotFound {
 								// Transaction not found in JungleBus - remove from queue
 								log.Printf("[QUEUE] Transaction not found, removing from queue: %s", txid)
 								if err := cfg.Store.Delog(ctx, cfg.Key, txid); err != nil {
@@ -638,9 +673,19 @@ func processQueue(ctx context.Context, cfg *idx.IngestCtx) {
 								return
 							} else if err != nil {
 								log.Printf("LoadTx error %s: %v", txid, err)
+								if len(cfg.Key) > 0 {
+									if err := cfg.Retry(ctx, txid, err); err != nil {
+										log.Printf("Retry error for %s: %v", txid, err)
+									}
+								}
 								errors <- err
 							} else if idxCtx, err := cfg.IngestTx(ctx, tx, cfg.AncestorConfig); err != nil {
 								log.Printf("Ingest error %s: %v", txid, err)
+								if len(cfg.Key) > 0 {
+									if err := cfg.Retry(ctx, txid, err); err != nil {
+										log.Printf("Retry error for %s: %v", txid, err)
+									}
+								}
 								errors <- err
 							} else if cfg.OnIngest != nil {
 								if err := (*cfg.OnIngest)(ctx, idxCtx); err != nil {
@@ -650,6 +695,8 @@ func processQueue(ctx context.Context, cfg *idx.IngestCtx) {
 								if err = cfg.Store.Delog(ctx, cfg.Key, txid); err != nil {
 									log.Println("Delog error:", err)
 									return
+								} else if err = cfg.Store.Delog(ctx, idx.AttemptsKey(cfg.Tag), txid); err != nil {
+									log.Println("Delog error:", err)
 								}
 							}
 						}(txid, l.Score)
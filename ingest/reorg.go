@@ -0,0 +1,144 @@
+package ingest
+
+import (
+	"context"
+	"log"
+
+	"github.com/shruggr/1sat-indexer/v5/blk"
+	"github.com/shruggr/1sat-indexer/v5/idx"
+	"github.com/shruggr/1sat-indexer/v5/sub"
+)
+
+// ReorgDepth is the number of indexed blocks compared against the longest chain on each new chaintip
+const ReorgDepth = 10
+
+// blockByHeight looks up the header of the longest chain at a height
+var blockByHeight = blk.BlockByHeight
+
+// checkReorg compares the merkle roots recorded for indexed blocks against the longest
+// chain and rolls back transactions from orphaned blocks
+func checkReorg(ctx context.Context, chaintip *blk.BlockHeader) error {
+	lastHeight, err := lastIndexedHeight(ctx)
+	if err != nil || lastHeight == 0 {
+		return err
+	}
+
+	forkHeight := lastHeight
+	if forkHeight > chaintip.Height {
+		forkHeight = chaintip.Height
+	}
+	for height := forkHeight; height > 0 && lastHeight-height < ReorgDepth; height-- {
+		if roots, err := indexedBlockRoots(ctx, height); err != nil {
+			return err
+		} else if len(roots) == 0 {
+			continue
+		} else if block, err := blockByHeight(ctx, height); err != nil {
+			return err
+		} else if len(roots) == 1 && roots[0] == block.MerkleRoot.String() {
+			break
+		} else {
+			log.Printf("[REORG] Orphaned block %d %v, longest chain has %s", height, roots, block.MerkleRoot.String())
+			forkHeight = height - 1
+		}
+	}
+
+	if forkHeight < lastHeight {
+		log.Printf("[REORG] Fork point %d, rolling back blocks %d-%d", forkHeight, forkHeight+1, lastHeight)
+		return rollbackBlocks(ctx, forkHeight+1, lastHeight)
+	}
+	return nil
+}
+
+func lastIndexedHeight(ctx context.Context) (uint32, error) {
+	if items, err := ingest.Store.Search(ctx, &idx.SearchCfg{
+		Keys:    []string{idx.BlockRootKey},
+		Reverse: true,
+		Limit:   1,
+	}); err != nil {
+		return 0, err
+	} else if len(items) == 0 {
+		return 0, nil
+	} else {
+		return uint32(items[0].Score), nil
+	}
+}
+
+// indexedBlockRoots returns the merkle roots recorded for transactions indexed at height.
+// More than one root means transactions from competing blocks were indexed.
+func indexedBlockRoots(ctx context.Context, height uint32) ([]string, error) {
+	from := float64(height) - 1
+	to := float64(height) + 1
+	return ingest.Store.SearchMembers(ctx, &idx.SearchCfg{
+		Keys: []string{idx.BlockRootKey},
+		From: &from,
+		To:   &to,
+	})
+}
+
+// rollbackBlocks removes every transaction indexed in the orphaned blocks and
+// re-queues it at its original score so it is re-ingested against the new branch
+func rollbackBlocks(ctx context.Context, fromHeight uint32, toHeight uint32) error {
+	from := idx.HeightScore(fromHeight, 0) - 1
+	to := idx.HeightScore(toHeight+1, 0)
+	items, err := ingest.Store.Search(ctx, &idx.SearchCfg{
+		Keys: []string{idx.PendingTxLog},
+		From: &from,
+		To:   &to,
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("[REORG] Rolling back %d txs from orphaned blocks", len(items))
+	for _, item := range items {
+		txid := item.Member
+		if err := ingest.Store.Rollback(ctx, txid); err != nil {
+			log.Printf("Rollback error for %s: %v", txid, err)
+			return err
+		} else if err := ingest.Store.Log(ctx, idx.RollbackTxLog, txid, item.Score); err != nil {
+			log.Printf("Log to RollbackTxLog error for %s: %v", txid, err)
+			return err
+		} else if err := ingest.Store.Delog(ctx, idx.PendingTxLog, txid); err != nil {
+			log.Printf("Delog from PendingTxLog error for %s: %v", txid, err)
+			return err
+		} else if len(ingest.Tag) > 0 {
+			if err := ingest.Store.Delog(ctx, idx.LogKey(ingest.Tag), txid); err != nil {
+				log.Printf("Delog from %s error for %s: %v", idx.LogKey(ingest.Tag), txid, err)
+				return err
+			}
+		}
+		if len(ingest.Key) > 0 {
+			if err := ingest.Store.Log(ctx, ingest.Key, txid, item.Score); err != nil {
+				log.Printf("Requeue error for %s: %v", txid, err)
+				return err
+			}
+		}
+	}
+
+	for height := fromHeight; height <= toHeight; height++ {
+		if roots, err := indexedBlockRoots(ctx, height); err != nil {
+			return err
+		} else if len(roots) > 0 {
+			if err := ingest.Store.Delog(ctx, idx.BlockRootKey, roots...); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Rewind subscriptions which progressed past the fork so the new branch is re-queued
+	rewind := float64(fromHeight - 1)
+	if items, err := ingest.Store.Search(ctx, &idx.SearchCfg{
+		Keys: []string{sub.ProgressKey},
+		From: &rewind,
+	}); err != nil {
+		return err
+	} else {
+		for _, item := range items {
+			log.Printf("[REORG] Rewinding %s from %d to %d", item.Member, int(item.Score), int(rewind))
+			if err := ingest.Store.Log(ctx, sub.ProgressKey, item.Member, rewind); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package ingest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bsv-blockchain/go-sdk/chainhash"
+	"github.com/bsv-blockchain/go-sdk/script"
+	"github.com/bsv-blockchain/go-sdk/transaction"
+	"github.com/shruggr/1sat-indexer/v5/blk"
+	"github.com/shruggr/1sat-indexer/v5/idx"
+	memstore "github.com/shruggr/1sat-indexer/v5/idx/mem-store"
+	"github.com/shruggr/1sat-indexer/v5/sub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// minedTx builds a transaction mined alone in a block at height, so its txid is the merkle root
+func minedTx(height uint32, sats uint64) *transaction.Transaction {
+	tx := transaction.NewTransaction()
+	tx.AddOutput(&transaction.TransactionOutput{
+		Satoshis:      sats,
+		LockingScript: &script.Script{script.OpTRUE},
+	})
+	txid := tx.TxID()
+	isTxid := true
+	tx.MerklePath = transaction.NewMerklePath(height, [][]*transaction.PathElement{{
+		{Offset: 0, Hash: txid, Txid: &isTxid},
+	}})
+	return tx
+}
+
+func TestCheckReorg(t *testing.T) {
+	ctx := context.Background()
+	store := memstore.NewMemStore()
+	ingest = &idx.IngestCtx{
+		Tag:   "test",
+		Key:   idx.QueueKey("test"),
+		Store: store,
+	}
+
+	kept := minedTx(100, 1)
+	orphaned := minedTx(101, 2)
+	for _, tx := range []*transaction.Transaction{kept, orphaned} {
+		_, err := ingest.IngestTx(ctx, tx, idx.AncestorConfig{})
+		require.NoError(t, err)
+	}
+	roots, err := indexedBlockRoots(ctx, 101)
+	require.NoError(t, err)
+	assert.Equal(t, []string{orphaned.TxID().String()}, roots)
+	require.NoError(t, store.Log(ctx, sub.ProgressKey, "sub", 105))
+
+	// the longest chain agrees at 100 and replaced block 101
+	replaced := chainhash.DoubleHashH([]byte("replaced"))
+	blockByHeight = func(ctx context.Context, height uint32) (*blk.BlockHeader, error) {
+		header := &blk.BlockHeader{Height: height, MerkleRoot: replaced}
+		if height == 100 {
+			header.MerkleRoot = *kept.TxID()
+		}
+		return header, nil
+	}
+	defer func() { blockByHeight = blk.BlockByHeight }()
+
+	require.NoError(t, checkReorg(ctx, &blk.BlockHeader{Height: 101}))
+
+	score, _ := store.LogScore(ctx, idx.PendingTxLog, orphaned.TxID().String())
+	assert.Zero(t, score)
+	score, _ = store.LogScore(ctx, ingest.Key, orphaned.TxID().String())
+	assert.Equal(t, idx.HeightScore(101, 0), score, "requeued at its original score")
+	txos, err := store.LoadTxosByTxid(ctx, orphaned.TxID().String(), nil, false, false)
+	require.NoError(t, err)
+	assert.Empty(t, txos)
+	roots, err = indexedBlockRoots(ctx, 101)
+	require.NoError(t, err)
+	assert.Empty(t, roots)
+
+	score, _ = store.LogScore(ctx, idx.PendingTxLog, kept.TxID().String())
+	assert.Equal(t, idx.HeightScore(100, 0), score)
+	score, _ = store.LogScore(ctx, ingest.Key, kept.TxID().String())
+	assert.Zero(t, score)
+	roots, err = indexedBlockRoots(ctx, 100)
+	require.NoError(t, err)
+	assert.Equal(t, []string{kept.TxID().String()}, roots)
+
+	score, _ = store.LogScore(ctx, sub.ProgressKey, "sub")
+	assert.Equal(t, float64(100), score)
+}
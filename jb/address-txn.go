@@ -1,10 +1,7 @@
 package jb
 
 import (
-	"encoding/json"
-	"fmt"
-	"log"
-	"net/http"
+	"context"
 )
 
 type AddressTxn struct {
@@ -16,23 +13,5 @@ type AddressTxn struct {
 }
 
 func FetchOwnerTxns(address string, lastHeight int) (txns []*AddressTxn, err error) {
-	if address == "" {
-		return
-	}
-	url := fmt.Sprintf("%s/v1/address/get/%s/%d", JUNGLEBUS, address, lastHeight)
-	if resp, err := http.Get(url); err != nil {
-		log.Panic(err)
-	} else if resp.StatusCode == 200 {
-		decoder := json.NewDecoder(resp.Body)
-		if err := decoder.Decode(&txns); err != nil {
-			log.Panic(err)
-		}
-	} else if resp.StatusCode == 400 {
-		return nil, ErrBadRequest
-	} else if resp.StatusCode == 404 {
-		return nil, ErrNotFound
-	} else {
-		log.Panic("Bad status ", resp.StatusCode, " from ", url)
-	}
-	return
+	return Source.FetchOwnerTxns(context.Background(), address, lastHeight)
 }
@@ -0,0 +1,114 @@
+package jb
+
+import (
+	"context"
+	"io"
+	"log"
+	"slices"
+
+	"github.com/bsv-blockchain/go-sdk/chainhash"
+	"github.com/bsv-blockchain/go-sdk/transaction"
+	"github.com/ordishs/go-bitcoin"
+)
+
+// BitcoindSource loads transactions from a node's REST interface and builds
+// merkle paths from the containing block. Spends and address history are not
+// indexed by the node and are reported as ErrUnsupported.
+type BitcoindSource struct {
+	Node *bitcoin.Bitcoind
+}
+
+func (s *BitcoindSource) LoadRawtx(ctx context.Context, txid string) (rawtx []byte, err error) {
+	if r, err := s.Node.GetRawTransactionRest(txid); err != nil {
+		log.Println("node error", txid, err)
+		return nil, err
+	} else {
+		defer r.Close()
+		if rawtx, err = io.ReadAll(r); err != nil {
+			log.Println("read error", txid, err)
+			return nil, err
+		} else if len(rawtx) == 0 {
+			return nil, ErrNotFound
+		}
+	}
+	return
+}
+
+func (s *BitcoindSource) LoadProof(ctx context.Context, txid string) (*transaction.MerklePath, error) {
+	if rawTx, err := s.Node.GetRawTransaction(txid); err != nil {
+		return nil, err
+	} else if rawTx.BlockHash == "" {
+		return nil, nil
+	} else if block, err := s.Node.GetBlock(rawTx.BlockHash); err != nil {
+		return nil, err
+	} else {
+		return buildMerklePath(uint32(block.Height), block.Tx, txid)
+	}
+}
+
+func (s *BitcoindSource) GetSpend(ctx context.Context, outpoint string) (string, error) {
+	return "", ErrUnsupported
+}
+
+func (s *BitcoindSource) FetchOwnerTxns(ctx context.Context, address string, lastHeight int) ([]*AddressTxn, error) {
+	return nil, ErrUnsupported
+}
+
+// buildMerklePath computes the merkle path for txid from the ordered list of block txids
+func buildMerklePath(height uint32, txids []string, txid string) (*transaction.MerklePath, error) {
+	level := make([]*chainhash.Hash, 0, len(txids))
+	offset := -1
+	for i, id := range txids {
+		if hash, err := chainhash.NewHashFromHex(id); err != nil {
+			return nil, err
+		} else {
+			level = append(level, hash)
+		}
+		if id == txid {
+			offset = i
+		}
+	}
+	if offset < 0 {
+		return nil, ErrNotFound
+	}
+
+	isTxid := true
+	path := [][]*transaction.PathElement{{{
+		Offset: uint64(offset),
+		Hash:   level[offset],
+		Txid:   &isTxid,
+	}}}
+	for depth := 0; len(level) > 1; depth++ {
+		sibling := offset ^ 1
+		if sibling < len(level) {
+			path[depth] = append(path[depth], &transaction.PathElement{
+				Offset: uint64(sibling),
+				Hash:   level[sibling],
+			})
+		} else {
+			duplicate := true
+			path[depth] = append(path[depth], &transaction.PathElement{
+				Offset:    uint64(sibling),
+				Duplicate: &duplicate,
+			})
+		}
+		slices.SortFunc(path[depth], func(a, b *transaction.PathElement) int {
+			return int(a.Offset) - int(b.Offset)
+		})
+
+		parents := make([]*chainhash.Hash, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 < len(level) {
+				parents = append(parents, transaction.MerkleTreeParent(level[i], level[i+1]))
+			} else {
+				parents = append(parents, transaction.MerkleTreeParent(level[i], level[i]))
+			}
+		}
+		level = parents
+		offset = offset >> 1
+		if len(level) > 1 {
+			path = append(path, []*transaction.PathElement{})
+		}
+	}
+	return transaction.NewMerklePath(height, path), nil
+}
@@ -0,0 +1,58 @@
+package jb
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/bsv-blockchain/go-sdk/transaction"
+	"github.com/redis/go-redis/v9"
+	"github.com/shruggr/1sat-indexer/v5/blk"
+)
+
+// CacheSource caches raw transactions and proofs from another TxSource in Redis
+type CacheSource struct {
+	Cache  *redis.Client
+	Source TxSource
+}
+
+func (s *CacheSource) LoadRawtx(ctx context.Context, txid string) (rawtx []byte, err error) {
+	cacheKey := TxKey(txid)
+	if rawtx, _ = s.Cache.Get(ctx, cacheKey).Bytes(); len(rawtx) > 0 {
+		if _, err := transaction.NewTransactionFromBytes(rawtx); err == nil {
+			return rawtx, nil
+		}
+		log.Println("fixing bad cache", txid)
+		s.Cache.Del(ctx, cacheKey)
+	}
+	if rawtx, err = s.Source.LoadRawtx(ctx, txid); err != nil {
+		return nil, err
+	} else if len(rawtx) > 0 {
+		s.Cache.Set(ctx, cacheKey, rawtx, 0)
+	}
+	return
+}
+
+func (s *CacheSource) LoadProof(ctx context.Context, txid string) (proof *transaction.MerklePath, err error) {
+	cacheKey := ProofKey(txid)
+	if prf, _ := s.Cache.Get(ctx, cacheKey).Bytes(); len(prf) > 0 {
+		return transaction.NewMerklePathFromBinary(prf)
+	} else if proof, err = s.Source.LoadProof(ctx, txid); err != nil || proof == nil {
+		return
+	} else if chaintip, err := blk.GetChaintip(ctx); err != nil {
+		return nil, err
+	} else if proof.BlockHeight+5 < uint32(chaintip.Height) {
+		s.Cache.Set(ctx, cacheKey, proof.Bytes(), 0)
+	} else {
+		s.Cache.Set(ctx, cacheKey, proof.Bytes(), time.Hour)
+	}
+	return
+}
+
+func (s *CacheSource) GetSpend(ctx context.Context, outpoint string) (string, error) {
+	return s.Source.GetSpend(ctx, outpoint)
+}
+
+func (s *CacheSource) FetchOwnerTxns(ctx context.Context, address string, lastHeight int) ([]*AddressTxn, error) {
+	return s.Source.FetchOwnerTxns(ctx, address, lastHeight)
+}
@@ -0,0 +1,152 @@
+package jb
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bsv-blockchain/go-sdk/transaction"
+	"github.com/shruggr/1sat-indexer/v5/metrics"
+)
+
+// JungleBusSource loads transactions, proofs, spends and address history from a JungleBus server
+type JungleBusSource struct {
+	URL string
+}
+
+type InFlight struct {
+	Result []byte
+	Err    error
+	Wg     sync.WaitGroup
+}
+
+var inflightMap = map[string]*InFlight{}
+var inflightM sync.Mutex
+
+// fetch dedupes concurrent requests for the same url
+func fetch(url string) (result []byte, err error) {
+	inflightM.Lock()
+	inflight, ok := inflightMap[url]
+	if !ok {
+		inflight = &InFlight{}
+		inflight.Wg.Add(1)
+		inflightMap[url] = inflight
+	}
+	inflightM.Unlock()
+	if ok {
+		inflight.Wg.Wait()
+		return inflight.Result, inflight.Err
+	}
+
+	defer func() {
+		inflight.Result = result
+		inflight.Err = err
+		inflight.Wg.Done()
+
+		inflightM.Lock()
+		delete(inflightMap, url)
+		inflightM.Unlock()
+	}()
+	if resp, err := http.Get(url); err != nil {
+		return nil, err
+	} else {
+		defer resp.Body.Close()
+		if resp.StatusCode == 404 {
+			return nil, ErrNotFound
+		} else if resp.StatusCode != 200 {
+			return nil, fmt.Errorf("%d %s", resp.StatusCode, url)
+		}
+		return io.ReadAll(resp.Body)
+	}
+}
+
+// observe records a JungleBus request. Not-found responses are answers, not failures.
+func observe(op string, start time.Time, err error) {
+	if err == ErrNotFound {
+		err = nil
+	}
+	metrics.ObserveRequest(metrics.ServiceJungleBus, op, start, err)
+}
+
+func (s *JungleBusSource) LoadRawtx(ctx context.Context, txid string) (rawtx []byte, err error) {
+	start := time.Now()
+	defer func() { observe("rawtx", start, err) }()
+	if rawtx, err = fetch(fmt.Sprintf("%s/v1/transaction/get/%s/bin", s.URL, txid)); err != nil {
+		log.Println("JB Err", txid, err)
+	} else if len(rawtx) == 0 {
+		log.Println("JB Missing", txid)
+		return nil, ErrNotFound
+	}
+	return
+}
+
+func (s *JungleBusSource) LoadProof(ctx context.Context, txid string) (*transaction.MerklePath, error) {
+	start := time.Now()
+	prf, err := fetch(fmt.Sprintf("%s/v1/transaction/proof/%s/bin", s.URL, txid))
+	observe("proof", start, err)
+	if err != nil {
+		if err == ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	} else if len(prf) == 0 {
+		return nil, nil
+	} else {
+		return transaction.NewMerklePathFromBinary(prf)
+	}
+}
+
+func (s *JungleBusSource) GetSpend(ctx context.Context, outpoint string) (spend string, err error) {
+	start := time.Now()
+	defer func() { observe("spend", start, err) }()
+	url := fmt.Sprintf("%s/v1/txo/spend/%s", s.URL, outpoint)
+	resp, err := http.Get(url)
+	if err != nil {
+		log.Println("JB Spend Request", err)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		err = fmt.Errorf("missing-spend-%s", outpoint)
+		return
+	}
+	var b []byte
+	if b, err = io.ReadAll(resp.Body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (s *JungleBusSource) FetchOwnerTxns(ctx context.Context, address string, lastHeight int) (txns []*AddressTxn, err error) {
+	if address == "" {
+		return
+	}
+	start := time.Now()
+	defer func() { observe("address", start, err) }()
+	url := fmt.Sprintf("%s/v1/address/get/%s/%d", s.URL, address, lastHeight)
+	if resp, err := http.Get(url); err != nil {
+		return nil, err
+	} else {
+		defer resp.Body.Close()
+		switch resp.StatusCode {
+		case 200:
+			if err := json.NewDecoder(resp.Body).Decode(&txns); err != nil {
+				return nil, err
+			}
+		case 400:
+			return nil, ErrBadRequest
+		case 404:
+			return nil, ErrNotFound
+		default:
+			return nil, fmt.Errorf("bad status %d from %s", resp.StatusCode, url)
+		}
+	}
+	return
+}
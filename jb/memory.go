@@ -0,0 +1,135 @@
+package jb
+
+import (
+	"context"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/bsv-blockchain/go-sdk/transaction"
+	"github.com/shruggr/1sat-indexer/v5/lib"
+)
+
+// MemorySource serves transactions, proofs, spends and address history held in memory.
+// It can be populated from a directory of fixtures with LoadDir.
+type MemorySource struct {
+	mu      sync.RWMutex
+	rawtxs  map[string][]byte
+	proofs  map[string]*transaction.MerklePath
+	spends  map[string]string
+	history map[string][]*AddressTxn
+}
+
+func NewMemorySource() *MemorySource {
+	return &MemorySource{
+		rawtxs:  make(map[string][]byte),
+		proofs:  make(map[string]*transaction.MerklePath),
+		spends:  make(map[string]string),
+		history: make(map[string][]*AddressTxn),
+	}
+}
+
+// AddTx stores the transaction and its merkle path, along with any source
+// transactions attached to its inputs, and records the spends of its inputs
+func (s *MemorySource) AddTx(tx *transaction.Transaction) {
+	txid := tx.TxID().String()
+	s.mu.Lock()
+	s.rawtxs[txid] = tx.Bytes()
+	if tx.MerklePath != nil {
+		s.proofs[txid] = tx.MerklePath
+	}
+	if !tx.IsCoinbase() {
+		for _, txin := range tx.Inputs {
+			s.spends[lib.NewOutpointFromHash(txin.SourceTXID, txin.SourceTxOutIndex).String()] = txid
+		}
+	}
+	s.mu.Unlock()
+	for _, txin := range tx.Inputs {
+		if txin.SourceTransaction != nil {
+			s.AddTx(txin.SourceTransaction)
+		}
+	}
+}
+
+func (s *MemorySource) AddOwnerTxn(txn *AddressTxn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.history[txn.Address] = append(s.history[txn.Address], txn)
+}
+
+// LoadDir loads every fixture in dir. Files named <txid>.hex or <txid>.bin hold a
+// raw transaction, <txid>.beef holds a BEEF encoded transaction with its ancestors.
+func (s *MemorySource) LoadDir(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		ext := filepath.Ext(entry.Name())
+		if ext != ".hex" && ext != ".bin" && ext != ".beef" {
+			continue
+		}
+		b, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		if ext == ".hex" {
+			if b, err = hex.DecodeString(strings.TrimSpace(string(b))); err != nil {
+				return err
+			}
+		} else if ext == ".beef" {
+			if decoded, err := hex.DecodeString(strings.TrimSpace(string(b))); err == nil {
+				b = decoded
+			}
+		}
+		var tx *transaction.Transaction
+		if ext == ".beef" {
+			tx, err = transaction.NewTransactionFromBEEF(b)
+		} else {
+			tx, err = transaction.NewTransactionFromBytes(b)
+		}
+		if err != nil {
+			return err
+		}
+		s.AddTx(tx)
+	}
+	return nil
+}
+
+func (s *MemorySource) LoadRawtx(ctx context.Context, txid string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if rawtx, ok := s.rawtxs[txid]; ok {
+		return rawtx, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (s *MemorySource) LoadProof(ctx context.Context, txid string) (*transaction.MerklePath, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.proofs[txid], nil
+}
+
+func (s *MemorySource) GetSpend(ctx context.Context, outpoint string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.spends[outpoint], nil
+}
+
+func (s *MemorySource) FetchOwnerTxns(ctx context.Context, address string, lastHeight int) ([]*AddressTxn, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	txns := make([]*AddressTxn, 0, len(s.history[address]))
+	for _, txn := range s.history[address] {
+		if int(txn.Height) >= lastHeight {
+			txns = append(txns, txn)
+		}
+	}
+	return txns, nil
+}
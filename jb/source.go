@@ -0,0 +1,105 @@
+package jb
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bsv-blockchain/go-sdk/transaction"
+)
+
+var ErrUnsupported = errors.New("unsupported")
+
+// TxSource provides the chain data the indexer needs about transactions it does not hold itself
+type TxSource interface {
+	LoadRawtx(ctx context.Context, txid string) ([]byte, error)
+	LoadProof(ctx context.Context, txid string) (*transaction.MerklePath, error)
+	GetSpend(ctx context.Context, outpoint string) (string, error)
+	FetchOwnerTxns(ctx context.Context, address string, lastHeight int) ([]*AddressTxn, error)
+}
+
+// Source is the default TxSource configured from the environment
+var Source TxSource
+
+func LoadTxFrom(ctx context.Context, src TxSource, txid string, withProof bool) (tx *transaction.Transaction, err error) {
+	if src == nil {
+		return nil, ErrNotFound
+	} else if rawtx, err := src.LoadRawtx(ctx, txid); err != nil {
+		return nil, err
+	} else if len(rawtx) == 0 {
+		return nil, ErrNotFound
+	} else if tx, err = transaction.NewTransactionFromBytes(rawtx); err != nil {
+		return nil, ErrMalformed
+	}
+
+	if withProof {
+		if proof, err := src.LoadProof(ctx, txid); err == nil && proof != nil {
+			tx.MerklePath = proof
+		}
+	}
+	return tx, nil
+}
+
+// GetSpendFrom returns the txid spending outpoint according to src. Sources that
+// cannot look up spends report no spend rather than an error.
+func GetSpendFrom(ctx context.Context, src TxSource, outpoint string) (spend string, err error) {
+	if src == nil {
+		return "", nil
+	} else if spend, err = src.GetSpend(ctx, outpoint); err == ErrUnsupported {
+		return "", nil
+	}
+	return spend, err
+}
+
+// MultiSource queries each source in order, returning the first result found
+type MultiSource []TxSource
+
+func (m MultiSource) LoadRawtx(ctx context.Context, txid string) (rawtx []byte, err error) {
+	err = ErrNotFound
+	for _, src := range m {
+		if raw, e := src.LoadRawtx(ctx, txid); e == nil && len(raw) > 0 {
+			return raw, nil
+		} else if e != nil && e != ErrNotFound && e != ErrUnsupported {
+			err = e
+		}
+	}
+	return
+}
+
+func (m MultiSource) LoadProof(ctx context.Context, txid string) (proof *transaction.MerklePath, err error) {
+	for _, src := range m {
+		if proof, err = src.LoadProof(ctx, txid); err == nil && proof != nil {
+			return
+		}
+	}
+	return nil, err
+}
+
+func (m MultiSource) GetSpend(ctx context.Context, outpoint string) (spend string, err error) {
+	err = ErrUnsupported
+	for _, src := range m {
+		if s, e := src.GetSpend(ctx, outpoint); e == nil {
+			if s != "" {
+				return s, nil
+			}
+			err = nil
+		} else if e != ErrUnsupported && err == ErrUnsupported {
+			err = e
+		}
+	}
+	return
+}
+
+func (m MultiSource) FetchOwnerTxns(ctx context.Context, address string, lastHeight int) (txns []*AddressTxn, err error) {
+	err = ErrUnsupported
+	for _, src := range m {
+		if t, e := src.FetchOwnerTxns(ctx, address, lastHeight); e == nil {
+			if len(t) > 0 {
+				return t, nil
+			}
+			err = nil
+		} else if e != ErrUnsupported && err == ErrUnsupported {
+			err = e
+		}
+	}
+	return
+}
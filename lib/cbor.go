@@ -0,0 +1,199 @@
+package lib
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+)
+
+var ErrCBOR = errors.New("invalid cbor")
+
+const cborMaxDepth = 32
+
+// DecodeCBOR decodes a single CBOR item into values encoding/json can marshal.
+// Map keys are converted to strings, byte string keys hex encoded. Tags are dropped and
+// byte strings are kept as []byte.
+func DecodeCBOR(b []byte) (any, error) {
+	d := &cborDecoder{buf: b}
+	if v, err := d.decode(0); err != nil {
+		return nil, err
+	} else if d.pos != len(b) {
+		return nil, fmt.Errorf("%w: trailing bytes", ErrCBOR)
+	} else {
+		return v, nil
+	}
+}
+
+type cborDecoder struct {
+	buf []byte
+	pos int
+}
+
+func (d *cborDecoder) read(n uint64) ([]byte, error) {
+	if n > uint64(len(d.buf)-d.pos) {
+		return nil, fmt.Errorf("%w: unexpected end", ErrCBOR)
+	}
+	b := d.buf[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+// head reads an item header, returning its major type, additional info and
+// argument. Info 31 marks the streaming form of strings, arrays and maps.
+func (d *cborDecoder) head() (major byte, info byte, arg uint64, err error) {
+	var b []byte
+	if b, err = d.read(1); err != nil {
+		return
+	}
+	major = b[0] >> 5
+	info = b[0] & 0x1f
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info == 24:
+		if b, err = d.read(1); err == nil {
+			arg = uint64(b[0])
+		}
+	case info == 25:
+		if b, err = d.read(2); err == nil {
+			arg = uint64(binary.BigEndian.Uint16(b))
+		}
+	case info == 26:
+		if b, err = d.read(4); err == nil {
+			arg = uint64(binary.BigEndian.Uint32(b))
+		}
+	case info == 27:
+		if b, err = d.read(8); err == nil {
+			arg = binary.BigEndian.Uint64(b)
+		}
+	case info == 31 && major >= 2 && major <= 5:
+	default:
+		err = fmt.Errorf("%w: additional info %d", ErrCBOR, info)
+	}
+	return
+}
+
+func (d *cborDecoder) isBreak() bool {
+	if d.pos < len(d.buf) && d.buf[d.pos] == 0xff {
+		d.pos++
+		return true
+	}
+	return false
+}
+
+func (d *cborDecoder) decode(depth int) (any, error) {
+	if depth > cborMaxDepth {
+		return nil, fmt.Errorf("%w: nested too deep", ErrCBOR)
+	}
+	major, info, arg, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	indefinite := info == 31
+	switch major {
+	case 0:
+		return arg, nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return -1 - float64(arg), nil
+		}
+		return -1 - int64(arg), nil
+	case 2, 3:
+		var s []byte
+		if !indefinite {
+			if s, err = d.read(arg); err != nil {
+				return nil, err
+			}
+		} else {
+			for !d.isBreak() {
+				if chunkMajor, chunkInfo, n, err := d.head(); err != nil {
+					return nil, err
+				} else if chunkMajor != major || chunkInfo == 31 {
+					return nil, fmt.Errorf("%w: bad string chunk", ErrCBOR)
+				} else if chunk, err := d.read(n); err != nil {
+					return nil, err
+				} else {
+					s = append(s, chunk...)
+				}
+			}
+		}
+		if major == 3 {
+			return string(s), nil
+		}
+		return append([]byte{}, s...), nil
+	case 4:
+		arr := make([]any, 0)
+		for i := uint64(0); indefinite || i < arg; i++ {
+			if indefinite && d.isBreak() {
+				break
+			} else if v, err := d.decode(depth + 1); err != nil {
+				return nil, err
+			} else {
+				arr = append(arr, v)
+			}
+		}
+		return arr, nil
+	case 5:
+		m := make(map[string]any)
+		for i := uint64(0); indefinite || i < arg; i++ {
+			if indefinite && d.isBreak() {
+				break
+			} else if k, err := d.decode(depth + 1); err != nil {
+				return nil, err
+			} else if v, err := d.decode(depth + 1); err != nil {
+				return nil, err
+			} else {
+				switch key := k.(type) {
+				case string:
+					m[key] = v
+				case []byte:
+					m[hex.EncodeToString(key)] = v
+				default:
+					m[fmt.Sprint(k)] = v
+				}
+			}
+		}
+		return m, nil
+	case 6:
+		return d.decode(depth + 1)
+	default:
+		switch {
+		case info == 25:
+			return halfToFloat(uint16(arg)), nil
+		case info == 26:
+			return float64(math.Float32frombits(uint32(arg))), nil
+		case info == 27:
+			return math.Float64frombits(arg), nil
+		case arg == 20:
+			return false, nil
+		case arg == 21:
+			return true, nil
+		case arg == 22 || arg == 23:
+			return nil, nil
+		}
+		return arg, nil
+	}
+}
+
+func halfToFloat(h uint16) float64 {
+	exp := int(h>>10) & 0x1f
+	mant := float64(h & 0x3ff)
+	var v float64
+	if exp == 0 {
+		v = math.Ldexp(mant, -24)
+	} else if exp == 31 {
+		if mant == 0 {
+			v = math.Inf(1)
+		} else {
+			v = math.NaN()
+		}
+	} else {
+		v = math.Ldexp(mant+1024, exp-25)
+	}
+	if h&0x8000 != 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,79 @@
+package lib
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeCBOR(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+		want any
+	}{
+		{"uint", []byte{0x17}, uint64(23)},
+		{"uint8", []byte{0x18, 0x18}, uint64(24)},
+		{"uint16", []byte{0x19, 0x01, 0x00}, uint64(256)},
+		{"uint32", []byte{0x1a, 0x00, 0x01, 0x00, 0x00}, uint64(65536)},
+		{"uint64", []byte{0x1b, 0, 0, 0, 0x01, 0, 0, 0, 0}, uint64(1 << 32)},
+		{"negative", []byte{0x38, 0x63}, int64(-100)},
+		{"negative beyond int64", []byte{0x3b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, -1 - float64(math.MaxUint64)},
+		{"bytes", []byte{0x43, 0x01, 0x02, 0x03}, []byte{1, 2, 3}},
+		{"indefinite bytes", []byte{0x5f, 0x41, 0x01, 0x42, 0x02, 0x03, 0xff}, []byte{1, 2, 3}},
+		{"text", []byte{0x64, 'I', 'E', 'T', 'F'}, "IETF"},
+		{"indefinite text", []byte{0x7f, 0x65, 's', 't', 'r', 'e', 'a', 0x64, 'm', 'i', 'n', 'g', 0xff}, "streaming"},
+		{"array", []byte{0x83, 0x01, 0x02, 0x03}, []any{uint64(1), uint64(2), uint64(3)}},
+		{"indefinite array", []byte{0x9f, 0x01, 0x82, 0x02, 0x03, 0xff}, []any{uint64(1), []any{uint64(2), uint64(3)}}},
+		{"map", []byte{0xa2, 0x61, 'a', 0x01, 0x61, 'b', 0x82, 0x02, 0x03}, map[string]any{"a": uint64(1), "b": []any{uint64(2), uint64(3)}}},
+		{"indefinite map", []byte{0xbf, 0x63, 'F', 'u', 'n', 0xf5, 0xff}, map[string]any{"Fun": true}},
+		{"integer key", []byte{0xa1, 0x01, 0x02}, map[string]any{"1": uint64(2)}},
+		{"byte string key", []byte{0xa1, 0x43, 0x01, 0x02, 0x03, 0x61, 'x'}, map[string]any{"010203": "x"}},
+		{"tag", []byte{0xc1, 0x1a, 0x51, 0x4b, 0x67, 0xb0}, uint64(1363896240)},
+		{"false", []byte{0xf4}, false},
+		{"true", []byte{0xf5}, true},
+		{"null", []byte{0xf6}, nil},
+		{"undefined", []byte{0xf7}, nil},
+		{"half float", []byte{0xf9, 0x3c, 0x00}, 1.0},
+		{"half float infinity", []byte{0xf9, 0x7c, 0x00}, math.Inf(1)},
+		{"float32", []byte{0xfa, 0x47, 0xc3, 0x50, 0x00}, 100000.0},
+		{"float64", []byte{0xfb, 0x3f, 0xf1, 0x99, 0x99, 0x99, 0x99, 0x99, 0x9a}, 1.1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := DecodeCBOR(tt.in)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, v)
+		})
+	}
+}
+
+func TestDecodeCBORInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		in   []byte
+	}{
+		{"empty", []byte{}},
+		{"truncated argument", []byte{0x19, 0x01}},
+		{"truncated bytes", []byte{0x43, 0x01, 0x02}},
+		{"truncated array", []byte{0x83, 0x01, 0x02}},
+		{"truncated map", []byte{0xa1, 0x61, 'a'}},
+		{"unterminated indefinite bytes", []byte{0x5f, 0x41, 0x01}},
+		{"unterminated indefinite array", []byte{0x9f, 0x01}},
+		{"mismatched chunk", []byte{0x5f, 0x61, 'a', 0xff}},
+		{"nested chunk", []byte{0x5f, 0x5f, 0xff, 0xff}},
+		{"reserved info", []byte{0x1c}},
+		{"indefinite integer", []byte{0x1f}},
+		{"lone break", []byte{0xff}},
+		{"trailing bytes", []byte{0x01, 0x02}},
+		{"nested too deep", append(bytes.Repeat([]byte{0x81}, cborMaxDepth+1), 0x01)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeCBOR(tt.in)
+			assert.ErrorIs(t, err, ErrCBOR)
+		})
+	}
+}
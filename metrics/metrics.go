@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "onesat"
+
+var (
+	TxsIngested = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "txs_ingested_total",
+		Help:      "Transactions ingested, by ingest tag",
+	}, []string{"tag"})
+
+	IngestErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ingest_errors_total",
+		Help:      "Failed transaction ingests, by ingest tag and error kind",
+	}, []string{"tag", "kind"})
+
+	IngestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "ingest_duration_seconds",
+		Help:      "Time to parse and save a transaction, by ingest tag",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"tag"})
+
+	QueueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "queue_depth",
+		Help:      "Transactions waiting in an ingest queue, by queue key",
+	}, []string{"queue"})
+
+	ParseDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "indexer_parse_duration_seconds",
+		Help:      "Time spent parsing a single output, by indexer tag",
+		Buckets:   []float64{.00001, .00005, .0001, .0005, .001, .005, .01, .05, .1},
+	}, []string{"indexer"})
+
+	RequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_request_duration_seconds",
+		Help:      "Latency of requests to upstream services, by service and operation",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"service", "op"})
+
+	RequestErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "upstream_request_errors_total",
+		Help:      "Failed requests to upstream services, by service and operation",
+	}, []string{"service", "op"})
+
+	AuditOutcomes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "audit_outcomes_total",
+		Help:      "Results of auditing pending transactions, by outcome",
+	}, []string{"outcome"})
+
+	APIDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "api_request_duration_seconds",
+		Help:      "Latency of API requests, by method, route and status",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	SSESessions = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "sse_sessions",
+		Help:      "Open server-sent event sessions",
+	})
+
+	StoreDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "store_operation_duration_seconds",
+		Help:      "Latency of TxoStore operations, by operation",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"op"})
+
+	StoreErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "store_operation_errors_total",
+		Help:      "Failed TxoStore operations, by operation",
+	}, []string{"op"})
+)
+
+const (
+	ServiceJungleBus = "junglebus"
+	ServiceArc       = "arc"
+)
+
+const (
+	AuditArchived    = "archived"
+	AuditRolledBack  = "rolled_back"
+	AuditRemoved     = "removed"
+	AuditReingested  = "reingested"
+	AuditRepublished = "republished"
+)
+
+// ObserveRequest records the latency and outcome of an upstream request started at start
+func ObserveRequest(service string, op string, start time.Time, err error) {
+	RequestDuration.WithLabelValues(service, op).Observe(time.Since(start).Seconds())
+	if err != nil {
+		RequestErrors.WithLabelValues(service, op).Inc()
+	}
+}
+
+// ObserveStore records the latency and outcome of a store operation started at start
+func ObserveStore(op string, start time.Time, err error) {
+	StoreDuration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+	if err != nil {
+		StoreErrors.WithLabelValues(op).Inc()
+	}
+}
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Serve exposes /metrics on the address in METRICS_ADDR, if set. It is used by
+// binaries which do not otherwise run an HTTP server.
+func Serve() {
+	addr := os.Getenv("METRICS_ADDR")
+	if addr == "" {
+		return
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	go func() {
+		log.Println("Metrics listening on", addr)
+		if err := http.ListenAndServe(addr, mux); err != nil {
+			log.Println("Metrics server error:", err)
+		}
+	}()
+}
@@ -0,0 +1,53 @@
+package bitcom
+
+import (
+	"testing"
+
+	"github.com/shruggr/1sat-indexer/v5/idxtest"
+	"github.com/shruggr/1sat-indexer/v5/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+func harness(t *testing.T) *idxtest.Harness {
+	return idxtest.New(t,
+		&BitcomIndexer{},
+		&BIndexer{},
+		&MapIndexer{},
+		&SigmaIndexer{},
+	)
+}
+
+func TestB(t *testing.T) {
+	h := harness(t)
+	idxCtx := h.Parse("bitcom")
+
+	b := h.Data(idxCtx, 0, "b").Data.(*lib.File)
+	assert.Equal(t, "Hello, bitcom", string(b.Content))
+	assert.Equal(t, "text/plain", b.Type)
+	assert.Equal(t, "utf-8", b.Encoding)
+	assert.Equal(t, "hello.txt", b.Name)
+	assert.Equal(t, uint32(13), b.Size)
+}
+
+func TestMap(t *testing.T) {
+	h := harness(t)
+	idxCtx := h.Parse("bitcom")
+
+	mp := h.Data(idxCtx, 0, MAP_TAG).Data.(Map)
+	assert.Equal(t, Map{"app": "idxtest", "type": "post"}, mp)
+	assert.NotContains(t, idxCtx.Txos[0].Data, BITCOM_TAG)
+}
+
+func TestSigma(t *testing.T) {
+	h := harness(t)
+	idxCtx := h.Parse("bitcom")
+
+	sigmas := h.Data(idxCtx, 0, "sigma").Data.(Sigmas)
+	assert.Len(t, sigmas, 1)
+	assert.Equal(t, "BSM", sigmas[0].Algorithm)
+	assert.Equal(t, "1Fj8GVwfV6GnjpqN7t8GvxugKN4SAyER9X", sigmas[0].Address)
+	assert.Equal(t, uint32(0), sigmas[0].Vin)
+	assert.True(t, sigmas[0].Valid)
+
+	h.Golden("bitcom", idxCtx)
+}
@@ -0,0 +1,309 @@
+package cosign
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	ec "github.com/bsv-blockchain/go-sdk/primitives/ec"
+	"github.com/bsv-blockchain/go-sdk/transaction"
+	"github.com/shruggr/1sat-indexer/v5/idx"
+	"github.com/shruggr/1sat-indexer/v5/jb"
+	"github.com/shruggr/1sat-indexer/v5/lib"
+	"github.com/shruggr/1sat-indexer/v5/mod/onesat"
+)
+
+var (
+	ErrNotCosigned = errors.New("no inputs cosigned by approver")
+	ErrUnsigned    = errors.New("input not signed by owner")
+	ErrRejected    = errors.New("rejected by policy")
+)
+
+// Request is a partially signed transaction awaiting approval
+type Request struct {
+	IdxCtx *idx.IndexContext
+	// Inputs are the vins locked to the approver, keyed to their owner address
+	Inputs map[uint32]string
+	// Spent holds the satoshis each owner sends to other addresses
+	Spent map[string]uint64
+	// Sent splits each owner's Spent across their cosigned inputs, in vin order
+	Sent map[uint32]uint64
+	// Tokens holds the BSV21 amount each owner sends to other addresses, by token id
+	Tokens map[string]map[string]uint64
+}
+
+// Destinations returns the outputs that do not pay back to an owner of a cosigned input
+func (r *Request) Destinations() []*idx.Txo {
+	var txos []*idx.Txo
+	for _, txo := range r.IdxCtx.Txos {
+		if !r.isChange(txo) {
+			txos = append(txos, txo)
+		}
+	}
+	return txos
+}
+
+func (r *Request) isChange(txo *idx.Txo) bool {
+	for _, owner := range txo.Owners {
+		for _, inputOwner := range r.Inputs {
+			if owner == inputOwner {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Outpoint returns the outpoint spent by the cosigned input vin
+func (r *Request) Outpoint(vin uint32) string {
+	return r.IdxCtx.Spends[vin].Outpoint.String()
+}
+
+// PolicyFn rejects a request by returning an error
+type PolicyFn func(ctx context.Context, req *Request) error
+
+// Approver holds a cosigner key and countersigns the inputs locked to it
+// once every policy passes
+type Approver struct {
+	Key      *ec.PrivateKey
+	Ingest   *idx.IngestCtx
+	Policies []PolicyFn
+	// mu holds concurrent approvals back until the spends checked by the
+	// policies are recorded
+	mu sync.Mutex
+}
+
+func NewApprover(key *ec.PrivateKey, ingest *idx.IngestCtx, policies ...PolicyFn) *Approver {
+	return &Approver{
+		Key:      key,
+		Ingest:   ingest,
+		Policies: policies,
+	}
+}
+
+// Cosigner returns the hex public key cosign locks name to be approved by a
+func (a *Approver) Cosigner() string {
+	return hex.EncodeToString(a.Key.PubKey().Compressed())
+}
+
+// Approve checks tx against the policies and adds the approver signature to
+// each input locked to the approver, which must already carry the owner's
+// signature and public key
+func (a *Approver) Approve(ctx context.Context, tx *transaction.Transaction) (*Request, error) {
+	if err := a.loadSources(ctx, tx); err != nil {
+		return nil, err
+	}
+	idxCtx, err := a.Ingest.ParseTx(ctx, tx, idx.AncestorConfig{Load: true, Parse: true})
+	if err != nil {
+		return nil, err
+	}
+	req := &Request{
+		IdxCtx: idxCtx,
+		Inputs: make(map[uint32]string),
+		Spent:  make(map[string]uint64),
+		Sent:   make(map[uint32]uint64),
+		Tokens: make(map[string]map[string]uint64),
+	}
+	cosigner := a.Cosigner()
+	for vin, input := range tx.Inputs {
+		if cosign := parseScript(input.SourceTxOutput().LockingScript); cosign == nil || cosign.Cosigner != cosigner {
+			continue
+		} else if input.UnlockingScript == nil {
+			return nil, fmt.Errorf("%w: %d", ErrUnsigned, vin)
+		} else if chunks, err := input.UnlockingScript.Chunks(); err != nil || len(chunks) != 2 {
+			return nil, fmt.Errorf("%w: %d", ErrUnsigned, vin)
+		} else {
+			req.Inputs[uint32(vin)] = cosign.Address
+		}
+	}
+	if len(req.Inputs) == 0 {
+		return nil, ErrNotCosigned
+	}
+	req.tally()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, policy := range a.Policies {
+		if err := policy(ctx, req); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrRejected, err)
+		}
+	}
+
+	for vin := range req.Inputs {
+		input := tx.Inputs[vin]
+		if unlock, err := ApproverUnlock(a.Key, input.UnlockingScript, nil); err != nil {
+			return nil, err
+		} else if input.UnlockingScript, err = unlock.Sign(tx, vin); err != nil {
+			return nil, err
+		}
+	}
+	if err := recordSpends(ctx, a.Ingest.Store, req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// loadSources attaches the source transaction of each input that does not
+// already carry one, so the approver can sign it
+func (a *Approver) loadSources(ctx context.Context, tx *transaction.Transaction) error {
+	for vin, input := range tx.Inputs {
+		if input.SourceTxOutput() != nil {
+			continue
+		}
+		outpoint := lib.NewOutpointFromHash(input.SourceTXID, input.SourceTxOutIndex)
+		if sourceTx, err := jb.LoadTxFrom(ctx, a.Ingest.TxSource(), outpoint.TxidHex(), false); err != nil {
+			return fmt.Errorf("input %d: %w", vin, err)
+		} else if sourceTx == nil || int(input.SourceTxOutIndex) >= len(sourceTx.Outputs) {
+			return fmt.Errorf("input %d: %w", vin, jb.ErrNotFound)
+		} else {
+			input.SourceTransaction = sourceTx
+		}
+	}
+	return nil
+}
+
+// tally totals the satoshis and tokens leaving each owner's cosigned inputs
+func (r *Request) tally() {
+	for vin, owner := range r.Inputs {
+		spend := r.IdxCtx.Spends[vin]
+		r.Spent[owner] += r.IdxCtx.Tx.Inputs[vin].SourceTxOutput().Satoshis
+		if token := bsv21(spend); token != nil {
+			if r.Tokens[owner] == nil {
+				r.Tokens[owner] = make(map[string]uint64)
+			}
+			r.Tokens[owner][token.Id] += token.Amt
+		}
+	}
+	for _, txo := range r.IdxCtx.Txos {
+		for _, owner := range txo.Owners {
+			if _, ok := r.Spent[owner]; !ok {
+				continue
+			}
+			r.Spent[owner] -= min(r.Spent[owner], *txo.Satoshis)
+			if token := bsv21(txo); token != nil && r.Tokens[owner] != nil {
+				r.Tokens[owner][token.Id] -= min(r.Tokens[owner][token.Id], token.Amt)
+			}
+			break
+		}
+	}
+	remaining := maps.Clone(r.Spent)
+	for _, vin := range slices.Sorted(maps.Keys(r.Inputs)) {
+		owner := r.Inputs[vin]
+		sent := min(remaining[owner], r.IdxCtx.Tx.Inputs[vin].SourceTxOutput().Satoshis)
+		r.Sent[vin] = sent
+		remaining[owner] -= sent
+	}
+}
+
+func bsv21(txo *idx.Txo) *onesat.Bsv21 {
+	if data, ok := txo.Data[onesat.BSV21_TAG]; ok {
+		if token, ok := data.Data.(*onesat.Bsv21); ok && token.Id != "" {
+			return token
+		}
+	}
+	return nil
+}
+
+// SpendKey holds the cosigned inputs of owner spent by approved transactions,
+// scored by the time they were last approved
+func SpendKey(owner string) string {
+	return "cosign:spend:" + owner
+}
+
+// spentKey holds the satoshis each approved spend of a cosigned input sent
+// away from owner
+func spentKey(owner string) string {
+	return "cosign:spent:" + owner
+}
+
+// ReservationTTL is how long an approved spend counts against the limits before
+// the signed transaction is seen on ingest. Approvals which are never broadcast
+// stop counting once it passes.
+var ReservationTTL = 10 * time.Minute
+
+// counted reports whether the approved spend of a cosigned input still counts: the
+// input was spent by an ingested transaction, which only an approval can sign, or
+// it was approved, at the given time, within ReservationTTL
+func counted(ctx context.Context, store idx.TxoStore, outpoint string, approved float64) (bool, error) {
+	if approved > float64(time.Now().Add(-ReservationTTL).UnixNano()) {
+		return true, nil
+	} else if spend, err := store.GetSpend(ctx, outpoint, false); err != nil {
+		return false, err
+	} else {
+		return spend != "", nil
+	}
+}
+
+// spendRecord returns the satoshis to record for a cosigned input sending sent,
+// which never lowers an earlier record that still counts, and how much that raises
+// the total approved since the given time. Transactions spending the same input
+// conflict, so only the largest of them is counted.
+func spendRecord(ctx context.Context, store idx.TxoStore, owner string, outpoint string, sent uint64, since time.Time) (record uint64, increase uint64, err error) {
+	prev, err := store.LogScore(ctx, spentKey(owner), outpoint)
+	if err != nil {
+		return 0, 0, err
+	}
+	approved, err := store.LogScore(ctx, SpendKey(owner), outpoint)
+	if err != nil {
+		return 0, 0, err
+	} else if ok, err := counted(ctx, store, outpoint, approved); err != nil {
+		return 0, 0, err
+	} else if !ok {
+		// The earlier approval expired without being broadcast
+		return sent, sent, nil
+	}
+	record = max(uint64(prev), sent)
+	if approved > float64(since.UnixNano()) {
+		return record, record - uint64(prev), nil
+	}
+	return record, record, nil
+}
+
+// recordSpends reserves the spends of an approved request. They count against
+// the limits for ReservationTTL, and from then on only once the signed
+// transaction is seen on ingest.
+func recordSpends(ctx context.Context, store idx.TxoStore, req *Request) error {
+	now := time.Now()
+	for vin, owner := range req.Inputs {
+		outpoint := req.Outpoint(vin)
+		if record, _, err := spendRecord(ctx, store, owner, outpoint, req.Sent[vin], now); err != nil {
+			return err
+		} else if err := store.Log(ctx, spentKey(owner), outpoint, float64(record)); err != nil {
+			return err
+		} else if err := store.Log(ctx, SpendKey(owner), outpoint, float64(now.UnixNano())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SpentSince returns the satoshis approved spends have sent away from
+// owner since the given time. Expired reservations are left out.
+func SpentSince(ctx context.Context, store idx.TxoStore, owner string, since time.Time) (uint64, error) {
+	from := float64(since.UnixNano())
+	spends, err := store.Search(ctx, &idx.SearchCfg{
+		Keys: []string{SpendKey(owner)},
+		From: &from,
+	})
+	if err != nil {
+		return 0, err
+	}
+	var total uint64
+	for _, spend := range spends {
+		if ok, err := counted(ctx, store, spend.Member, spend.Score); err != nil {
+			return 0, err
+		} else if !ok {
+			continue
+		} else if sats, err := store.LogScore(ctx, spentKey(owner), spend.Member); err != nil {
+			return 0, err
+		} else {
+			total += uint64(sats)
+		}
+	}
+	return total, nil
+}
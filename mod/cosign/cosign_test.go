@@ -0,0 +1,163 @@
+package cosign
+
+import (
+	"context"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	ec "github.com/bsv-blockchain/go-sdk/primitives/ec"
+	"github.com/bsv-blockchain/go-sdk/script"
+	"github.com/bsv-blockchain/go-sdk/script/interpreter"
+	"github.com/bsv-blockchain/go-sdk/transaction"
+	"github.com/bsv-blockchain/go-sdk/transaction/template/p2pkh"
+	"github.com/shruggr/1sat-indexer/v5/idxtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCosign(t *testing.T) {
+	h := idxtest.New(t, &CosignIndexer{})
+	idxCtx := h.Parse("cosign")
+
+	cosign := h.Data(idxCtx, 0, COSIGN_TAG).Data.(*Cosign)
+	assert.Equal(t, "1Fj8GVwfV6GnjpqN7t8GvxugKN4SAyER9X", cosign.Address)
+	assert.Len(t, cosign.Cosigner, 66)
+	assert.Equal(t, []string{
+		"evt:cosign:own:" + cosign.Address,
+		"evt:cosign:cosigner:" + cosign.Cosigner,
+	}, idxtest.Events(idxCtx, 0, COSIGN_TAG))
+	assert.Contains(t, idxCtx.Txos[0].Owners, cosign.Address)
+	assert.NotContains(t, idxCtx.Txos[1].Data, COSIGN_TAG)
+
+	h.Golden("cosign", idxCtx)
+}
+
+// cosign-mainnet is a mainnet BEEF of a BSV21 transfer to cosign locks
+// inscribed with the token amount
+func TestCosignMainnet(t *testing.T) {
+	h := idxtest.New(t, &CosignIndexer{})
+	idxCtx := h.Parse("cosign-mainnet")
+
+	cosigner := "020a177d6a5e6f3a8689acd2e313bd1cf0dcf5a243d1cc67b7218602aee9e04b2f"
+	for vout, address := range []string{
+		"1G6CB3Ch4zFkPmuhZzEyChQmrQPfi86qk3",
+		"19Vq2TV8aVhFNLQkhDMdnEQ7zT96x6F3PK",
+		"1HbKHqMvFiFoVMnoeKXjb2fD8DnWXshsVT",
+	} {
+		cosign := h.Data(idxCtx, uint32(vout), COSIGN_TAG).Data.(*Cosign)
+		assert.Equal(t, address, cosign.Address)
+		assert.Equal(t, cosigner, cosign.Cosigner)
+		assert.Equal(t, []string{address}, idxCtx.Txos[vout].Owners)
+	}
+	h.Golden("cosign-mainnet", idxCtx)
+}
+
+func TestApprover(t *testing.T) {
+	h := idxtest.New(t, &CosignIndexer{})
+	ctx := context.Background()
+	ownerKey, _ := ec.NewPrivateKey()
+	approverKey, _ := ec.NewPrivateKey()
+	ownerAddr, _ := script.NewAddressFromPublicKey(ownerKey.PubKey(), true)
+	destKey, _ := ec.NewPrivateKey()
+	destAddr, _ := script.NewAddressFromPublicKey(destKey.PubKey(), true)
+	cosignScript, _ := Lock(ownerAddr, approverKey.PubKey())
+	destScript, _ := p2pkh.Lock(destAddr)
+
+	fundTx := transaction.NewTransaction()
+	fundTx.AddOutput(&transaction.TransactionOutput{Satoshis: 1000, LockingScript: cosignScript})
+	fundTx.AddOutput(&transaction.TransactionOutput{Satoshis: 1000, LockingScript: cosignScript})
+	h.AddTx("fund", fundTx)
+	h.Ingest("fund")
+
+	spend := func(name string, vout uint32, sats uint64, dest *script.Script) *transaction.Transaction {
+		tx := transaction.NewTransaction()
+		tx.AddInputFromTx(fundTx, vout, nil)
+		tx.AddOutput(&transaction.TransactionOutput{Satoshis: sats, LockingScript: dest})
+		tx.AddOutput(&transaction.TransactionOutput{Satoshis: 900 - sats, LockingScript: cosignScript})
+		unlock, _ := OwnerUnlock(ownerKey, nil)
+		unlockScript, err := unlock.Sign(tx, 0)
+		assert.NoError(t, err)
+		tx.Inputs[0].UnlockingScript = unlockScript
+		h.AddTx(name, tx)
+		return tx
+	}
+
+	approver := NewApprover(approverKey, h.Ctx,
+		AllowDestinations(destAddr.AddressString),
+		SpendLimit(h.Store, 800, time.Hour),
+	)
+	assert.Equal(t, hex.EncodeToString(approverKey.PubKey().Compressed()), approver.Cosigner())
+
+	tx := spend("approved", 0, 500, destScript)
+	resubmit, _ := transaction.NewTransactionFromBytes(tx.Bytes())
+	req, err := approver.Approve(ctx, tx)
+	assert.NoError(t, err)
+	assert.Equal(t, map[uint32]string{0: ownerAddr.AddressString}, req.Inputs)
+	// The fee leaves the owner along with the payment
+	assert.Equal(t, uint64(600), req.Spent[ownerAddr.AddressString])
+	assert.NoError(t, interpreter.NewEngine().Execute(
+		interpreter.WithTx(tx, 0, fundTx.Outputs[0]),
+		interpreter.WithForkID(),
+		interpreter.WithAfterGenesis(),
+	))
+
+	// Approving the same spend again does not count against the limit
+	_, err = approver.Approve(ctx, resubmit)
+	assert.NoError(t, err)
+	spent, err := SpentSince(ctx, h.Store, ownerAddr.AddressString, time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(600), spent)
+
+	// A smaller spend of the same input does not lower what is recorded
+	_, err = approver.Approve(ctx, spend("lower", 0, 100, destScript))
+	assert.NoError(t, err)
+	spent, err = SpentSince(ctx, h.Store, ownerAddr.AddressString, time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(600), spent)
+
+	_, err = approver.Approve(ctx, spend("limit", 1, 300, destScript))
+	assert.ErrorIs(t, err, ErrRejected)
+
+	_, err = approver.Approve(ctx, spend("other", 1, 100, &script.Script{script.OpTRUE}))
+	assert.ErrorIs(t, err, ErrRejected)
+
+	unsigned := spend("unsigned", 1, 100, destScript)
+	unsigned.Inputs[0].UnlockingScript = nil
+	_, err = approver.Approve(ctx, unsigned)
+	assert.ErrorIs(t, err, ErrUnsigned)
+
+	_, err = NewApprover(destKey, h.Ctx).Approve(ctx, spend("stranger", 1, 100, destScript))
+	assert.ErrorIs(t, err, ErrNotCosigned)
+
+	// Spending both inputs is counted against each of them, whichever comes first
+	both := transaction.NewTransaction()
+	both.AddInputFromTx(fundTx, 1, nil)
+	both.AddInputFromTx(fundTx, 0, nil)
+	both.AddOutput(&transaction.TransactionOutput{Satoshis: 150, LockingScript: destScript})
+	both.AddOutput(&transaction.TransactionOutput{Satoshis: 1800, LockingScript: cosignScript})
+	unlock, _ := OwnerUnlock(ownerKey, nil)
+	for vin := range both.Inputs {
+		unlockScript, err := unlock.Sign(both, uint32(vin))
+		assert.NoError(t, err)
+		both.Inputs[vin].UnlockingScript = unlockScript
+	}
+	req, err = approver.Approve(ctx, both)
+	assert.NoError(t, err)
+	assert.Equal(t, map[uint32]uint64{0: 200, 1: 0}, req.Sent)
+	spent, err = SpentSince(ctx, h.Store, ownerAddr.AddressString, time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(800), spent)
+
+	// Approvals which are never broadcast stop counting once their reservation expires
+	defer func(ttl time.Duration) { ReservationTTL = ttl }(ReservationTTL)
+	ReservationTTL = 0
+	spent, err = SpentSince(ctx, h.Store, ownerAddr.AddressString, time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), spent)
+
+	// Spends seen on ingest keep counting
+	h.Ingest("approved")
+	spent, err = SpentSince(ctx, h.Store, ownerAddr.AddressString, time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(600), spent)
+}
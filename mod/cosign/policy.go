@@ -0,0 +1,111 @@
+package cosign
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shruggr/1sat-indexer/v5/idx"
+)
+
+// AllowDestinations only approves transactions whose outputs either pay back
+// to an owner of a cosigned input or to one of addresses. Outputs without an
+// owner, such as OP_RETURN data, may not carry satoshis.
+func AllowDestinations(addresses ...string) PolicyFn {
+	allowed := make(map[string]struct{}, len(addresses))
+	for _, address := range addresses {
+		allowed[address] = struct{}{}
+	}
+	return func(ctx context.Context, req *Request) error {
+		for _, txo := range req.Destinations() {
+			if len(txo.Owners) == 0 && *txo.Satoshis > 0 {
+				return fmt.Errorf("destination %s has no owner", txo.Outpoint.String())
+			}
+			for _, owner := range txo.Owners {
+				if _, ok := allowed[owner]; !ok {
+					return fmt.Errorf("destination %s not allowed", owner)
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// MaxTokenAmount limits the amount of a BSV21 token each owner may send in a
+// single transaction
+func MaxTokenAmount(tokenId string, max uint64) PolicyFn {
+	return func(ctx context.Context, req *Request) error {
+		for owner, tokens := range req.Tokens {
+			if tokens[tokenId] > max {
+				return fmt.Errorf("%s sends %d of %s, over %d", owner, tokens[tokenId], tokenId, max)
+			}
+		}
+		return nil
+	}
+}
+
+// SpendLimit limits the satoshis each owner may send to other addresses
+// across the spends approved within window. Only the amount a request adds
+// over the earlier approvals of its inputs counts against the limit.
+func SpendLimit(store idx.TxoStore, limit uint64, window time.Duration) PolicyFn {
+	return func(ctx context.Context, req *Request) error {
+		since := time.Now().Add(-window)
+		for owner := range req.Spent {
+			spent, err := SpentSince(ctx, store, owner, since)
+			if err != nil {
+				return err
+			}
+			for vin, inputOwner := range req.Inputs {
+				if inputOwner != owner {
+					continue
+				} else if _, increase, err := spendRecord(ctx, store, owner, req.Outpoint(vin), req.Sent[vin], since); err != nil {
+					return err
+				} else {
+					spent += increase
+				}
+			}
+			if spent > limit {
+				return fmt.Errorf("%s spends %d, over limit %d", owner, spent, limit)
+			}
+		}
+		return nil
+	}
+}
+
+// PoliciesFromEnv builds the approval policies configured by
+// COSIGN_DESTINATIONS (comma separated addresses), COSIGN_TOKEN_LIMITS
+// (comma separated tokenId:amount pairs) and COSIGN_SPEND_LIMIT (satoshis per
+// owner per COSIGN_SPEND_WINDOW, a duration defaulting to 24h)
+func PoliciesFromEnv(store idx.TxoStore) (policies []PolicyFn, err error) {
+	if destinations := os.Getenv("COSIGN_DESTINATIONS"); destinations != "" {
+		policies = append(policies, AllowDestinations(strings.Split(destinations, ",")...))
+	}
+	if limits := os.Getenv("COSIGN_TOKEN_LIMITS"); limits != "" {
+		for _, limit := range strings.Split(limits, ",") {
+			if tokenId, amt, ok := strings.Cut(limit, ":"); !ok {
+				return nil, fmt.Errorf("invalid token limit %s", limit)
+			} else if max, err := strconv.ParseUint(amt, 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid token limit %s: %w", limit, err)
+			} else {
+				policies = append(policies, MaxTokenAmount(tokenId, max))
+			}
+		}
+	}
+	if limit := os.Getenv("COSIGN_SPEND_LIMIT"); limit != "" {
+		window := 24 * time.Hour
+		if w := os.Getenv("COSIGN_SPEND_WINDOW"); w != "" {
+			if window, err = time.ParseDuration(w); err != nil {
+				return nil, err
+			}
+		}
+		if sats, err := strconv.ParseUint(limit, 10, 64); err != nil {
+			return nil, err
+		} else {
+			policies = append(policies, SpendLimit(store, sats, window))
+		}
+	}
+	return policies, nil
+}
@@ -0,0 +1,224 @@
+package lock
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/bsv-blockchain/go-sdk/script"
+	"github.com/bsv-blockchain/go-sdk/transaction"
+	"github.com/shruggr/1sat-indexer/v5/evt"
+	"github.com/shruggr/1sat-indexer/v5/idx"
+	"github.com/shruggr/1sat-indexer/v5/idxtest"
+	"github.com/shruggr/1sat-indexer/v5/mod/bitcom"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLock(t *testing.T) {
+	h := idxtest.New(t, &LockIndexer{})
+	idxCtx := h.Parse("lock")
+
+	lock := h.Data(idxCtx, 0, LOCK_TAG).Data.(*Lock)
+	assert.Equal(t, "1Fj8GVwfV6GnjpqN7t8GvxugKN4SAyER9X", lock.Address)
+	assert.Equal(t, uint32(850000), lock.Until)
+	assert.Equal(t, []string{"evt:lock:owner:1Fj8GVwfV6GnjpqN7t8GvxugKN4SAyER9X"}, idxtest.Events(idxCtx, 0, LOCK_TAG))
+	assert.Contains(t, idxCtx.Txos[0].Owners, lock.Address)
+	assert.NotContains(t, idxCtx.Txos[1].Data, LOCK_TAG)
+
+	h.Golden("lock", idxCtx)
+}
+
+func TestLockIngest(t *testing.T) {
+	h := idxtest.New(t, &LockIndexer{})
+	ctx := context.Background()
+	owner := "1Fj8GVwfV6GnjpqN7t8GvxugKN4SAyER9X"
+	h.Ingest("lock")
+
+	cfg := &idx.SearchCfg{
+		Keys:        []string{evt.EventKey(LOCK_TAG, &evt.Event{Id: "owner", Value: owner})},
+		IncludeTxo:  true,
+		IncludeTags: []string{LOCK_TAG},
+	}
+	txos, err := h.Store.SearchTxos(ctx, cfg)
+	assert.NoError(t, err)
+	assert.Len(t, txos, 1)
+	assert.Equal(t, h.Outpoint("lock", 0), txos[0].Outpoint.String())
+	assert.Contains(t, txos[0].Data, LOCK_TAG)
+
+	assert.NoError(t, h.Store.Rollback(ctx, h.Txid("lock")))
+	txos, err = h.Store.SearchTxos(ctx, cfg)
+	assert.NoError(t, err)
+	assert.Empty(t, txos)
+}
+
+func TestLockMaturity(t *testing.T) {
+	h := idxtest.New(t, &LockIndexer{})
+	ctx := context.Background()
+	owner := "1Fj8GVwfV6GnjpqN7t8GvxugKN4SAyER9X"
+	h.Ingest("lock")
+
+	txos, err := SearchLocks(ctx, h.Store, owner, StatusLocked, 849999, 10)
+	assert.NoError(t, err)
+	assert.Len(t, txos, 1)
+	txos, err = SearchLocks(ctx, h.Store, owner, StatusUnlockable, 849999, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, txos)
+
+	txos, err = SearchLocks(ctx, h.Store, owner, StatusUnlockable, 850000, 10)
+	assert.NoError(t, err)
+	assert.Len(t, txos, 1)
+	assert.Equal(t, h.Outpoint("lock", 0), txos[0].Outpoint.String())
+
+	totals, err := LoadLockTotals(ctx, h.Store, owner, 849999)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), totals.Unlockable)
+	assert.Equal(t, *txos[0].Satoshis, totals.Locked)
+
+	matured, err := MaturedLocks(ctx, h.Store, 849999, 850000)
+	assert.NoError(t, err)
+	assert.Len(t, matured, 1)
+	matured, err = MaturedLocks(ctx, h.Store, 850000, 850010)
+	assert.NoError(t, err)
+	assert.Empty(t, matured)
+
+	// Each matured lock is announced once, however often it is seen
+	assert.NoError(t, publishMatured(ctx, h.Store, 849999, 850000))
+	assert.NoError(t, publishMatured(ctx, h.Store, 849999, 850000))
+	notified, err := h.Store.SearchMembers(ctx, &idx.SearchCfg{Keys: []string{LockNotifiedKey}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{h.Outpoint("lock", 0)}, notified)
+
+	assert.NoError(t, h.Store.Rollback(ctx, h.Txid("lock")))
+	count, err := h.Store.CountMembers(ctx, LockUntilKey(owner))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), count)
+}
+
+func TestLockMaturityZero(t *testing.T) {
+	h := idxtest.New(t, &LockIndexer{})
+	ctx := context.Background()
+
+	// A lock with no Until height is unlockable from the start
+	tx := transaction.NewTransaction()
+	tx.AddOutput(&transaction.TransactionOutput{Satoshis: 1000, LockingScript: lockScript(make([]byte, 20), 0)})
+	h.AddTx("lock-zero", tx)
+	idxCtx := h.Ingest("lock-zero")
+	lock := h.Data(idxCtx, 0, LOCK_TAG).Data.(*Lock)
+	assert.Equal(t, uint32(0), lock.Until)
+
+	txos, err := SearchLocks(ctx, h.Store, lock.Address, StatusUnlockable, 1, 10)
+	assert.NoError(t, err)
+	assert.Len(t, txos, 1)
+	count, err := h.Store.CountMembers(ctx, LockMaturityKey)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), count)
+
+	assert.NoError(t, h.Store.Rollback(ctx, h.Txid("lock-zero")))
+	count, err = h.Store.CountMembers(ctx, LockMaturityKey)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), count)
+}
+
+func TestLockMaturityResume(t *testing.T) {
+	h := idxtest.New(t, &LockIndexer{})
+	ctx := context.Background()
+	h.Ingest("lock")
+
+	// The first run starts from the chaintip
+	assert.NoError(t, announceMatured(ctx, h.Store, 849990))
+	notified, err := h.Store.SearchMembers(ctx, &idx.SearchCfg{Keys: []string{LockNotifiedKey}})
+	assert.NoError(t, err)
+	assert.Empty(t, notified)
+
+	// Locks maturing while no watcher ran are announced from the recorded height
+	assert.NoError(t, announceMatured(ctx, h.Store, 850005))
+	notified, err = h.Store.SearchMembers(ctx, &idx.SearchCfg{Keys: []string{LockNotifiedKey}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{h.Outpoint("lock", 0)}, notified)
+	height, err := h.Store.LogScore(ctx, LockWatchKey, "height")
+	assert.NoError(t, err)
+	assert.Equal(t, float64(850005), height)
+}
+
+func TestLockMaturityParseOnly(t *testing.T) {
+	h := idxtest.New(t, &LockIndexer{})
+	ctx := context.Background()
+
+	h.Parse("lock")
+	count, err := h.Store.CountMembers(ctx, LockMaturityKey)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), count)
+}
+
+func lockScript(pkhash []byte, until uint32) *script.Script {
+	scr := script.NewFromBytes(append([]byte{}, LockPrefix...))
+	scr.AppendPushData(pkhash)
+	scr.AppendPushData(binary.LittleEndian.AppendUint32(nil, until))
+	*scr = append(*scr, LockSuffix...)
+	return scr
+}
+
+func likeScript(target string) *script.Script {
+	scr := &script.Script{}
+	scr.AppendOpcodes(script.OpFALSE, script.OpRETURN)
+	for _, s := range []string{bitcom.MAP_PROTO, "SET", "app", "hodlocker.com", "type", "like", "context", "tx", "tx", target} {
+		scr.AppendPushDataString(s)
+	}
+	return scr
+}
+
+func TestLockTargets(t *testing.T) {
+	h := idxtest.New(t, &bitcom.BitcomIndexer{}, &bitcom.MapIndexer{}, &LockIndexer{})
+	ctx := context.Background()
+	target := h.Txid("lock")
+	pkhash := make([]byte, 20)
+
+	likeTx := transaction.NewTransaction()
+	likeTx.AddOutput(&transaction.TransactionOutput{Satoshis: 1000, LockingScript: lockScript(pkhash, 850000)})
+	likeTx.AddOutput(&transaction.TransactionOutput{Satoshis: 0, LockingScript: likeScript(target)})
+	h.AddTx("like", likeTx)
+
+	idxCtx := h.Ingest("like")
+	lock := h.Data(idxCtx, 0, LOCK_TAG).Data.(*Lock)
+	assert.Equal(t, target, lock.Target)
+	// Re-indexing the like does not count it twice
+	h.Ingest("like")
+
+	targets, err := SearchTargets(ctx, h.Store, nil, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []*TargetTotal{{Target: target, Satoshis: 1000}}, targets)
+	locks, err := LoadTargetLocks(ctx, h.Store, target, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1000), locks.Satoshis)
+	assert.Equal(t, []*LockerTotal{{Address: lock.Address, Satoshis: 1000}}, locks.Lockers)
+
+	unlockTx := transaction.NewTransaction()
+	unlockTx.AddInput(&transaction.TransactionInput{
+		SourceTXID:       likeTx.TxID(),
+		SourceTxOutIndex: 0,
+		UnlockingScript:  &script.Script{},
+		SequenceNumber:   0xffffffff,
+	})
+	unlockTx.AddOutput(&transaction.TransactionOutput{Satoshis: 1000, LockingScript: script.NewFromBytes(append([]byte{}, h.Tx("lock").Outputs[1].LockingScript.Bytes()...))})
+	h.AddTx("unlock", unlockTx)
+	h.Ingest("unlock")
+	h.Ingest("unlock")
+
+	targets, err = SearchTargets(ctx, h.Store, nil, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, targets)
+	locks, err = LoadTargetLocks(ctx, h.Store, target, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), locks.Satoshis)
+	assert.Empty(t, locks.Lockers)
+
+	// Rolling back the unlock restores the totals, and rolling back the like clears them
+	assert.NoError(t, h.Store.Rollback(ctx, h.Txid("unlock")))
+	targets, err = SearchTargets(ctx, h.Store, nil, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []*TargetTotal{{Target: target, Satoshis: 1000}}, targets)
+	assert.NoError(t, h.Store.Rollback(ctx, h.Txid("like")))
+	targets, err = SearchTargets(ctx, h.Store, nil, 10)
+	assert.NoError(t, err)
+	assert.Empty(t, targets)
+}
@@ -0,0 +1,184 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/shruggr/1sat-indexer/v5/blk"
+	"github.com/shruggr/1sat-indexer/v5/evt"
+	"github.com/shruggr/1sat-indexer/v5/idx"
+)
+
+const (
+	StatusLocked     = "locked"
+	StatusUnlockable = "unlockable"
+)
+
+// LockMaturityKey holds every open lock, scored by the height it matures at
+const LockMaturityKey = "lock:until"
+
+// LockUntilKey holds the open locks of an address, scored by the height they mature at
+func LockUntilKey(address string) string {
+	return "lock:until:" + address
+}
+
+// LockNotifiedKey holds the locks whose maturity has been announced, scored by
+// the height they matured at, so each is announced once across watchers
+const LockNotifiedKey = "lock:notified"
+
+// LockWatchKey holds the chaintip height up to which maturities have been announced
+const LockWatchKey = "lock:watch"
+
+// logMaturity indexes a new lock by the height it matures at. The scores are only
+// recorded when the transaction is committed and are removed when it is rolled back.
+func logMaturity(idxCtx *idx.IndexContext, outpoint string, lock *Lock) {
+	idxCtx.Log(LockUntilKey(lock.Address), outpoint, float64(lock.Until))
+	idxCtx.Log(LockMaturityKey, outpoint, float64(lock.Until))
+}
+
+// delogMaturity drops a lock spent by idxCtx from the maturity indexes. Rolling back
+// the spending transaction restores it.
+func delogMaturity(idxCtx *idx.IndexContext, outpoint string, lock *Lock) {
+	idxCtx.Delog(LockUntilKey(lock.Address), outpoint)
+	idxCtx.Delog(LockMaturityKey, outpoint)
+}
+
+// UnlockableEvent is the channel notified with the outpoint of each lock of
+// address as it matures
+func UnlockableEvent(address string) string {
+	return evt.EventKey(LOCK_TAG, &evt.Event{
+		Id:    StatusUnlockable,
+		Value: address,
+	})
+}
+
+// statusCfg bounds a search of the maturity indexes to the locks that are
+// still locked, or already unlockable, at height. A lock can be spent once
+// the chain reaches its Until height.
+func statusCfg(key string, status string, height uint32) *idx.SearchCfg {
+	cfg := &idx.SearchCfg{
+		Keys:        []string{key},
+		IncludeTxo:  true,
+		IncludeTags: []string{LOCK_TAG},
+		FilterSpent: true,
+	}
+	if status == StatusUnlockable {
+		to := float64(height) + 1
+		cfg.To = &to
+	} else {
+		from := float64(height)
+		cfg.From = &from
+	}
+	return cfg
+}
+
+func SearchLocks(ctx context.Context, store idx.TxoStore, address string, status string, height uint32, limit uint32) ([]*idx.Txo, error) {
+	cfg := statusCfg(LockUntilKey(address), status, height)
+	cfg.Limit = limit
+	return store.SearchTxos(ctx, cfg)
+}
+
+type LockTotals struct {
+	Address    string `json:"address"`
+	Height     uint32 `json:"height"`
+	Locked     uint64 `json:"locked"`
+	Unlockable uint64 `json:"unlockable"`
+}
+
+func LoadLockTotals(ctx context.Context, store idx.TxoStore, address string, height uint32) (totals *LockTotals, err error) {
+	totals = &LockTotals{
+		Address: address,
+		Height:  height,
+	}
+	if totals.Locked, err = store.SearchBalance(ctx, statusCfg(LockUntilKey(address), StatusLocked, height)); err != nil {
+		return nil, err
+	} else if totals.Unlockable, err = store.SearchBalance(ctx, statusCfg(LockUntilKey(address), StatusUnlockable, height)); err != nil {
+		return nil, err
+	}
+	return totals, nil
+}
+
+// MaturedLocks returns the open locks maturing after height from, up to and including height to
+func MaturedLocks(ctx context.Context, store idx.TxoStore, from uint32, to uint32) ([]*idx.Txo, error) {
+	cfg := statusCfg(LockMaturityKey, StatusUnlockable, to)
+	lower := float64(from)
+	cfg.From = &lower
+	return store.SearchTxos(ctx, cfg)
+}
+
+// WatchMaturity publishes an UnlockableEvent for each lock as the chaintip
+// reaches its Until height. The height announced up to is kept in the store,
+// so locks that matured while no watcher ran are announced on restart.
+// Watchers sharing a store announce each lock once.
+func WatchMaturity(ctx context.Context, store idx.TxoStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if chaintip, err := blk.GetChaintip(ctx); err != nil {
+			log.Println("lock maturity", err)
+		} else if chaintip != nil {
+			if err := announceMatured(ctx, store, chaintip.Height); err != nil {
+				log.Println("lock maturity", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// announceMatured publishes the locks maturing between the height last announced
+// and tip, then records tip. The first run only records tip.
+func announceMatured(ctx context.Context, store idx.TxoStore, tip uint32) error {
+	if score, err := store.LogScore(ctx, LockWatchKey, "height"); err != nil {
+		return err
+	} else if height := uint32(score); height >= tip {
+		return nil
+	} else if height > 0 {
+		if err := publishMatured(ctx, store, height, tip); err != nil {
+			return err
+		}
+	}
+	return store.Log(ctx, LockWatchKey, "height", float64(tip))
+}
+
+func publishMatured(ctx context.Context, store idx.TxoStore, from uint32, to uint32) error {
+	txos, err := MaturedLocks(ctx, store, from, to)
+	if err != nil {
+		return err
+	}
+	for _, txo := range txos {
+		if lockData, ok := txo.Data[LOCK_TAG]; !ok {
+			continue
+		} else if lock, err := lockFromData(lockData); err != nil {
+			return err
+		} else if added, err := store.LogOnce(ctx, LockNotifiedKey, txo.Outpoint.String(), float64(lock.Until)); err != nil {
+			return err
+		} else if !added {
+			continue
+		} else if err := evt.Publish(ctx, UnlockableEvent(lock.Address), txo.Outpoint.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func lockFromData(data *idx.IndexData) (*Lock, error) {
+	switch d := data.Data.(type) {
+	case *Lock:
+		return d, nil
+	case json.RawMessage:
+		lock := &Lock{}
+		if err := json.Unmarshal(d, lock); err != nil {
+			return nil, err
+		}
+		return lock, nil
+	default:
+		return nil, fmt.Errorf("%w: unexpected lock data %T", idx.ErrMalformedData, data.Data)
+	}
+}
@@ -0,0 +1,144 @@
+package lock
+
+import (
+	"context"
+	"encoding/hex"
+
+	"github.com/shruggr/1sat-indexer/v5/idx"
+	"github.com/shruggr/1sat-indexer/v5/mod/bitcom"
+)
+
+// LockTargetsKey ranks the transactions liked through locks by the satoshis
+// currently locked to them
+const LockTargetsKey = "lock:targets"
+
+// LockTargetKey ranks the addresses locking to target by the satoshis they
+// currently have locked to it
+func LockTargetKey(target string) string {
+	return "lock:target:" + target
+}
+
+// lockTarget reads the txid a lock likes from MAP data on the lock output or,
+// failing that, on another output of the same transaction. The MAP context
+// key names the key holding the target, defaulting to tx.
+func lockTarget(idxCtx *idx.IndexContext, txo *idx.Txo) string {
+	if target := mapTarget(txo); target != "" {
+		return target
+	}
+	for _, other := range idxCtx.Txos {
+		if target := mapTarget(other); target != "" {
+			return target
+		}
+	}
+	return ""
+}
+
+func mapTarget(txo *idx.Txo) string {
+	mapData, ok := txo.Data[bitcom.MAP_TAG]
+	if !ok {
+		return ""
+	}
+	mp, ok := mapData.Data.(bitcom.Map)
+	if !ok {
+		return ""
+	}
+	key := "tx"
+	if ctxKey, ok := mp["context"].(string); ok && ctxKey != "" {
+		key = ctxKey
+	}
+	if target, ok := mp[key].(string); !ok || len(target) != 64 {
+		return ""
+	} else if _, err := hex.DecodeString(target); err != nil {
+		return ""
+	} else {
+		return target
+	}
+}
+
+// addTarget counts satoshis locked by lock towards its target and locker. The totals
+// are moved by increments, which the store applies atomically when the transaction is
+// committed and reverts when it is rolled back, so re-indexing a lock or its unlock is
+// not counted twice.
+func addTarget(idxCtx *idx.IndexContext, lock *Lock, satoshis uint64) {
+	if lock.Target == "" {
+		return
+	}
+	idxCtx.Incr(LockTargetsKey, lock.Target, float64(satoshis))
+	idxCtx.Incr(LockTargetKey(lock.Target), lock.Address, float64(satoshis))
+}
+
+// removeTarget takes the satoshis of a spent lock off its target and locker
+func removeTarget(idxCtx *idx.IndexContext, lock *Lock, satoshis uint64) {
+	if lock.Target == "" {
+		return
+	}
+	idxCtx.Incr(LockTargetsKey, lock.Target, -float64(satoshis))
+	idxCtx.Incr(LockTargetKey(lock.Target), lock.Address, -float64(satoshis))
+}
+
+type TargetTotal struct {
+	Target   string `json:"target"`
+	Satoshis uint64 `json:"satoshis"`
+}
+
+type LockerTotal struct {
+	Address  string `json:"address"`
+	Satoshis uint64 `json:"satoshis"`
+}
+
+type TargetLocks struct {
+	Target   string         `json:"target"`
+	Satoshis uint64         `json:"satoshis"`
+	Lockers  []*LockerTotal `json:"lockers"`
+}
+
+// SearchTargets returns the liked transactions with the most satoshis locked
+// to them, starting below the total from when it is set
+func SearchTargets(ctx context.Context, store idx.TxoStore, from *float64, limit uint32) ([]*TargetTotal, error) {
+	logs, err := store.Search(ctx, &idx.SearchCfg{
+		Keys:    []string{LockTargetsKey},
+		From:    from,
+		Reverse: true,
+		Limit:   limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	targets := make([]*TargetTotal, 0, len(logs))
+	for _, l := range logs {
+		targets = append(targets, &TargetTotal{
+			Target:   l.Member,
+			Satoshis: uint64(l.Score),
+		})
+	}
+	return targets, nil
+}
+
+// LoadTargetLocks returns the satoshis locked to target and its lockers,
+// largest first
+func LoadTargetLocks(ctx context.Context, store idx.TxoStore, target string, limit uint32) (*TargetLocks, error) {
+	total, err := store.LogScore(ctx, LockTargetsKey, target)
+	if err != nil {
+		return nil, err
+	}
+	logs, err := store.Search(ctx, &idx.SearchCfg{
+		Keys:    []string{LockTargetKey(target)},
+		Reverse: true,
+		Limit:   limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+	locks := &TargetLocks{
+		Target:   target,
+		Satoshis: uint64(total),
+		Lockers:  make([]*LockerTotal, 0, len(logs)),
+	}
+	for _, l := range logs {
+		locks.Lockers = append(locks.Lockers, &LockerTotal{
+			Address:  l.Member,
+			Satoshis: uint64(l.Score),
+		})
+	}
+	return locks, nil
+}
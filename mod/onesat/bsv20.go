@@ -1,8 +1,10 @@
 package onesat
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/binary"
+	"encoding/json"
 	"fmt"
 	"log"
 	"strconv"
@@ -11,6 +13,7 @@ import (
 	bip32 "github.com/bsv-blockchain/go-sdk/compat/bip32"
 	"github.com/shruggr/1sat-indexer/v5/evt"
 	"github.com/shruggr/1sat-indexer/v5/idx"
+	"github.com/shruggr/1sat-indexer/v5/lib"
 )
 
 type Bsv20Status int
@@ -31,13 +34,14 @@ const BSV20_INCLUDE_FEE = 10000000
 type Bsv20 struct {
 	Ticker      string      `json:"tick,omitempty"`
 	Op          string      `json:"op"`
-	Max         uint64      `json:"-"`
-	Limit       uint64      `json:"-"`
-	Decimals    uint8       `json:"-"`
+	Max         uint64      `json:"max,omitempty"`
+	Limit       uint64      `json:"lim,omitempty"`
+	Decimals    uint8       `json:"dec,omitempty"`
 	Amt         *uint64     `json:"amt"`
 	Implied     bool        `json:"-"`
-	Status      Bsv20Status `json:"-"`
+	Status      Bsv20Status `json:"status"`
 	Reason      *string     `json:"reason,omitempty"`
+	Supply      uint64      `json:"supply,omitempty"`
 	FundPath    string      `json:"-"`
 	FundPKHash  []byte      `json:"-"`
 	FundBalance int         `json:"-"`
@@ -55,6 +59,18 @@ func (i *Bsv20Indexer) Tag() string {
 	return BSV20_TAG
 }
 
+func (i *Bsv20Indexer) FromBytes(data []byte) (any, error) {
+	return Bsv20FromBytes(data)
+}
+
+func Bsv20FromBytes(data []byte) (*Bsv20, error) {
+	obj := &Bsv20{}
+	if err := json.Unmarshal(data, obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
 func (i *Bsv20Indexer) Parse(idxCtx *idx.IndexContext, vout uint32) *idx.IndexData {
 	txo := idxCtx.Txos[vout]
 
@@ -75,12 +91,9 @@ func (i *Bsv20Indexer) Parse(idxCtx *idx.IndexContext, vout uint32) *idx.IndexDa
 		bsv20 := &Bsv20{
 			Ticker: strings.ToUpper(tick),
 		}
-		// if i.WhitelistFn != nil && !(*i.WhitelistFn)(bsv20.Ticker) {
-		// 	return nil
-		// } else if i.BlacklistFn != nil || (*i.BlacklistFn)(bsv20.Ticker) {
-		// 	return nil
-		// } else
-		if op, ok := insc.JsonMap["op"]; !ok {
+		if !allowed(idxCtx, BSV20_TAG, bsv20.Ticker, i.WhitelistFn, i.BlacklistFn) {
+			return nil
+		} else if op, ok := insc.JsonMap["op"]; !ok {
 			return nil
 		} else {
 			bsv20.Op = strings.ToLower(op)
@@ -146,3 +159,263 @@ func (i *Bsv20Indexer) Parse(idxCtx *idx.IndexContext, vout uint32) *idx.IndexDa
 		}
 	}
 }
+
+const (
+	Bsv20DeployEvent = "deploy"
+	Bsv20MintEvent   = "mint"
+)
+
+// Bsv20DeployKey logs every well formed deploy of a ticker by score. The lowest score is the valid deploy.
+func Bsv20DeployKey(tick string) string {
+	return evt.EventKey(BSV20_TAG, &evt.Event{Id: Bsv20DeployEvent, Value: tick})
+}
+
+// Bsv20MintKey logs every valid mint of a ticker by score. Each mint carries the supply after it.
+func Bsv20MintKey(tick string) string {
+	return evt.EventKey(BSV20_TAG, &evt.Event{Id: Bsv20MintEvent, Value: tick})
+}
+
+type Bsv20Ticker struct {
+	Ticker   string  `json:"tick"`
+	Deploy   string  `json:"deploy"`
+	Score    float64 `json:"score"`
+	Max      uint64  `json:"max"`
+	Limit    uint64  `json:"lim"`
+	Decimals uint8   `json:"dec"`
+	Supply   uint64  `json:"supply"`
+	Mints    uint64  `json:"mints"`
+}
+
+// LoadBsv20Ticker returns the valid deploy of a ticker along with its minted supply.
+// It returns nil when the ticker has not been deployed.
+func LoadBsv20Ticker(ctx context.Context, store idx.TxoStore, tick string) (*Bsv20Ticker, error) {
+	tick = strings.ToUpper(tick)
+	if deploys, err := store.Search(ctx, &idx.SearchCfg{
+		Keys:  []string{Bsv20DeployKey(tick)},
+		Limit: 1,
+	}); err != nil {
+		return nil, err
+	} else if len(deploys) == 0 {
+		return nil, nil
+	} else if deploy, err := loadBsv20(ctx, store, deploys[0].Member); err != nil {
+		return nil, fmt.Errorf("deploy %s: %w", deploys[0].Member, err)
+	} else {
+		ticker := &Bsv20Ticker{
+			Ticker:   tick,
+			Deploy:   deploys[0].Member,
+			Score:    deploys[0].Score,
+			Max:      deploy.Max,
+			Limit:    deploy.Limit,
+			Decimals: deploy.Decimals,
+		}
+		if ticker.Supply, err = bsv20Supply(ctx, store, tick, nil, "", 0); err != nil {
+			return nil, err
+		} else if ticker.Mints, err = store.CountMembers(ctx, Bsv20MintKey(tick)); err != nil {
+			return nil, err
+		}
+		return ticker, nil
+	}
+}
+
+// loadBsv20 loads the saved bsv20 data of outpoint
+func loadBsv20(ctx context.Context, store idx.TxoStore, outpoint string) (*Bsv20, error) {
+	if data, err := store.LoadData(ctx, outpoint, []string{BSV20_TAG}); err != nil {
+		return nil, err
+	} else if data[BSV20_TAG] == nil {
+		return nil, idx.ErrMissingInput
+	} else if raw, ok := data[BSV20_TAG].Data.(json.RawMessage); !ok {
+		return nil, idx.ErrMalformedData
+	} else if bsv20, err := Bsv20FromBytes(raw); err != nil {
+		return nil, fmt.Errorf("%w: %v", idx.ErrMalformedData, err)
+	} else {
+		return bsv20, nil
+	}
+}
+
+// bsv20Supply returns the supply of a ticker after the last valid mint scored below before,
+// or after the last valid mint when before is nil. Mints of txid are skipped so that
+// reingesting a transaction does not count its own mints twice.
+func bsv20Supply(ctx context.Context, store idx.TxoStore, tick string, before *float64, txid string, outputs int) (uint64, error) {
+	if mints, err := store.Search(ctx, &idx.SearchCfg{
+		Keys:    []string{Bsv20MintKey(tick)},
+		From:    before,
+		Reverse: true,
+		Limit:   uint32(outputs + 1),
+	}); err != nil {
+		return 0, err
+	} else {
+		for _, mint := range mints {
+			if txid != "" && strings.HasPrefix(mint.Member, txid+"_") {
+				continue
+			} else if bsv20, err := loadBsv20(ctx, store, mint.Member); err != nil {
+				return 0, fmt.Errorf("mint %s: %w", mint.Member, err)
+			} else {
+				return bsv20.Supply, nil
+			}
+		}
+		return 0, nil
+	}
+}
+
+type bsv20Token struct {
+	balance uint64
+	token   *Bsv20
+	outputs []*idx.IndexData
+	deps    []*lib.Outpoint
+}
+
+// PreSave settles the status of each bsv20 output. Nothing is written to the store here:
+// deploys and valid mints are recorded by their events when the transaction is committed,
+// so parsing alone has no effect and a rollback removes them.
+func (i *Bsv20Indexer) PreSave(idxCtx *idx.IndexContext) {
+	tokens := map[string]*bsv20Token{}
+	deployed := map[string]struct{}{}
+	supplies := map[string]uint64{}
+	for _, txo := range idxCtx.Txos {
+		if idxData, ok := txo.Data[BSV20_TAG]; !ok {
+			continue
+		} else if bsv20, ok := idxData.Data.(*Bsv20); !ok {
+			continue
+		} else if bsv20.Op == "deploy" {
+			status, reason := i.validateDeploy(idxCtx, txo, bsv20, deployed)
+			setBsv20Status(idxData, bsv20, status, reason)
+		} else if bsv20.Op == "mint" {
+			status, reason := i.validateMint(idxCtx, idxData, bsv20, supplies)
+			setBsv20Status(idxData, bsv20, status, reason)
+		} else if token, ok := tokens[bsv20.Ticker]; !ok {
+			tokens[bsv20.Ticker] = &bsv20Token{
+				outputs: []*idx.IndexData{idxData},
+			}
+		} else {
+			token.outputs = append(token.outputs, idxData)
+		}
+	}
+	if len(tokens) == 0 {
+		return
+	}
+
+	isPending := false
+	for _, spend := range idxCtx.Spends {
+		if spend.Satoshis == nil {
+			isPending = true
+			break
+		}
+		if idxData, ok := spend.Data[BSV20_TAG]; ok {
+			if bsv20, ok := idxData.Data.(*Bsv20); ok && bsv20.Op != "deploy" {
+				if bsv20.Status == Pending {
+					isPending = true
+					break
+				} else if bsv20.Status == Valid {
+					if token, ok := tokens[bsv20.Ticker]; ok {
+						token.balance += *bsv20.Amt
+						token.token = bsv20
+						token.deps = append(token.deps, spend.Outpoint)
+					}
+				}
+			}
+		}
+	}
+
+	for _, token := range tokens {
+		for _, idxData := range token.outputs {
+			bsv20 := idxData.Data.(*Bsv20)
+			if isPending {
+				setBsv20Status(idxData, bsv20, Pending, "")
+			} else if token.token == nil {
+				setBsv20Status(idxData, bsv20, Invalid, "missing inputs")
+			} else if *bsv20.Amt > token.balance {
+				setBsv20Status(idxData, bsv20, Invalid, "insufficient funds")
+			} else {
+				bsv20.Decimals = token.token.Decimals
+				token.balance -= *bsv20.Amt
+				setBsv20Status(idxData, bsv20, Valid, "")
+			}
+			idxData.Deps = append(idxData.Deps, token.deps...)
+		}
+	}
+}
+
+// validateDeploy checks that no deploy of the ticker has a lower score, either in the
+// store or earlier in the same transaction. Well formed deploys are logged to
+// Bsv20DeployKey through their deploy event.
+func (i *Bsv20Indexer) validateDeploy(idxCtx *idx.IndexContext, txo *idx.Txo, bsv20 *Bsv20, deployed map[string]struct{}) (Bsv20Status, string) {
+	outpoint := txo.Outpoint.String()
+	if bsv20.Max == 0 {
+		return Invalid, "invalid max"
+	} else if bsv20.Limit > bsv20.Max {
+		return Invalid, "invalid lim"
+	}
+	txo.Data[BSV20_TAG].Events = append(txo.Data[BSV20_TAG].Events, &evt.Event{
+		Id:    Bsv20DeployEvent,
+		Value: bsv20.Ticker,
+	})
+	if _, ok := deployed[bsv20.Ticker]; ok {
+		return Invalid, "duplicate deploy"
+	}
+	deployed[bsv20.Ticker] = struct{}{}
+	if deploys, err := idxCtx.Store.Search(idxCtx.Ctx, &idx.SearchCfg{
+		Keys:  []string{Bsv20DeployKey(bsv20.Ticker)},
+		Limit: 1,
+	}); err != nil {
+		log.Println("bsv20 deploy", outpoint, err)
+		return Pending, ""
+	} else if len(deploys) > 0 && deploys[0].Member != outpoint && deploys[0].Score < idxCtx.Score {
+		return Invalid, "duplicate deploy"
+	}
+	return Valid, ""
+}
+
+// validateMint checks a mint against the ticker's limit and the supply left by the valid
+// mints scored before it. The mint that crosses the max supply is reduced to the remaining
+// supply. Valid mints record the supply after them and are logged to Bsv20MintKey through
+// their mint event. supplies carries the running supply of mints earlier in the same
+// transaction.
+func (i *Bsv20Indexer) validateMint(idxCtx *idx.IndexContext, idxData *idx.IndexData, bsv20 *Bsv20, supplies map[string]uint64) (Bsv20Status, string) {
+	if ticker, err := LoadBsv20Ticker(idxCtx.Ctx, idxCtx.Store, bsv20.Ticker); err != nil {
+		log.Println("bsv20 mint", bsv20.Ticker, err)
+		return Pending, ""
+	} else if ticker == nil || ticker.Score > idxCtx.Score {
+		return Invalid, "ticker not deployed"
+	} else if *bsv20.Amt == 0 {
+		return Invalid, "invalid amt"
+	} else if ticker.Limit > 0 && *bsv20.Amt > ticker.Limit {
+		bsv20.Decimals = ticker.Decimals
+		return Invalid, "exceeds lim"
+	} else {
+		bsv20.Decimals = ticker.Decimals
+		supply, ok := supplies[bsv20.Ticker]
+		if !ok {
+			if supply, err = bsv20Supply(idxCtx.Ctx, idxCtx.Store, bsv20.Ticker, &idxCtx.Score, idxCtx.TxidHex, len(idxCtx.Txos)); err != nil {
+				log.Println("bsv20 mint", bsv20.Ticker, err)
+				return Pending, ""
+			}
+		}
+		if supply >= ticker.Max {
+			return Invalid, "supply exhausted"
+		} else if remaining := ticker.Max - supply; *bsv20.Amt > remaining {
+			bsv20.Amt = &remaining
+		}
+		bsv20.Supply = supply + *bsv20.Amt
+		supplies[bsv20.Ticker] = bsv20.Supply
+		idxData.Events = append(idxData.Events, &evt.Event{
+			Id:    Bsv20MintEvent,
+			Value: bsv20.Ticker,
+		})
+		return Valid, ""
+	}
+}
+
+func setBsv20Status(idxData *idx.IndexData, bsv20 *Bsv20, status Bsv20Status, reason string) {
+	bsv20.Status = status
+	event := PendingEvent
+	if status == Valid {
+		event = ValidEvent
+	} else if status == Invalid {
+		event = InvalidEvent
+		bsv20.Reason = &reason
+	}
+	idxData.Events = append(idxData.Events, &evt.Event{
+		Id:    event,
+		Value: bsv20.Ticker,
+	})
+}
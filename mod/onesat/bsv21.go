@@ -1,6 +1,7 @@
 package onesat
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/binary"
 	"encoding/json"
@@ -43,6 +44,8 @@ type Bsv21Indexer struct {
 	idx.BaseIndexer
 	WhitelistFn *func(tokenId string) bool
 	BlacklistFn *func(tokenId string) bool
+	// Funds totals the satoshis sent to fund addresses when set
+	Funds *Bsv21Funds
 }
 
 func (i *Bsv21Indexer) Tag() string {
@@ -74,19 +77,6 @@ func (i *Bsv21Indexer) Parse(idxCtx *idx.IndexContext, vout uint32) *idx.IndexDa
 	} else if protocol, ok := insc.JsonMap["p"]; !ok || protocol != "bsv-20" {
 		return nil
 	} else {
-		// if i.WhitelistFn != nil {
-		// 	whitelisted := (*i.WhitelistFn)(id)
-		// 	if !whitelisted {
-		// 		return nil
-		// 	}
-
-		// }
-		// if i.BlacklistFn != nil {
-		// 	blacklisted := (*i.BlacklistFn)(id)
-		// 	if blacklisted {
-		// 		return nil
-		// 	}
-		// }
 		bsv21 := &Bsv21{}
 		if op, ok := insc.JsonMap["op"]; ok {
 			bsv21.Op = strings.ToLower(op)
@@ -141,6 +131,9 @@ func (i *Bsv21Indexer) Parse(idxCtx *idx.IndexContext, vout uint32) *idx.IndexDa
 			events = append(events, &evt.Event{
 				Id:    IssueEvent,
 				Value: "",
+			}, &evt.Event{
+				Id:    ValidEvent,
+				Value: bsv21.Id,
 			})
 		case "transfer", "burn":
 			if id, ok := insc.JsonMap["id"]; !ok {
@@ -154,6 +147,10 @@ func (i *Bsv21Indexer) Parse(idxCtx *idx.IndexContext, vout uint32) *idx.IndexDa
 			return nil
 		}
 
+		if !allowed(idxCtx, BSV21_TAG, bsv21.Id, i.WhitelistFn, i.BlacklistFn) {
+			return nil
+		}
+
 		events = append(events, &evt.Event{
 			Id:    IdEvent,
 			Value: bsv21.Id,
@@ -177,15 +174,20 @@ type bsv21Ctx struct {
 }
 
 func (i *Bsv21Indexer) PreSave(idxCtx *idx.IndexContext) {
+	defer tallyBsv21(idxCtx)
+	defer markResolvable(idxCtx)
+	defer i.Funds.tally(idxCtx)
 	ctx := bsv21Ctx{
 		tokens: map[string]*bsv21Token{},
 	}
 
-	isPending := false
 	for _, txo := range idxCtx.Txos {
 		if idxData, ok := txo.Data[BSV21_TAG]; ok {
 			if bsv21, ok := idxData.Data.(*Bsv21); ok {
 				if bsv21.Op == "deploy+mint" {
+					if i.Funds != nil && bsv21.FundAddress != "" {
+						i.Funds.Add(bsv21.FundAddress, bsv21.Id)
+					}
 					continue
 				}
 				if token, ok := ctx.tokens[bsv21.Id]; !ok {
@@ -205,16 +207,18 @@ func (i *Bsv21Indexer) PreSave(idxCtx *idx.IndexContext) {
 		return
 	}
 
+	// Spends which can't be validated yet. Every output waits on all of them.
+	pending := []*lib.Outpoint{}
 	for _, spend := range idxCtx.Spends {
+		// An input which is neither saved nor loaded may still carry tokens, so wait on it
 		if spend.Satoshis == nil {
-			isPending = true
-			break
+			pending = append(pending, spend.Outpoint)
+			continue
 		}
 		if idxData, ok := spend.Data[BSV21_TAG]; ok {
 			if bsv21, ok := idxData.Data.(*Bsv21); ok {
 				if bsv21.Status == Pending {
-					isPending = true
-					break
+					pending = append(pending, spend.Outpoint)
 				} else if bsv21.Status == Valid {
 					if token, ok := ctx.tokens[bsv21.Id]; ok {
 						token.balance += bsv21.Amt
@@ -225,18 +229,27 @@ func (i *Bsv21Indexer) PreSave(idxCtx *idx.IndexContext) {
 			}
 		}
 	}
+	// An ancestor parsed without loading its own inputs can't be judged either
+	if len(idxCtx.Spends) == 0 && !idxCtx.Tx.IsCoinbase() {
+		for _, txin := range idxCtx.Tx.Inputs {
+			pending = append(pending, lib.NewOutpointFromHash(txin.SourceTXID, txin.SourceTxOutIndex))
+		}
+	}
+	isPending := len(pending) > 0
 
 	reasons := map[string]string{}
 	if !isPending {
-		for _, token := range ctx.tokens {
+		for tokenId, token := range ctx.tokens {
 			for _, idxData := range token.outputs {
 				if bsv21, ok := idxData.Data.(*Bsv21); ok {
 					if token.token == nil {
 						reason := "missing inputs"
 						token.reason = &reason
+						reasons[tokenId] = reason
 					} else if bsv21.Amt > token.balance {
 						reason := "insufficient funds"
 						token.reason = &reason
+						reasons[tokenId] = reason
 					} else {
 						bsv21.Icon = token.token.Icon
 						bsv21.Symbol = token.token.Symbol
@@ -256,6 +269,7 @@ func (i *Bsv21Indexer) PreSave(idxCtx *idx.IndexContext) {
 						Id:    PendingEvent,
 						Value: tokenId,
 					})
+					idxData.Deps = append(idxData.Deps, pending...)
 				} else if reason, ok := reasons[tokenId]; ok {
 					bsv21.Status = Invalid
 					bsv21.Reason = &reason
@@ -275,4 +289,176 @@ func (i *Bsv21Indexer) PreSave(idxCtx *idx.IndexContext) {
 			}
 		}
 	}
+
+}
+
+// tallyBsv21 moves the supply and holder balances of each token by the valid
+// outputs the transaction creates and the valid outputs it spends. The totals
+// are only changed when the transaction is committed.
+func tallyBsv21(idxCtx *idx.IndexContext) {
+	tally := func(txo *idx.Txo, sign float64) {
+		if txo.Satoshis == nil {
+			return
+		} else if idxData, ok := txo.Data[BSV21_TAG]; !ok {
+			return
+		} else if bsv21, ok := idxData.Data.(*Bsv21); !ok || bsv21.Status != Valid || bsv21.Op == "burn" {
+			return
+		} else {
+			idxCtx.Incr(Bsv21SupplyKey, bsv21.Id, sign*float64(bsv21.Amt))
+			if len(txo.Owners) > 0 {
+				idxCtx.Incr(Bsv21HoldersKey(bsv21.Id), txo.Owners[0], sign*float64(bsv21.Amt))
+			}
+		}
+	}
+	for _, txo := range idxCtx.Txos {
+		tally(txo, 1)
+	}
+	for _, spend := range idxCtx.Spends {
+		tally(spend, -1)
+	}
+}
+
+// Bsv21SupplyKey holds the circulating supply of each token, the amount held
+// in its unspent valid outputs
+const Bsv21SupplyKey = "bsv21:supply"
+
+// Bsv21HoldersKey ranks the owners of a token by the amount held in their
+// unspent valid outputs
+func Bsv21HoldersKey(tokenId string) string {
+	return "bsv21:holders:" + tokenId
+}
+
+type Bsv21Token struct {
+	Id          string  `json:"id"`
+	Symbol      *string `json:"sym,omitempty"`
+	Decimals    uint8   `json:"dec"`
+	Icon        string  `json:"icon,omitempty"`
+	Height      uint32  `json:"height"`
+	Idx         uint64  `json:"idx"`
+	Max         uint64  `json:"max"`
+	Supply      uint64  `json:"supply"`
+	FundAddress string  `json:"fundAddress,omitempty"`
+}
+
+type Bsv21Holder struct {
+	Address string `json:"address"`
+	Amt     uint64 `json:"amt"`
+	Balance string `json:"balance"`
+}
+
+// FormatAmount renders a raw token amount as a decimal string using the token's decimals
+func FormatAmount(amt uint64, dec uint8) string {
+	s := strconv.FormatUint(amt, 10)
+	if dec == 0 {
+		return s
+	}
+	if len(s) <= int(dec) {
+		s = strings.Repeat("0", int(dec)-len(s)+1) + s
+	}
+	return s[:len(s)-int(dec)] + "." + s[len(s)-int(dec):]
+}
+
+// Bsv21ValidKey is the event key of every valid output of a token
+func Bsv21ValidKey(tokenId string) string {
+	return evt.EventKey(BSV21_TAG, &evt.Event{
+		Id:    ValidEvent,
+		Value: tokenId,
+	})
+}
+
+// LoadBsv21Token returns the deploy of a token along with its circulating supply.
+// It returns nil when the token has not been deployed.
+func LoadBsv21Token(ctx context.Context, store idx.TxoStore, tokenId string) (*Bsv21Token, error) {
+	if txo, err := store.LoadTxo(ctx, tokenId, []string{BSV21_TAG}, false, false); err != nil {
+		return nil, err
+	} else if txo == nil || txo.Data[BSV21_TAG] == nil {
+		return nil, nil
+	} else if deploy, err := bsv21Data(txo); err != nil {
+		return nil, err
+	} else if deploy.Op != "deploy+mint" {
+		return nil, nil
+	} else if supply, err := store.LogScore(ctx, Bsv21SupplyKey, tokenId); err != nil {
+		return nil, err
+	} else {
+		token := &Bsv21Token{
+			Id:          tokenId,
+			Symbol:      deploy.Symbol,
+			Decimals:    deploy.Decimals,
+			Icon:        deploy.Icon,
+			Height:      txo.Height,
+			Idx:         txo.Idx,
+			Max:         deploy.Amt,
+			Supply:      uint64(supply),
+			FundAddress: deploy.FundAddress,
+		}
+		return token, nil
+	}
+}
+
+// LoadBsv21Utxos returns the unspent valid outputs of a token, optionally limited to an owner.
+// Burned outputs are excluded. The bsv21 data of each txo is decoded.
+func LoadBsv21Utxos(ctx context.Context, store idx.TxoStore, tokenId string, owner string) ([]*idx.Txo, error) {
+	cfg := &idx.SearchCfg{
+		Keys:        []string{Bsv21ValidKey(tokenId)},
+		FilterSpent: true,
+		IncludeTxo:  true,
+		IncludeTags: []string{BSV21_TAG},
+	}
+	if owner != "" {
+		cfg.Keys = append(cfg.Keys, idx.OwnerKey(owner))
+		cfg.ComparisonType = idx.ComparisonAND
+	}
+	if txos, err := store.SearchTxos(ctx, cfg); err != nil {
+		return nil, err
+	} else {
+		utxos := make([]*idx.Txo, 0, len(txos))
+		for _, txo := range txos {
+			if txo == nil || txo.Data[BSV21_TAG] == nil {
+				continue
+			} else if bsv21, err := bsv21Data(txo); err != nil {
+				return nil, err
+			} else if bsv21.Status != Valid || bsv21.Op == "burn" {
+				continue
+			} else {
+				txo.Data[BSV21_TAG].Data = bsv21
+				utxos = append(utxos, txo)
+			}
+		}
+		return utxos, nil
+	}
+}
+
+// LoadBsv21Holders returns the owners of a token ranked by balance, starting
+// below the balance from when it is set
+func LoadBsv21Holders(ctx context.Context, store idx.TxoStore, tokenId string, dec uint8, from *float64, limit uint32) ([]*Bsv21Holder, error) {
+	if logs, err := store.Search(ctx, &idx.SearchCfg{
+		Keys:    []string{Bsv21HoldersKey(tokenId)},
+		From:    from,
+		Reverse: true,
+		Limit:   limit,
+	}); err != nil {
+		return nil, err
+	} else {
+		holders := make([]*Bsv21Holder, 0, len(logs))
+		for _, l := range logs {
+			holders = append(holders, &Bsv21Holder{
+				Address: l.Member,
+				Amt:     uint64(l.Score),
+				Balance: FormatAmount(uint64(l.Score), dec),
+			})
+		}
+		return holders, nil
+	}
+}
+
+func bsv21Data(txo *idx.Txo) (*Bsv21, error) {
+	if bsv21, ok := txo.Data[BSV21_TAG].Data.(*Bsv21); ok {
+		return bsv21, nil
+	} else if raw, ok := txo.Data[BSV21_TAG].Data.(json.RawMessage); !ok {
+		return nil, fmt.Errorf("%s: %w", txo.Outpoint.String(), idx.ErrMalformedData)
+	} else if bsv21, err := Bsv21FromBytes(raw); err != nil {
+		return nil, fmt.Errorf("%s: %w: %v", txo.Outpoint.String(), idx.ErrMalformedData, err)
+	} else {
+		return bsv21, nil
+	}
 }
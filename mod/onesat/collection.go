@@ -0,0 +1,100 @@
+package onesat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/shruggr/1sat-indexer/v5/evt"
+	"github.com/shruggr/1sat-indexer/v5/idx"
+	"github.com/shruggr/1sat-indexer/v5/lib"
+	"github.com/shruggr/1sat-indexer/v5/mod/bitcom"
+)
+
+// parentOrigin returns the origin of parent when parent is spent by the
+// transaction, and nil when the claimed provenance is not valid. A parent
+// with no known origin is its own origin.
+func parentOrigin(idxCtx *idx.IndexContext, parent *lib.Outpoint) *lib.Outpoint {
+	for _, spend := range idxCtx.Spends {
+		if !bytes.Equal(*spend.Outpoint, *parent) {
+			continue
+		}
+		if originData, ok := spend.Data[ORIGIN_TAG]; ok {
+			if origin, ok := originData.Data.(*Origin); ok && origin.Outpoint != nil {
+				return origin.Outpoint
+			}
+		}
+		return parent
+	}
+	return nil
+}
+
+// CollectionChildKey indexes the inscriptions whose valid parent has origin
+func CollectionChildKey(origin string) string {
+	return evt.EventKey(INSC_TAG, &evt.Event{
+		Id:    "child",
+		Value: origin,
+	})
+}
+
+type CollectionItem struct {
+	Outpoint    string          `json:"outpoint"`
+	Height      uint32          `json:"height"`
+	Idx         uint64          `json:"idx"`
+	Score       float64         `json:"score"`
+	Origin      *Origin         `json:"origin,omitempty"`
+	SubTypeData json.RawMessage `json:"subTypeData,omitempty"`
+}
+
+// LoadCollectionItems lists the children of the collection with origin,
+// along with the subTypeData of their MAP metadata
+func LoadCollectionItems(ctx context.Context, store idx.TxoStore, origin string, from float64, limit uint32) ([]*CollectionItem, error) {
+	txos, err := store.SearchTxos(ctx, &idx.SearchCfg{
+		Keys:        []string{CollectionChildKey(origin)},
+		From:        &from,
+		Limit:       limit,
+		IncludeTags: []string{ORIGIN_TAG},
+	})
+	if err != nil {
+		return nil, err
+	}
+	items := make([]*CollectionItem, 0, len(txos))
+	for _, txo := range txos {
+		item := &CollectionItem{
+			Outpoint: txo.Outpoint.String(),
+			Height:   txo.Height,
+			Idx:      txo.Idx,
+			Score:    txo.Score,
+		}
+		if originData, ok := txo.Data[ORIGIN_TAG]; ok {
+			switch data := originData.Data.(type) {
+			case *Origin:
+				item.Origin = data
+			case json.RawMessage:
+				if item.Origin, err = NewOriginFromBytes(data); err != nil {
+					return nil, err
+				}
+			}
+			if item.Origin != nil {
+				item.SubTypeData = subTypeData(item.Origin.Map)
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// subTypeData returns the subTypeData MAP field, which is usually itself a
+// JSON document carried as a string
+func subTypeData(m bitcom.Map) json.RawMessage {
+	v, ok := m["subTypeData"]
+	if !ok {
+		return nil
+	}
+	if str, ok := v.(string); ok && json.Valid([]byte(str)) {
+		return json.RawMessage(str)
+	} else if b, err := json.Marshal(v); err == nil {
+		return b
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package onesat
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/shruggr/1sat-indexer/v5/evt"
+	"github.com/shruggr/1sat-indexer/v5/idx"
+	"github.com/shruggr/1sat-indexer/v5/jb"
+	"github.com/shruggr/1sat-indexer/v5/lib"
+)
+
+func ContentKey(outpoint string) string {
+	return "content:" + outpoint
+}
+
+// ContentLoader re-extracts inscription bodies from raw transactions, since
+// only their hash, size and type are saved. Bodies up to MaxSize are kept in
+// Cache for TTL, which is renewed on every hit.
+type ContentLoader struct {
+	Source  jb.TxSource
+	Store   idx.TxoStore
+	Cache   *redis.Client
+	TTL     time.Duration
+	MaxSize int
+}
+
+// Stored returns the inscription saved for outpoint, whose file has no body, or nil
+// when the outpoint isn't indexed with one
+func (l *ContentLoader) Stored(ctx context.Context, outpoint *lib.Outpoint) (*Inscription, error) {
+	if l.Store == nil {
+		return nil, nil
+	} else if data, err := l.Store.LoadData(ctx, outpoint.String(), []string{INSC_TAG}); err != nil {
+		return nil, err
+	} else if inscData, ok := data[INSC_TAG]; !ok {
+		return nil, nil
+	} else if raw, ok := inscData.Data.(json.RawMessage); !ok {
+		return nil, fmt.Errorf("content %s: unexpected data type %T", outpoint, inscData.Data)
+	} else {
+		insc := &Inscription{}
+		if err := json.Unmarshal(raw, insc); err != nil {
+			return nil, err
+		} else if insc.File == nil {
+			return nil, nil
+		}
+		return insc, nil
+	}
+}
+
+// maxDelegateDepth bounds how many delegate hops Load follows
+const maxDelegateDepth = 4
+
+// Load returns the inscription body at outpoint. An empty inscription naming
+// a delegate is served with the delegate's body.
+func (l *ContentLoader) Load(ctx context.Context, outpoint *lib.Outpoint) (*lib.File, error) {
+	return l.load(ctx, outpoint, 0)
+}
+
+func (l *ContentLoader) load(ctx context.Context, outpoint *lib.Outpoint, depth int) (*lib.File, error) {
+	op := outpoint.String()
+	if l.Cache != nil {
+		if file, err := l.loadCached(ctx, op); err != nil {
+			return nil, err
+		} else if file != nil {
+			return file, nil
+		}
+	}
+
+	source := l.Source
+	if source == nil {
+		source = jb.Source
+	}
+	tx, err := jb.LoadTxFrom(ctx, source, outpoint.TxidHex(), false)
+	if err != nil {
+		return nil, err
+	} else if int(outpoint.Vout()) >= len(tx.Outputs) {
+		return nil, fmt.Errorf("content %s: %w", op, jb.ErrNotFound)
+	}
+	insc := FindInscription(&idx.Txo{}, tx.Outputs[outpoint.Vout()].LockingScript, nil)
+	if insc == nil || insc.File == nil {
+		return nil, fmt.Errorf("content %s: %w", op, jb.ErrNotFound)
+	}
+	file := insc.File
+	if len(file.Content) == 0 && insc.Delegate != nil && depth < maxDelegateDepth {
+		delegated, err := l.load(ctx, insc.Delegate, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		file = delegated
+	}
+
+	if l.Cache != nil && len(file.Content) <= l.MaxSize {
+		key := ContentKey(op)
+		if _, err := l.Cache.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, key,
+				"type", file.Type,
+				"enc", file.Encoding,
+				"hash", file.Hash,
+				"data", file.Content,
+			)
+			pipe.Expire(ctx, key, l.TTL)
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return file, nil
+}
+
+func (l *ContentLoader) loadCached(ctx context.Context, outpoint string) (*lib.File, error) {
+	key := ContentKey(outpoint)
+	if fields, err := l.Cache.HGetAll(ctx, key).Result(); err != nil {
+		return nil, err
+	} else if len(fields) == 0 {
+		return nil, nil
+	} else if err := l.Cache.Expire(ctx, key, l.TTL).Err(); err != nil {
+		return nil, err
+	} else {
+		return &lib.File{
+			Type:     fields["type"],
+			Encoding: fields["enc"],
+			Hash:     []byte(fields["hash"]),
+			Content:  []byte(fields["data"]),
+			Size:     uint32(len(fields["data"])),
+		}, nil
+	}
+}
+
+// ContentETag is the strong validator for an inscription body
+func ContentETag(file *lib.File) string {
+	return `"` + hex.EncodeToString(file.Hash) + `"`
+}
+
+// ETagMatches reports whether an If-None-Match header lists etag. Entries are compared
+// weakly, as If-None-Match requires.
+func ETagMatches(header string, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// LatestOrigin returns the most recent outpoint in the chain of origin, or
+// nil when the origin is not indexed
+func LatestOrigin(ctx context.Context, store idx.TxoStore, origin string) (*lib.Outpoint, error) {
+	if outpoints, err := store.SearchOutpoints(ctx, &idx.SearchCfg{
+		Keys: []string{evt.EventKey(ORIGIN_TAG, &evt.Event{
+			Id:    "outpoint",
+			Value: origin,
+		})},
+		Reverse: true,
+		Limit:   1,
+	}); err != nil {
+		return nil, err
+	} else if len(outpoints) == 0 {
+		return nil, nil
+	} else {
+		return lib.NewOutpointFromString(outpoints[0])
+	}
+}
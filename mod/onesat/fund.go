@@ -0,0 +1,321 @@
+package onesat
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/shruggr/1sat-indexer/v5/evt"
+	"github.com/shruggr/1sat-indexer/v5/idx"
+)
+
+// Bsv21FundStatus accounts for the indexing fees of a token. Every validated output
+// is charged BSV21_INDEX_FEE against the satoshis sent to the token's fund address.
+type Bsv21FundStatus struct {
+	Id          string `json:"id"`
+	FundAddress string `json:"fundAddress"`
+	Funded      uint64 `json:"funded"`
+	Validated   uint64 `json:"validated"`
+	Used        uint64 `json:"used"`
+	Balance     int64  `json:"balance"`
+	Queued      uint64 `json:"queued"`
+	Paused      bool   `json:"paused"`
+}
+
+// Bsv21FundedKey totals the satoshis sent to the fund address of each token from elsewhere
+const Bsv21FundedKey = "bsv21:funded"
+
+// Bsv21Funds maps fund addresses to the tokens they fund, so the satoshis they receive
+// are totalled as transactions are committed. Deploys are added as they are parsed,
+// and the rest are read from the store by Load and Watch.
+type Bsv21Funds struct {
+	Store  idx.TxoStore
+	mu     sync.RWMutex
+	tokens map[string]string
+	loaded map[string]struct{}
+}
+
+func NewBsv21Funds(store idx.TxoStore) *Bsv21Funds {
+	return &Bsv21Funds{
+		Store:  store,
+		tokens: map[string]string{},
+		loaded: map[string]struct{}{},
+	}
+}
+
+// Add registers the fund address of a token
+func (f *Bsv21Funds) Add(address string, tokenId string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.tokens[address] = tokenId
+	f.loaded[tokenId] = struct{}{}
+}
+
+// Token returns the token funded by address
+func (f *Bsv21Funds) Token(address string) (tokenId string, ok bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	tokenId, ok = f.tokens[address]
+	return
+}
+
+// Load adds the fund addresses of deploys in the store which are not yet registered
+func (f *Bsv21Funds) Load(ctx context.Context) error {
+	tokenIds, err := f.Store.SearchMembers(ctx, &idx.SearchCfg{
+		Keys: []string{evt.EventKey(BSV21_TAG, &evt.Event{Id: IssueEvent})},
+	})
+	if err != nil {
+		return err
+	}
+	f.mu.RLock()
+	missing := make([]string, 0)
+	for _, tokenId := range tokenIds {
+		if _, ok := f.loaded[tokenId]; !ok {
+			missing = append(missing, tokenId)
+		}
+	}
+	f.mu.RUnlock()
+	if len(missing) == 0 {
+		return nil
+	}
+	txos, err := f.Store.LoadTxos(ctx, missing, []string{BSV21_TAG}, false, false)
+	if err != nil {
+		return err
+	}
+	for _, txo := range txos {
+		if txo == nil || txo.Data[BSV21_TAG] == nil {
+			continue
+		} else if deploy, err := bsv21Data(txo); err != nil {
+			return err
+		} else if deploy.Op == "deploy+mint" && deploy.FundAddress != "" {
+			if err := backfillBsv21Funded(ctx, f.Store, deploy); err != nil {
+				return err
+			}
+			f.Add(deploy.FundAddress, deploy.Id)
+		}
+	}
+	return nil
+}
+
+// backfillBsv21Funded totals the fund outputs already in the store for a token without a
+// funded total, such as one deployed before totals were kept on commit
+func backfillBsv21Funded(ctx context.Context, store idx.TxoStore, deploy *Bsv21) error {
+	if funded, err := store.LogScore(ctx, Bsv21FundedKey, deploy.Id); err != nil || funded > 0 {
+		return err
+	}
+	funds, err := store.SearchTxos(ctx, &idx.SearchCfg{
+		Keys:          []string{idx.OwnerKey(deploy.FundAddress)},
+		OutpointsOnly: true,
+		IncludeTxo:    true,
+		IncludeSpend:  true,
+	})
+	if err != nil {
+		return err
+	}
+	received := make(map[string]uint64, len(funds))
+	spent := make(map[string]uint64, len(funds))
+	for _, fund := range funds {
+		if fund != nil && fund.Satoshis != nil {
+			received[fund.Outpoint.TxidHex()] += *fund.Satoshis
+			if fund.Spend != "" {
+				spent[fund.Spend] += *fund.Satoshis
+			}
+		}
+	}
+	funded := uint64(0)
+	for txid, sats := range received {
+		funded += sats - min(sats, spent[txid])
+	}
+	if funded == 0 {
+		return nil
+	}
+	return store.Log(ctx, Bsv21FundedKey, deploy.Id, float64(funded))
+}
+
+// Watch loads new deploys every interval until ctx is cancelled
+func (f *Bsv21Funds) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := f.Load(ctx); err != nil {
+				log.Println("bsv21 funds reload", err)
+			}
+		}
+	}
+}
+
+// tally moves the funded total of each token by the satoshis the transaction sends to
+// its fund address from elsewhere. Change and consolidations which the fund address
+// pays back to itself are netted against the fund outputs they spend, while spending
+// fund outputs never lowers the total, so sweeping the fund doesn't pause validation.
+func (f *Bsv21Funds) tally(idxCtx *idx.IndexContext) {
+	if f == nil {
+		return
+	}
+	net := map[string]int64{}
+	sum := func(txo *idx.Txo, sign int64) {
+		if txo.Satoshis == nil {
+			return
+		}
+		for _, owner := range txo.Owners {
+			if tokenId, ok := f.Token(owner); ok {
+				net[tokenId] += sign * int64(*txo.Satoshis)
+				return
+			}
+		}
+	}
+	for _, txo := range idxCtx.Txos {
+		sum(txo, 1)
+	}
+	for _, spend := range idxCtx.Spends {
+		sum(spend, -1)
+	}
+	for tokenId, sats := range net {
+		if sats > 0 {
+			idxCtx.Incr(Bsv21FundedKey, tokenId, float64(sats))
+		}
+	}
+}
+
+// ApplyFunds attaches a Bsv21Funds registry to each BSV-21 indexer in indexers, loading
+// the deploys in store and new ones every interval until ctx is cancelled
+func ApplyFunds(ctx context.Context, store idx.TxoStore, indexers []idx.Indexer, interval time.Duration) error {
+	for _, indexer := range indexers {
+		if i, ok := indexer.(*Bsv21Indexer); ok {
+			i.Funds = NewBsv21Funds(store)
+			if err := i.Funds.Load(ctx); err != nil {
+				return err
+			}
+			go i.Funds.Watch(ctx, interval)
+		}
+	}
+	return nil
+}
+
+// LoadBsv21FundStatus returns the fund status of a token, or nil when the token has not been deployed
+func LoadBsv21FundStatus(ctx context.Context, store idx.TxoStore, tokenId string) (*Bsv21FundStatus, error) {
+	txo, err := store.LoadTxo(ctx, tokenId, []string{BSV21_TAG}, false, false)
+	if err != nil {
+		return nil, err
+	} else if txo == nil || txo.Data[BSV21_TAG] == nil {
+		return nil, nil
+	}
+	deploy, err := bsv21Data(txo)
+	if err != nil {
+		return nil, err
+	} else if deploy.Op != "deploy+mint" {
+		return nil, nil
+	}
+	status := &Bsv21FundStatus{
+		Id:          tokenId,
+		FundAddress: deploy.FundAddress,
+	}
+
+	if funded, err := store.LogScore(ctx, Bsv21FundedKey, tokenId); err != nil {
+		return nil, err
+	} else {
+		status.Funded = uint64(funded)
+	}
+
+	for _, event := range []string{ValidEvent, InvalidEvent} {
+		if count, err := store.CountMembers(ctx, evt.EventKey(BSV21_TAG, &evt.Event{
+			Id:    event,
+			Value: tokenId,
+		})); err != nil {
+			return nil, err
+		} else {
+			status.Validated += count
+		}
+	}
+	// The deploy output is not charged
+	if status.Validated > 0 {
+		status.Validated--
+	}
+	status.Used = status.Validated * BSV21_INDEX_FEE
+	status.Balance = int64(status.Funded) - int64(status.Used)
+
+	if status.Queued, err = store.CountMembers(ctx, idx.QueueKey(tokenId)); err != nil {
+		return nil, err
+	}
+	status.Paused = status.Queued > 0 && status.Balance < BSV21_INDEX_FEE
+	return status, nil
+}
+
+// Bsv21Validator ingests the transactions queued for a token while its fund covers the indexing fee.
+// Queued transactions stay pending until the fund address is topped up.
+type Bsv21Validator struct {
+	Ingest *idx.IngestCtx
+}
+
+// Validate processes the queue of a token until it is empty or the fund is exhausted.
+// Each transaction is parsed first and only committed while the fund covers every output
+// it settles. Transactions which fail to ingest are retried through the token queue, so
+// one bad transaction doesn't hold up the rest.
+func (v *Bsv21Validator) Validate(ctx context.Context, tokenId string) (*Bsv21FundStatus, error) {
+	queueKey := idx.QueueKey(tokenId)
+	status, err := LoadBsv21FundStatus(ctx, v.Ingest.Store, tokenId)
+	if err != nil || status == nil || status.Queued == 0 || status.Paused {
+		return status, err
+	}
+	// Retried transactions are rescheduled after to, so each is tried once per call
+	to := float64(time.Now().UnixNano())
+	balance := status.Balance
+	exhausted := false
+	for !exhausted && balance >= BSV21_INDEX_FEE {
+		logs, err := v.Ingest.Store.Search(ctx, &idx.SearchCfg{
+			Keys:  []string{queueKey},
+			Limit: v.Ingest.PageSize,
+			To:    &to,
+		})
+		if err != nil {
+			return nil, err
+		} else if len(logs) == 0 {
+			break
+		}
+		for _, l := range logs {
+			idxCtx, err := v.Ingest.ParseTxid(ctx, l.Member, v.Ingest.AncestorConfig)
+			var fee int64
+			if err == nil {
+				if fee = int64(charged(idxCtx, tokenId) * BSV21_INDEX_FEE); fee > balance {
+					exhausted = true
+					break
+				}
+				err = v.Ingest.Save(ctx, idxCtx)
+			}
+			if err != nil {
+				log.Println("bsv21 validate", tokenId, l.Member, err)
+				if err := v.Ingest.RetryIn(ctx, queueKey, l.Member, err); err != nil {
+					return nil, err
+				}
+			} else if err := v.Ingest.Store.Delog(ctx, queueKey, l.Member); err != nil {
+				return nil, err
+			} else if err := v.Ingest.Store.Delog(ctx, idx.AttemptsKey(v.Ingest.Tag), l.Member); err != nil {
+				return nil, err
+			} else {
+				balance -= fee
+			}
+		}
+	}
+	if status, err = LoadBsv21FundStatus(ctx, v.Ingest.Store, tokenId); err == nil && status != nil && exhausted {
+		// The next transaction settles more outputs than the balance covers
+		status.Paused = true
+	}
+	return status, err
+}
+
+// charged counts the outputs of a token which idxCtx settled, each of which is charged the index fee
+func charged(idxCtx *idx.IndexContext, tokenId string) (count uint64) {
+	for _, txo := range idxCtx.Txos {
+		if idxData, ok := txo.Data[BSV21_TAG]; ok {
+			if bsv21, ok := idxData.Data.(*Bsv21); ok && bsv21.Id == tokenId && bsv21.Op != "deploy+mint" && bsv21.Status != Pending {
+				count++
+			}
+		}
+	}
+	return
+}
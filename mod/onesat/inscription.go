@@ -3,7 +3,9 @@ package onesat
 import (
 	"bytes"
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/json"
+	"log"
 	"regexp"
 	"strings"
 	"unicode/utf8"
@@ -20,12 +22,17 @@ var INSC_TAG = "insc"
 var AsciiRegexp = regexp.MustCompile(`^[[:ascii:]]*$`)
 
 type Inscription struct {
-	Json    json.RawMessage   `json:"json,omitempty"`
-	JsonMap map[string]string `json:"-"`
-	Text    string            `json:"text,omitempty"`
-	File    *lib.File         `json:"file,omitempty"`
-	Pointer *uint64           `json:"pointer,omitempty"`
-	Parent  *lib.Outpoint     `json:"parent,omitempty"`
+	Json      json.RawMessage   `json:"json,omitempty"`
+	JsonMap   map[string]string `json:"-"`
+	Text      string            `json:"text,omitempty"`
+	File      *lib.File         `json:"file,omitempty"`
+	Pointer   *uint64           `json:"pointer,omitempty"`
+	Parent    *lib.Outpoint     `json:"parent,omitempty"`
+	Metaproto string            `json:"metaproto,omitempty"`
+	Metadata  json.RawMessage   `json:"metadata,omitempty"`
+	Delegate  *lib.Outpoint     `json:"delegate,omitempty"`
+	// Additional holds any further envelopes in the same output
+	Additional []*Inscription `json:"additional,omitempty"`
 }
 
 type InscriptionIndexer struct {
@@ -51,32 +58,85 @@ func (i *InscriptionIndexer) Parse(idxCtx *idx.IndexContext, vout uint32) *idx.I
 	}
 	scr := idxCtx.Tx.Outputs[vout].LockingScript
 
+	inscs := FindInscriptions(txo, scr, txo.Data[bitcom.BITCOM_TAG])
+	if len(inscs) == 0 {
+		return nil
+	}
+	insc := inscs[0]
+	insc.Additional = inscs[1:]
+	idxData := &idx.IndexData{
+		Data: insc,
+	}
+	seen := make(map[string]struct{})
+	for _, insc := range inscs {
+		events := insc.events()
+		if insc.Parent != nil {
+			if origin := parentOrigin(idxCtx, insc.Parent); origin != nil {
+				events = append(events, &evt.Event{
+					Id:    "parent",
+					Value: insc.Parent.String(),
+				}, &evt.Event{
+					Id:    "child",
+					Value: origin.String(),
+				})
+			}
+		}
+		for _, e := range events {
+			if _, ok := seen[e.Id+":"+e.Value]; !ok {
+				seen[e.Id+":"+e.Value] = struct{}{}
+				idxData.Events = append(idxData.Events, e)
+			}
+		}
+	}
+	return idxData
+}
+
+func (insc *Inscription) events() (events []*evt.Event) {
+	if insc.File != nil {
+		parts := strings.Split(insc.File.Type, ";")
+		if len(parts) > 0 {
+			events = append(events, &evt.Event{
+				Id:    "type",
+				Value: parts[0],
+			})
+		}
+	}
+	if insc.Delegate != nil {
+		events = append(events, &evt.Event{
+			Id:    "delegate",
+			Value: insc.Delegate.String(),
+		})
+	}
+	if insc.Metaproto != "" {
+		events = append(events, &evt.Event{
+			Id:    "metaproto",
+			Value: insc.Metaproto,
+		})
+	}
+	return
+}
+
+// FindInscription parses the first ord envelope in scr
+func FindInscription(txo *idx.Txo, scr *script.Script, bitcomData *idx.IndexData) *Inscription {
+	if inscs := FindInscriptions(txo, scr, bitcomData); len(inscs) > 0 {
+		return inscs[0]
+	}
+	return nil
+}
+
+// FindInscriptions parses every ord envelope in scr, in script order
+func FindInscriptions(txo *idx.Txo, scr *script.Script, bitcomData *idx.IndexData) (inscs []*Inscription) {
 	for i := 0; i < len(*scr); {
 		startI := i
 		if op, err := scr.ReadOp(&i); err != nil {
 			break
-		} else if i > 2 && op.Op == script.OpDATA3 && bytes.Equal(op.Data, []byte("ord")) && (*scr)[startI-2] == 0 && (*scr)[startI-1] == script.OpIF {
-			insc := ParseInscription(txo, scr, &i, txo.Data[bitcom.BITCOM_TAG])
-			if insc != nil {
-
-				idxCtx := &idx.IndexData{
-					Data: insc,
-				}
-				if insc.File != nil {
-					parts := strings.Split(insc.File.Type, ";")
-					if len(parts) > 0 {
-						idxCtx.Events = append(idxCtx.Events, &evt.Event{
-							Id:    "type",
-							Value: parts[0],
-						})
-					}
-				}
-				return idxCtx
+		} else if startI >= 2 && op.Op == script.OpDATA3 && bytes.Equal(op.Data, []byte("ord")) && (*scr)[startI-2] == 0 && (*scr)[startI-1] == script.OpIF {
+			if insc := ParseInscription(txo, scr, &i, bitcomData); insc != nil {
+				inscs = append(inscs, insc)
 			}
-			break
 		}
 	}
-	return nil
+	return
 }
 
 func (i *InscriptionIndexer) PreSave(idxCtx *idx.IndexContext) {
@@ -84,6 +144,9 @@ func (i *InscriptionIndexer) PreSave(idxCtx *idx.IndexContext) {
 		if inscData, ok := txo.Data[INSC_TAG]; ok {
 			if insc, ok := inscData.Data.(*Inscription); ok {
 				insc.File.Content = nil
+				for _, additional := range insc.Additional {
+					additional.File.Content = nil
+				}
 			}
 		}
 	}
@@ -94,6 +157,7 @@ func ParseInscription(txo *idx.Txo, scr *script.Script, fromPos *int, bitcomData
 		File: &lib.File{},
 	}
 	pos := *fromPos
+	var metadata []byte
 
 ordLoop:
 	for {
@@ -128,16 +192,23 @@ ordLoop:
 			if len(op2.Data) < 256 && utf8.Valid(op2.Data) {
 				insc.File.Type = string(op2.Data)
 			}
-		// case 2:
-		// 	var pointer uint64
-		// 	if len(op2.Data) > 0 {
-		// 		pointer = binary.LittleEndian.Uint64(op2.Data)
-		// 	}
-		// 	insc.Pointer = &pointer
+		case 2:
+			if len(op2.Data) <= 8 {
+				buf := make([]byte, 8)
+				copy(buf, op2.Data)
+				pointer := binary.LittleEndian.Uint64(buf)
+				insc.Pointer = &pointer
+			}
 		case 3:
 			insc.Parent = lib.NewOutpointFromBytes(op2.Data)
+		case 5:
+			metadata = append(metadata, op2.Data...)
+		case 7:
+			insc.Metaproto = string(op2.Data)
 		case 9:
 			insc.File.Encoding = string(op2.Data)
+		case 11:
+			insc.Delegate = lib.NewOutpointFromBytes(op2.Data)
 		}
 
 	}
@@ -147,6 +218,16 @@ ordLoop:
 	}
 	*fromPos = pos
 
+	// Metadata may be split across several pushes of field 5
+	if len(metadata) > 0 {
+		if v, err := lib.DecodeCBOR(metadata); err != nil {
+			log.Println("inscription metadata", txo.Outpoint, err)
+		} else if insc.Metadata, err = json.Marshal(v); err != nil {
+			// CBOR floats may be NaN or infinite, which JSON can't represent
+			log.Println("inscription metadata", txo.Outpoint, err)
+		}
+	}
+
 	insc.File.Size = uint32(len(insc.File.Content))
 	hash := sha256.Sum256(insc.File.Content)
 	insc.File.Hash = hash[:]
@@ -0,0 +1,129 @@
+package onesat
+
+import (
+	"context"
+	"math"
+
+	"github.com/shruggr/1sat-indexer/v5/evt"
+	"github.com/shruggr/1sat-indexer/v5/idx"
+)
+
+const (
+	SortRecent   = "recent"
+	SortPrice    = "price"
+	SortPricePer = "pricePer"
+)
+
+// MarketPriceKey holds the open listings for tag, scored by price
+func MarketPriceKey(tag string) string {
+	return "mkt:price:" + tag
+}
+
+// MarketPricePerKey holds the open listings for tag, scored by price per token
+func MarketPricePerKey(tag string) string {
+	return "mkt:ppu:" + tag
+}
+
+// logListing indexes an open listing by price. The prices are only recorded when the
+// transaction is committed and are removed when it is rolled back.
+func logListing(idxCtx *idx.IndexContext, outpoint string, ordLock *OrdLock) {
+	for _, tag := range ordLock.Tags {
+		idxCtx.Log(MarketPriceKey(tag), outpoint, float64(ordLock.Price))
+		idxCtx.Log(MarketPricePerKey(tag), outpoint, ordLock.PricePer)
+	}
+}
+
+// delogListing removes a listing closed by idxCtx from the price indexes. Rolling back
+// the closing transaction restores it.
+func delogListing(idxCtx *idx.IndexContext, outpoint string, ordLock *OrdLock) {
+	for _, tag := range ordLock.Tags {
+		idxCtx.Delog(MarketPriceKey(tag), outpoint)
+		idxCtx.Delog(MarketPricePerKey(tag), outpoint)
+	}
+}
+
+// listable reports whether a listed output carries no tokens, or only validated ones
+func listable(txo *idx.Txo) bool {
+	if idxData, ok := txo.Data[BSV21_TAG]; ok {
+		bsv21, ok := idxData.Data.(*Bsv21)
+		return ok && bsv21.Status == Valid
+	} else if idxData, ok := txo.Data[BSV20_TAG]; ok {
+		bsv20, ok := idxData.Data.(*Bsv20)
+		return ok && bsv20.Status == Valid
+	}
+	return true
+}
+
+// MarketTags are the tags returned alongside listings and sales
+var MarketTags = []string{ORDLOCK_TAG, ORIGIN_TAG, BSV20_TAG, BSV21_TAG}
+
+type ListingsCfg struct {
+	Tag  string
+	Sort string
+	// Min and Max are inclusive price bounds, ignored when sorting by recent
+	Min     *float64
+	Max     *float64
+	From    *float64
+	Reverse bool
+	Limit   uint32
+}
+
+// SearchListings returns the open listings for a tag. Listings sorted by
+// recent are paged by HeightScore, the others by price.
+func SearchListings(ctx context.Context, store idx.TxoStore, cfg *ListingsCfg) ([]*idx.Txo, error) {
+	search := &idx.SearchCfg{
+		From:        cfg.From,
+		Reverse:     cfg.Reverse,
+		Limit:       cfg.Limit,
+		IncludeTags: MarketTags,
+		FilterSpent: true,
+	}
+	switch cfg.Sort {
+	case SortPrice, SortPricePer:
+		if cfg.Sort == SortPrice {
+			search.Keys = []string{MarketPriceKey(cfg.Tag)}
+		} else {
+			search.Keys = []string{MarketPricePerKey(cfg.Tag)}
+		}
+		var lower, upper *float64
+		if cfg.Min != nil {
+			lo := math.Nextafter(*cfg.Min, math.Inf(-1))
+			lower = &lo
+		}
+		if cfg.Max != nil {
+			hi := math.Nextafter(*cfg.Max, math.Inf(1))
+			upper = &hi
+		}
+		if cfg.Reverse {
+			if search.From == nil || (upper != nil && *upper < *search.From) {
+				search.From = upper
+			}
+			search.To = lower
+		} else {
+			if search.From == nil || (lower != nil && *lower > *search.From) {
+				search.From = lower
+			}
+			search.To = upper
+		}
+	default:
+		search.Keys = []string{evt.EventKey(ORDLOCK_TAG, &evt.Event{
+			Id:    "list",
+			Value: cfg.Tag,
+		})}
+	}
+	return store.SearchTxos(ctx, search)
+}
+
+// SearchSales returns the completed sales for a tag, paged by HeightScore
+func SearchSales(ctx context.Context, store idx.TxoStore, tag string, from *float64, reverse bool, limit uint32) ([]*idx.Txo, error) {
+	return store.SearchTxos(ctx, &idx.SearchCfg{
+		Keys: []string{evt.EventKey(ORDLOCK_TAG, &evt.Event{
+			Id:    "sale",
+			Value: tag,
+		})},
+		From:        from,
+		Reverse:     reverse,
+		Limit:       limit,
+		IncludeTags: MarketTags,
+	})
+}
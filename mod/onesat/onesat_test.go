@@ -0,0 +1,779 @@
+package onesat
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bsv-blockchain/go-sdk/chainhash"
+	"github.com/bsv-blockchain/go-sdk/script"
+	"github.com/bsv-blockchain/go-sdk/transaction"
+	"github.com/bsv-blockchain/go-sdk/transaction/template/p2pkh"
+	"github.com/shruggr/1sat-indexer/v5/evt"
+	"github.com/shruggr/1sat-indexer/v5/idx"
+	"github.com/shruggr/1sat-indexer/v5/idxtest"
+	"github.com/shruggr/1sat-indexer/v5/lib"
+	"github.com/shruggr/1sat-indexer/v5/mod/bitcom"
+	"github.com/stretchr/testify/assert"
+)
+
+const owner = "1Fj8GVwfV6GnjpqN7t8GvxugKN4SAyER9X"
+
+func harness(t *testing.T) *idxtest.Harness {
+	return idxtest.New(t,
+		&bitcom.BitcomIndexer{},
+		&InscriptionIndexer{},
+		&bitcom.MapIndexer{},
+		&OriginIndexer{},
+		&Bsv20Indexer{},
+		&Bsv21Indexer{},
+		&OrdLockIndexer{},
+	)
+}
+
+func TestInscription(t *testing.T) {
+	h := harness(t)
+	idxCtx := h.Parse("insc")
+
+	insc := h.Data(idxCtx, 0, INSC_TAG).Data.(*Inscription)
+	assert.Equal(t, "text/plain;charset=utf-8", insc.File.Type)
+	assert.Equal(t, "Hello, world", insc.Text)
+	assert.Equal(t, uint32(12), insc.File.Size)
+	assert.Nil(t, insc.File.Content)
+	assert.Equal(t, []string{"evt:insc:type:text/plain"}, idxtest.Events(idxCtx, 0, INSC_TAG))
+	assert.Contains(t, idxCtx.Txos[0].Owners, owner)
+
+	insc = h.Data(idxCtx, 1, INSC_TAG).Data.(*Inscription)
+	assert.JSONEq(t, `{"name":"test"}`, string(insc.Json))
+
+	h.Golden("insc", idxCtx)
+}
+
+func TestInscriptionFields(t *testing.T) {
+	h := harness(t)
+	ref := h.Outpoint("insc", 0)
+	parent, err := lib.NewOutpointFromString(ref)
+	assert.NoError(t, err)
+
+	scr := &script.Script{}
+	scr.AppendOpcodes(script.OpFALSE, script.OpIF)
+	scr.AppendPushDataString("ord")
+	scr.AppendOpcodes(script.Op1)
+	scr.AppendPushDataString("text/plain")
+	scr.AppendOpcodes(script.Op2)
+	scr.AppendPushData([]byte{0x0a})
+	scr.AppendOpcodes(script.Op3)
+	scr.AppendPushData(*parent)
+	// {"name":"test","n":[1,2]} split across two metadata pushes
+	scr.AppendOpcodes(script.Op5)
+	scr.AppendPushDataHex("a2646e616d65")
+	scr.AppendOpcodes(script.Op5)
+	scr.AppendPushDataHex("6474657374616e820102")
+	scr.AppendOpcodes(script.Op7)
+	scr.AppendPushDataString("test-proto")
+	scr.AppendOpcodes(script.Op11)
+	scr.AppendPushData(*parent)
+	scr.AppendOpcodes(script.Op0, script.Op0, script.OpENDIF)
+	scr.AppendOpcodes(script.OpFALSE, script.OpIF)
+	scr.AppendPushDataString("ord")
+	scr.AppendOpcodes(script.Op1)
+	scr.AppendPushDataString("text/plain")
+	scr.AppendOpcodes(script.Op0)
+	scr.AppendPushDataString("second")
+	scr.AppendOpcodes(script.OpENDIF)
+
+	tx := transaction.NewTransaction()
+	tx.AddInput(&transaction.TransactionInput{
+		SourceTXID:       h.Tx("fund").TxID(),
+		SourceTxOutIndex: 0,
+		UnlockingScript:  &script.Script{},
+		SequenceNumber:   0xffffffff,
+	})
+	tx.AddOutput(&transaction.TransactionOutput{
+		Satoshis:      1,
+		LockingScript: scr,
+	})
+	h.AddTx("insc-fields", tx)
+	idxCtx := h.Parse("insc-fields")
+
+	insc := h.Data(idxCtx, 0, INSC_TAG).Data.(*Inscription)
+	assert.Equal(t, uint64(10), *insc.Pointer)
+	assert.Equal(t, ref, insc.Parent.String())
+	assert.Equal(t, ref, insc.Delegate.String())
+	assert.Equal(t, "test-proto", insc.Metaproto)
+	assert.JSONEq(t, `{"name":"test","n":[1,2]}`, string(insc.Metadata))
+	assert.Equal(t, uint32(0), insc.File.Size)
+	assert.Len(t, insc.Additional, 1)
+	assert.Equal(t, "second", insc.Additional[0].Text)
+	// The parent is not spent, so no provenance events are emitted
+	assert.Equal(t, []string{
+		"evt:insc:type:text/plain",
+		"evt:insc:delegate:" + ref,
+		"evt:insc:metaproto:test-proto",
+	}, idxtest.Events(idxCtx, 0, INSC_TAG))
+
+	// An empty inscription serves its delegate's content
+	file, err := (&ContentLoader{Source: h.Source}).Load(context.Background(), lib.NewOutpointFromHash(tx.TxID(), 0))
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello, world", string(file.Content))
+}
+
+func TestCollection(t *testing.T) {
+	h := harness(t)
+	ctx := context.Background()
+	h.Ingest("insc")
+	origin := h.Outpoint("insc", 0)
+	parent, err := lib.NewOutpointFromString(origin)
+	assert.NoError(t, err)
+
+	scr := &script.Script{}
+	scr.AppendOpcodes(script.OpFALSE, script.OpIF)
+	scr.AppendPushDataString("ord")
+	scr.AppendOpcodes(script.Op1)
+	scr.AppendPushDataString("text/plain")
+	scr.AppendOpcodes(script.Op3)
+	scr.AppendPushData(*parent)
+	scr.AppendOpcodes(script.Op0)
+	scr.AppendPushDataString("child")
+	scr.AppendOpcodes(script.OpENDIF, script.OpRETURN)
+	scr.AppendPushDataStrings([]string{bitcom.MAP_PROTO, "SET", "app", "test", "type", "ord", "subTypeData", `{"name":"One"}`})
+
+	tx := transaction.NewTransaction()
+	tx.AddInput(&transaction.TransactionInput{
+		SourceTXID:       parent.TxidHash(),
+		SourceTxOutIndex: parent.Vout(),
+		UnlockingScript:  &script.Script{},
+		SequenceNumber:   0xffffffff,
+	})
+	tx.AddOutput(&transaction.TransactionOutput{
+		Satoshis:      1,
+		LockingScript: scr,
+	})
+	h.AddTx("insc-child", tx)
+	idxCtx := h.Ingest("insc-child")
+	assert.Contains(t, idxtest.Events(idxCtx, 0, INSC_TAG), "evt:insc:parent:"+origin)
+	assert.Contains(t, idxtest.Events(idxCtx, 0, INSC_TAG), "evt:insc:child:"+origin)
+
+	items, err := LoadCollectionItems(ctx, h.Store, origin, 0, 100)
+	assert.NoError(t, err)
+	assert.Len(t, items, 1)
+	assert.Equal(t, h.Outpoint("insc-child", 0), items[0].Outpoint)
+	assert.JSONEq(t, `{"name":"One"}`, string(items[0].SubTypeData))
+
+	items, err = LoadCollectionItems(ctx, h.Store, h.Outpoint("insc-child", 0), 0, 100)
+	assert.NoError(t, err)
+	assert.Empty(t, items)
+}
+
+func TestContent(t *testing.T) {
+	h := harness(t)
+	h.Ingest("insc")
+	ctx := context.Background()
+
+	outpoint, err := LatestOrigin(ctx, h.Store, h.Outpoint("insc", 0))
+	assert.NoError(t, err)
+	assert.Equal(t, h.Outpoint("insc", 0), outpoint.String())
+
+	loader := &ContentLoader{Source: h.Source}
+	file, err := loader.Load(ctx, outpoint)
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello, world", string(file.Content))
+	assert.Equal(t, "text/plain;charset=utf-8", file.Type)
+	assert.Equal(t, `"4ae7c3b6ac0beff671efa8cf57386151c06e58ca53a78d83f36107316cec125f"`, ContentETag(file))
+
+	// The saved inscription has the same type and validator without loading the transaction
+	loader.Store = h.Store
+	insc, err := loader.Stored(ctx, outpoint)
+	assert.NoError(t, err)
+	assert.Equal(t, file.Type, insc.File.Type)
+	assert.Equal(t, ContentETag(file), ContentETag(insc.File))
+	assert.Empty(t, insc.File.Content)
+	insc, err = loader.Stored(ctx, lib.NewOutpointFromHash(outpoint.TxidHash(), 99))
+	assert.NoError(t, err)
+	assert.Nil(t, insc)
+
+	etag := ContentETag(file)
+	for header, match := range map[string]bool{
+		"":                 false,
+		etag:               true,
+		`"other", ` + etag: true,
+		`W/` + etag:        true,
+		"*":                true,
+		`"other", "third"`: false,
+	} {
+		assert.Equal(t, match, ETagMatches(header, etag), header)
+	}
+
+	outpoint, err = LatestOrigin(ctx, h.Store, h.Outpoint("bsv20-deploy", 0))
+	assert.NoError(t, err)
+	assert.Nil(t, outpoint)
+}
+
+func TestBsv20(t *testing.T) {
+	h := harness(t)
+	ctx := context.Background()
+	deploy := h.Outpoint("bsv20-deploy", 0)
+
+	idxCtx := h.Ingest("bsv20-deploy")
+	bsv20 := h.Data(idxCtx, 0, BSV20_TAG).Data.(*Bsv20)
+	assert.Equal(t, "TEST", bsv20.Ticker)
+	assert.Equal(t, "deploy", bsv20.Op)
+	assert.Equal(t, uint64(21000000), bsv20.Max)
+	assert.Equal(t, uint64(1000), bsv20.Limit)
+	assert.Equal(t, Valid, bsv20.Status)
+	assert.Contains(t, idxtest.Events(idxCtx, 0, BSV20_TAG), "evt:bsv20:val:TEST")
+	h.Golden("bsv20-deploy", idxCtx)
+
+	idxCtx = h.Parse("bsv20-deploy-dup")
+	bsv20 = h.Data(idxCtx, 0, BSV20_TAG).Data.(*Bsv20)
+	assert.Equal(t, Invalid, bsv20.Status)
+	assert.Equal(t, "duplicate deploy", *bsv20.Reason)
+	assert.Contains(t, idxtest.Events(idxCtx, 0, BSV20_TAG), "evt:bsv20:inv:TEST")
+	h.Golden("bsv20-deploy-dup", idxCtx)
+
+	idxCtx = h.Ingest("bsv20-mint")
+	bsv20 = h.Data(idxCtx, 0, BSV20_TAG).Data.(*Bsv20)
+	assert.Equal(t, "mint", bsv20.Op)
+	assert.Equal(t, uint64(1000), *bsv20.Amt)
+	assert.Equal(t, Valid, bsv20.Status)
+	assert.Equal(t, uint64(1000), bsv20.Supply)
+	assert.Equal(t, []string{"evt:bsv20:tick:TEST", "evt:bsv20:mint:TEST", "evt:bsv20:val:TEST"}, idxtest.Events(idxCtx, 0, BSV20_TAG))
+	h.Golden("bsv20-mint", idxCtx)
+
+	// Reingesting a mint does not count it twice
+	h.Ingest("bsv20-mint")
+	ticker, err := LoadBsv20Ticker(ctx, h.Store, "test")
+	assert.NoError(t, err)
+	assert.Equal(t, deploy, ticker.Deploy)
+	assert.Equal(t, uint64(21000000), ticker.Max)
+	assert.Equal(t, uint64(1000), ticker.Supply)
+	assert.Equal(t, uint64(1), ticker.Mints)
+
+	idxCtx = h.Parse("bsv20-mint-over-limit")
+	bsv20 = h.Data(idxCtx, 0, BSV20_TAG).Data.(*Bsv20)
+	assert.Equal(t, Invalid, bsv20.Status)
+	assert.Equal(t, "exceeds lim", *bsv20.Reason)
+	h.Golden("bsv20-mint-over-limit", idxCtx)
+
+	idxCtx = h.Parse("bsv20-transfer")
+	for vout := uint32(0); vout < 2; vout++ {
+		bsv20 = h.Data(idxCtx, vout, BSV20_TAG).Data.(*Bsv20)
+		assert.Equal(t, Valid, bsv20.Status)
+		assert.Contains(t, idxtest.Events(idxCtx, vout, BSV20_TAG), "evt:bsv20:val:TEST")
+	}
+	h.Golden("bsv20-transfer", idxCtx)
+
+	idxCtx = h.Parse("bsv20-overspend")
+	bsv20 = h.Data(idxCtx, 0, BSV20_TAG).Data.(*Bsv20)
+	assert.Equal(t, Invalid, bsv20.Status)
+	assert.Equal(t, "insufficient funds", *bsv20.Reason)
+	assert.Contains(t, idxtest.Events(idxCtx, 0, BSV20_TAG), "evt:bsv20:inv:TEST")
+	h.Golden("bsv20-overspend", idxCtx)
+
+	// Each output is checked against the balance left by the outputs before it
+	idxCtx = h.Parse("bsv20-partial-overspend")
+	bsv20 = h.Data(idxCtx, 0, BSV20_TAG).Data.(*Bsv20)
+	assert.Equal(t, Invalid, bsv20.Status)
+	assert.Equal(t, "insufficient funds", *bsv20.Reason)
+	bsv20 = h.Data(idxCtx, 1, BSV20_TAG).Data.(*Bsv20)
+	assert.Equal(t, Valid, bsv20.Status)
+	assert.Nil(t, bsv20.Reason)
+}
+
+func TestBsv20ParseOnly(t *testing.T) {
+	h := harness(t)
+	ctx := context.Background()
+
+	// Parsing a deploy or mint records nothing until it is saved
+	h.Parse("bsv20-deploy")
+	idxCtx := h.Parse("bsv20-mint")
+	bsv20 := h.Data(idxCtx, 0, BSV20_TAG).Data.(*Bsv20)
+	assert.Equal(t, Invalid, bsv20.Status)
+	assert.Equal(t, "ticker not deployed", *bsv20.Reason)
+	ticker, err := LoadBsv20Ticker(ctx, h.Store, "test")
+	assert.NoError(t, err)
+	assert.Nil(t, ticker)
+
+	h.Ingest("bsv20-deploy")
+	h.Parse("bsv20-mint")
+	ticker, err = LoadBsv20Ticker(ctx, h.Store, "test")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), ticker.Supply)
+	assert.Equal(t, uint64(0), ticker.Mints)
+
+	// Rolling back a mint removes it from the supply
+	h.Ingest("bsv20-mint")
+	assert.NoError(t, h.Store.Rollback(ctx, h.Txid("bsv20-mint")))
+	ticker, err = LoadBsv20Ticker(ctx, h.Store, "test")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), ticker.Supply)
+	assert.Equal(t, uint64(0), ticker.Mints)
+}
+
+func TestBsv21(t *testing.T) {
+	h := harness(t)
+	id := h.Outpoint("bsv21-deploy", 0)
+
+	idxCtx := h.Parse("bsv21-deploy")
+	bsv21 := h.Data(idxCtx, 0, BSV21_TAG).Data.(*Bsv21)
+	assert.Equal(t, id, bsv21.Id)
+	assert.Equal(t, Valid, bsv21.Status)
+	assert.Equal(t, "TST", *bsv21.Symbol)
+	assert.Equal(t, uint8(2), bsv21.Decimals)
+	assert.Equal(t, h.Txid("bsv21-deploy")+"_1", bsv21.Icon)
+	h.Golden("bsv21-deploy", idxCtx)
+
+	idxCtx = h.Parse("bsv21-transfer")
+	for vout := uint32(0); vout < 2; vout++ {
+		bsv21 = h.Data(idxCtx, vout, BSV21_TAG).Data.(*Bsv21)
+		assert.Equal(t, Valid, bsv21.Status)
+		assert.Equal(t, "TST", *bsv21.Symbol)
+		assert.Contains(t, idxtest.Events(idxCtx, vout, BSV21_TAG), "evt:bsv21:val:"+id)
+	}
+	h.Golden("bsv21-transfer", idxCtx)
+
+	idxCtx = h.Parse("bsv21-overspend")
+	bsv21 = h.Data(idxCtx, 0, BSV21_TAG).Data.(*Bsv21)
+	assert.Equal(t, Invalid, bsv21.Status)
+	assert.Equal(t, "insufficient funds", *bsv21.Reason)
+	assert.Contains(t, idxtest.Events(idxCtx, 0, BSV21_TAG), "evt:bsv21:inv:"+id)
+	h.Golden("bsv21-overspend", idxCtx)
+
+	idxCtx = h.Parse("bsv21-missing-inputs")
+	bsv21 = h.Data(idxCtx, 0, BSV21_TAG).Data.(*Bsv21)
+	assert.Equal(t, Invalid, bsv21.Status)
+	assert.Equal(t, "missing inputs", *bsv21.Reason)
+	h.Golden("bsv21-missing-inputs", idxCtx)
+}
+
+// bsv21-mainnet is a mainnet BEEF of a transfer of ae59f3...8127_0 along with
+// its parent, mined in block 905506, but not the parent's own inputs
+func TestBsv21Mainnet(t *testing.T) {
+	h := harness(t)
+	id := "ae59f3b898ec61acbdb6cc7a245fabeded0c094bf046f35206a3aec60ef88127_0"
+	parent, _ := lib.NewOutpointFromString("9d9eed2d6c0526193a909c2f4543a84f683523bcde9bcd0d37028414a9eb1224_0")
+
+	idxCtx := h.Parse("bsv21-mainnet")
+	assert.Equal(t, "437407e10a85c7b707a347a140e82a2c35e36d082253d21855d0d0825bbe5d8c", idxCtx.TxidHex)
+	for vout, amt := range []uint64{1000000, 100, 1870713} {
+		bsv21 := h.Data(idxCtx, uint32(vout), BSV21_TAG).Data.(*Bsv21)
+		assert.Equal(t, id, bsv21.Id)
+		assert.Equal(t, "transfer", bsv21.Op)
+		assert.Equal(t, amt, bsv21.Amt)
+		assert.Equal(t, Pending, bsv21.Status)
+		assert.Contains(t, idxtest.Events(idxCtx, uint32(vout), BSV21_TAG), "evt:bsv21:pen:"+id)
+		assert.Equal(t, []*lib.Outpoint{parent}, h.Data(idxCtx, uint32(vout), BSV21_TAG).Deps)
+	}
+	assert.Equal(t, Pending, idxCtx.Spends[0].Data[BSV21_TAG].Data.(*Bsv21).Status)
+	h.Golden("bsv21-mainnet", idxCtx)
+}
+
+func TestBsv21Holders(t *testing.T) {
+	h := harness(t)
+	ctx := context.Background()
+	id := h.Outpoint("bsv21-deploy", 0)
+
+	h.Ingest("bsv21-deploy")
+	token, err := LoadBsv21Token(ctx, h.Store, id)
+	assert.NoError(t, err)
+	assert.Equal(t, "TST", *token.Symbol)
+	assert.Equal(t, uint64(1000000), token.Max)
+	assert.Equal(t, uint64(1000000), token.Supply)
+
+	idxCtx := h.Ingest("bsv21-transfer")
+	recipient := idxCtx.Txos[0].Owners[0]
+	holders, err := LoadBsv21Holders(ctx, h.Store, id, token.Decimals, nil, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []*Bsv21Holder{
+		{Address: recipient, Amt: 600000, Balance: "6000.00"},
+		{Address: owner, Amt: 400000, Balance: "4000.00"},
+	}, holders)
+	from := float64(600000)
+	holders, err = LoadBsv21Holders(ctx, h.Store, id, token.Decimals, &from, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []*Bsv21Holder{{Address: owner, Amt: 400000, Balance: "4000.00"}}, holders)
+
+	// Reingesting a transfer does not count it twice
+	h.Ingest("bsv21-transfer")
+	token, err = LoadBsv21Token(ctx, h.Store, id)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1000000), token.Supply)
+	holders, err = LoadBsv21Holders(ctx, h.Store, id, token.Decimals, nil, 0)
+	assert.NoError(t, err)
+	assert.Len(t, holders, 2)
+
+	utxos, err := LoadBsv21Utxos(ctx, h.Store, id, owner)
+	assert.NoError(t, err)
+	assert.Len(t, utxos, 1)
+	assert.Equal(t, h.Outpoint("bsv21-transfer", 1), utxos[0].Outpoint.String())
+
+	// Invalid outputs are not counted
+	h.Ingest("bsv21-missing-inputs")
+	token, err = LoadBsv21Token(ctx, h.Store, id)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1000000), token.Supply)
+
+	token, err = LoadBsv21Token(ctx, h.Store, h.Outpoint("bsv20-deploy", 0))
+	assert.NoError(t, err)
+	assert.Nil(t, token)
+
+	// Rolling back the transfer returns the balance to the deployer
+	assert.NoError(t, h.Store.Rollback(ctx, h.Txid("bsv21-transfer")))
+	holders, err = LoadBsv21Holders(ctx, h.Store, id, 2, nil, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []*Bsv21Holder{{Address: owner, Amt: 1000000, Balance: "10000.00"}}, holders)
+}
+
+func TestBsv21Resolver(t *testing.T) {
+	h := harness(t)
+	ctx := context.Background()
+	id := h.Outpoint("bsv21-deploy", 0)
+	parent := h.Outpoint("bsv21-transfer", 0)
+	pendingKey := evt.EventKey(BSV21_TAG, &evt.Event{Id: PendingEvent, Value: id})
+	h.Ctx.Key = idx.QueueKey("test")
+
+	// The parent transfer is only parsed as an ancestor, so it can't be validated
+	idxCtx := h.Ingest("bsv21-transfer2")
+	assert.Equal(t, Pending, h.Data(idxCtx, 0, BSV21_TAG).Data.(*Bsv21).Status)
+	assert.Equal(t, []*lib.Outpoint{idxCtx.Spends[0].Outpoint}, h.Data(idxCtx, 0, BSV21_TAG).Deps)
+	count, err := h.Store.CountMembers(ctx, idx.DependentsKey(BSV21_TAG, parent))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), count)
+
+	h.Ingest("bsv21-deploy")
+	h.Ingest("bsv21-transfer")
+	queued, err := h.Store.SearchMembers(ctx, &idx.SearchCfg{Keys: []string{Bsv21ResolveKey}})
+	assert.NoError(t, err)
+	assert.Contains(t, queued, parent)
+	assert.Contains(t, queued, h.Outpoint("bsv21-transfer2", 0))
+
+	resolver := &Bsv21Resolver{Ingest: h.Ctx, Once: true}
+	assert.NoError(t, resolver.Exec(ctx))
+
+	txo, err := h.Store.LoadTxo(ctx, h.Outpoint("bsv21-transfer2", 0), []string{BSV21_TAG}, false, false)
+	assert.NoError(t, err)
+	bsv21, err := Bsv21FromBytes(txo.Data[BSV21_TAG].Data.(json.RawMessage))
+	assert.NoError(t, err)
+	assert.Equal(t, Valid, bsv21.Status)
+
+	count, err = h.Store.CountMembers(ctx, pendingKey)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), count)
+	count, err = h.Store.CountMembers(ctx, Bsv21ResolveKey)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), count)
+	count, err = h.Store.CountMembers(ctx, h.Ctx.Key)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), count)
+
+	holders, err := LoadBsv21Holders(ctx, h.Store, id, 2, nil, 0)
+	assert.NoError(t, err)
+	assert.Len(t, holders, 2)
+}
+
+func TestBsv21Unresolvable(t *testing.T) {
+	h := harness(t)
+	ctx := context.Background()
+	id := h.Outpoint("bsv21-deploy", 0)
+	h.Ctx.Key = idx.QueueKey("test")
+	ancestors := h.Ctx.AncestorConfig
+
+	// Inputs which can be neither loaded nor parsed may still carry tokens, so the
+	// transfer waits on them rather than being rejected
+	h.Ctx.AncestorConfig = idx.AncestorConfig{}
+	idxCtx := h.Ingest("bsv21-transfer")
+	bsv21 := h.Data(idxCtx, 0, BSV21_TAG).Data.(*Bsv21)
+	assert.Equal(t, Pending, bsv21.Status)
+	assert.Contains(t, idxtest.Events(idxCtx, 0, BSV21_TAG), "evt:bsv21:pen:"+id)
+	assert.Contains(t, h.Data(idxCtx, 0, BSV21_TAG).Deps, lib.NewOutpointFromHash(h.Tx("bsv21-deploy").TxID(), 0))
+	dependents, err := h.Store.SearchMembers(ctx, &idx.SearchCfg{Keys: []string{idx.DependentsKey(BSV21_TAG, id)}})
+	assert.NoError(t, err)
+	assert.Contains(t, dependents, h.Outpoint("bsv21-transfer", 0))
+
+	// Once the deploy is indexed the resolver validates the transfer
+	h.Ctx.AncestorConfig = ancestors
+	h.Ingest("bsv21-deploy")
+	resolver := &Bsv21Resolver{Ingest: h.Ctx, Once: true}
+	assert.NoError(t, resolver.Exec(ctx))
+	txo, err := h.Store.LoadTxo(ctx, h.Outpoint("bsv21-transfer", 0), []string{BSV21_TAG}, false, false)
+	assert.NoError(t, err)
+	bsv21, err = bsv21Data(txo)
+	assert.NoError(t, err)
+	assert.Equal(t, Valid, bsv21.Status)
+	count, err := h.Store.CountMembers(ctx, h.Ctx.Key)
+	assert.NoError(t, err)
+	assert.Zero(t, count)
+}
+
+func TestBsv21Validator(t *testing.T) {
+	h := harness(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	id := h.Outpoint("bsv21-deploy", 0)
+	validator := &Bsv21Validator{Ingest: h.Ctx}
+
+	h.Ingest("bsv21-deploy")
+	assert.NoError(t, ApplyFunds(ctx, h.Store, h.Ctx.Indexers, time.Hour))
+	fundAddress := h.Data(h.Parse("bsv21-deploy"), 0, BSV21_TAG).Data.(*Bsv21).FundAddress
+	status, err := LoadBsv21FundStatus(ctx, h.Store, id)
+	assert.NoError(t, err)
+	assert.Equal(t, &Bsv21FundStatus{Id: id, FundAddress: fundAddress}, status)
+
+	// Validation pauses while the fund can't cover the fee
+	assert.NoError(t, h.Store.Log(ctx, idx.QueueKey(id), h.Txid("bsv21-transfer"), 1))
+	status, err = validator.Validate(ctx, id)
+	assert.NoError(t, err)
+	assert.True(t, status.Paused)
+	assert.Equal(t, uint64(1), status.Queued)
+	txo, err := h.Store.LoadTxo(ctx, h.Outpoint("bsv21-transfer", 0), nil, false, false)
+	assert.NoError(t, err)
+	assert.Nil(t, txo)
+
+	address, err := script.NewAddressFromString(fundAddress)
+	assert.NoError(t, err)
+	lockingScript, err := p2pkh.Lock(address)
+	assert.NoError(t, err)
+	fund := func(name string, source *chainhash.Hash, vout uint32, sats uint64) *transaction.Transaction {
+		tx := transaction.NewTransaction()
+		tx.AddInput(&transaction.TransactionInput{
+			SourceTXID:       source,
+			SourceTxOutIndex: vout,
+			UnlockingScript:  &script.Script{},
+			SequenceNumber:   0xffffffff,
+		})
+		tx.AddOutput(&transaction.TransactionOutput{
+			Satoshis:      sats,
+			LockingScript: lockingScript,
+		})
+		h.AddTx(name, tx)
+		h.Ingest(name)
+		return tx
+	}
+
+	// A transaction is only committed once the fund covers every output it settles,
+	// and one which fails to ingest is retried without holding up the queue
+	first := fund("bsv21-fund", h.Tx("fund").TxID(), 0, 1500)
+	missing := strings.Repeat("0", 64)
+	assert.NoError(t, h.Store.Log(ctx, idx.QueueKey(id), missing, 0))
+	status, err = validator.Validate(ctx, id)
+	assert.NoError(t, err)
+	assert.True(t, status.Paused)
+	assert.Equal(t, uint64(2), status.Queued)
+	assert.Equal(t, uint64(1500), status.Funded)
+	assert.Zero(t, status.Validated)
+	assert.Equal(t, int64(1500), status.Balance)
+	attempts, err := h.Store.LogScore(ctx, idx.AttemptsKey(h.Ctx.Tag), missing)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(1), attempts)
+	assert.NoError(t, h.Store.Delog(ctx, idx.QueueKey(id), missing))
+
+	// Change paid back to the fund address is not counted as funding
+	fund("bsv21-consolidate", first.TxID(), 0, 1400)
+	status, err = LoadBsv21FundStatus(ctx, h.Store, id)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1500), status.Funded)
+
+	// Topping up the fund resumes validation, charging each validated output
+	fund("bsv21-topup", h.Tx("fund").TxID(), 1, 1000)
+	status, err = validator.Validate(ctx, id)
+	assert.NoError(t, err)
+	assert.Zero(t, status.Queued)
+	assert.Equal(t, uint64(2500), status.Funded)
+	assert.Equal(t, uint64(2), status.Validated)
+	assert.Equal(t, uint64(2*BSV21_INDEX_FEE), status.Used)
+	assert.Equal(t, int64(500), status.Balance)
+
+	assert.NoError(t, h.Store.Log(ctx, idx.QueueKey(id), h.Txid("bsv21-overspend"), 2))
+	status, err = validator.Validate(ctx, id)
+	assert.NoError(t, err)
+	assert.True(t, status.Paused)
+
+	// Rolling back a funding transaction takes its satoshis off the total
+	assert.NoError(t, h.Store.Rollback(ctx, h.Txid("bsv21-topup")))
+	status, err = LoadBsv21FundStatus(ctx, h.Store, id)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1500), status.Funded)
+}
+
+func TestBsv21FundBackfill(t *testing.T) {
+	h := harness(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	id := h.Outpoint("bsv21-deploy", 0)
+
+	// Funding indexed before totals were kept is counted when the token is first loaded
+	h.Ingest("bsv21-deploy")
+	address, err := script.NewAddressFromString(h.Data(h.Parse("bsv21-deploy"), 0, BSV21_TAG).Data.(*Bsv21).FundAddress)
+	assert.NoError(t, err)
+	lockingScript, err := p2pkh.Lock(address)
+	assert.NoError(t, err)
+	fund := transaction.NewTransaction()
+	fund.AddInput(&transaction.TransactionInput{
+		SourceTXID:       h.Tx("fund").TxID(),
+		SourceTxOutIndex: 0,
+		UnlockingScript:  &script.Script{},
+		SequenceNumber:   0xffffffff,
+	})
+	fund.AddOutput(&transaction.TransactionOutput{
+		Satoshis:      1500,
+		LockingScript: lockingScript,
+	})
+	h.AddTx("bsv21-fund", fund)
+	h.Ingest("bsv21-fund")
+	status, err := LoadBsv21FundStatus(ctx, h.Store, id)
+	assert.NoError(t, err)
+	assert.Zero(t, status.Funded)
+
+	assert.NoError(t, ApplyFunds(ctx, h.Store, h.Ctx.Indexers, time.Hour))
+	status, err = LoadBsv21FundStatus(ctx, h.Store, id)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1500), status.Funded)
+}
+
+func TestFormatAmount(t *testing.T) {
+	assert.Equal(t, "1000", FormatAmount(1000, 0))
+	assert.Equal(t, "10.00", FormatAmount(1000, 2))
+	assert.Equal(t, "0.001", FormatAmount(1, 3))
+	assert.Equal(t, "0.0", FormatAmount(0, 1))
+}
+
+func TestOrdLock(t *testing.T) {
+	h := harness(t)
+
+	idxCtx := h.Parse("ordlock-list")
+	ordLock := h.Data(idxCtx, 0, ORDLOCK_TAG).Data.(*OrdLock)
+	assert.Equal(t, uint64(5000), ordLock.Price)
+	assert.Equal(t, OrdLockPending, ordLock.State)
+	assert.Contains(t, idxtest.Events(idxCtx, 0, ORDLOCK_TAG), "evt:ordlock:list:"+owner)
+	assert.Contains(t, idxCtx.Txos[0].Owners, owner)
+	h.Golden("ordlock-list", idxCtx)
+
+	idxCtx = h.Parse("ordlock-sale")
+	ordLock = h.Data(idxCtx, 0, ORDLOCK_TAG).Data.(*OrdLock)
+	assert.Equal(t, OrdLockSale, ordLock.State)
+	assert.Contains(t, idxtest.Events(idxCtx, 0, ORDLOCK_TAG), "evt:ordlock:sale:"+owner)
+	h.Golden("ordlock-sale", idxCtx)
+
+	idxCtx = h.Parse("ordlock-cancel")
+	ordLock = h.Data(idxCtx, 0, ORDLOCK_TAG).Data.(*OrdLock)
+	assert.Equal(t, OrdLockCancel, ordLock.State)
+	assert.Contains(t, idxtest.Events(idxCtx, 0, ORDLOCK_TAG), "evt:ordlock:cancel:"+owner)
+	h.Golden("ordlock-cancel", idxCtx)
+}
+
+func TestMarket(t *testing.T) {
+	h := harness(t)
+	ctx := context.Background()
+	listing := h.Outpoint("ordlock-list", 0)
+	h.Ingest("ordlock-list")
+
+	for _, sort := range []string{SortRecent, SortPrice, SortPricePer} {
+		txos, err := SearchListings(ctx, h.Store, &ListingsCfg{Tag: owner, Sort: sort, Limit: 10})
+		assert.NoError(t, err)
+		assert.Len(t, txos, 1, sort)
+		assert.Equal(t, listing, txos[0].Outpoint.String())
+		assert.Contains(t, txos[0].Data, ORDLOCK_TAG)
+	}
+
+	// Price bounds are inclusive
+	price := float64(5000)
+	txos, err := SearchListings(ctx, h.Store, &ListingsCfg{Sort: SortPrice, Min: &price, Max: &price, Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, txos, 1)
+	above := price + 1
+	txos, err = SearchListings(ctx, h.Store, &ListingsCfg{Sort: SortPrice, Min: &above, Limit: 10})
+	assert.NoError(t, err)
+	assert.Empty(t, txos)
+	txos, err = SearchListings(ctx, h.Store, &ListingsCfg{Sort: SortPrice, Max: &above, Reverse: true, Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, txos, 1)
+
+	// A sale closes the listing and is recorded in the sales history
+	h.Ingest("ordlock-sale")
+	count, err := h.Store.CountMembers(ctx, MarketPriceKey(owner))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), count)
+	txos, err = SearchListings(ctx, h.Store, &ListingsCfg{Tag: owner, Sort: SortRecent, Limit: 10})
+	assert.NoError(t, err)
+	assert.Empty(t, txos)
+	sales, err := SearchSales(ctx, h.Store, owner, nil, true, 10)
+	assert.NoError(t, err)
+	assert.Len(t, sales, 1)
+	assert.Equal(t, h.Outpoint("ordlock-sale", 0), sales[0].Outpoint.String())
+
+	// Rolling back the sale reopens the listing
+	assert.NoError(t, h.Store.Rollback(ctx, h.Txid("ordlock-sale")))
+	count, err = h.Store.CountMembers(ctx, MarketPriceKey(owner))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), count)
+}
+
+func TestMarketFreeListing(t *testing.T) {
+	h := harness(t)
+	ctx := context.Background()
+
+	// A listing asking nothing keeps a price of 0 in the price indexes
+	tx, err := transaction.NewTransactionFromBytes(h.Tx("ordlock-list").Bytes())
+	assert.NoError(t, err)
+	scr := *tx.Outputs[0].LockingScript
+	start := bytes.Index(scr, OrdLockPrefix) + len(OrdLockPrefix)
+	ops, err := script.DecodeScript(scr[start:bytes.Index(scr, OrdLockSuffix)])
+	assert.NoError(t, err)
+	payOut := bytes.Index(scr[start:], ops[1].Data) + start
+	copy(scr[payOut:payOut+8], make([]byte, 8))
+	h.AddTx("ordlock-free", tx)
+	idxCtx := h.Ingest("ordlock-free")
+	assert.Equal(t, uint64(0), h.Data(idxCtx, 0, ORDLOCK_TAG).Data.(*OrdLock).Price)
+
+	zero := float64(0)
+	for _, sort := range []string{SortPrice, SortPricePer} {
+		txos, err := SearchListings(ctx, h.Store, &ListingsCfg{Tag: owner, Sort: sort, Min: &zero, Max: &zero, Limit: 10})
+		assert.NoError(t, err)
+		assert.Len(t, txos, 1, sort)
+	}
+	count, err := h.Store.CountMembers(ctx, MarketPricePerKey(owner))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), count)
+}
+
+func TestMarketTokens(t *testing.T) {
+	h := harness(t)
+	ctx := context.Background()
+	id := h.Outpoint("bsv21-deploy", 0)
+	listing := h.Outpoint("bsv21-list", 0)
+
+	// Parsing alone lists nothing
+	h.Parse("bsv21-list")
+	count, err := h.Store.CountMembers(ctx, MarketPriceKey(id))
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), count)
+
+	// Listings of tokens which aren't validated are left out of the market
+	idxCtx := h.Ingest("bsv21-list")
+	assert.Equal(t, Pending, h.Data(idxCtx, 0, BSV21_TAG).Data.(*Bsv21).Status)
+	for _, sort := range []string{SortRecent, SortPrice, SortPricePer} {
+		txos, err := SearchListings(ctx, h.Store, &ListingsCfg{Tag: id, Sort: sort, Limit: 10})
+		assert.NoError(t, err)
+		assert.Empty(t, txos, sort)
+	}
+
+	h.Ingest("bsv21-deploy")
+	h.Ingest("bsv21-transfer")
+	idxCtx = h.Ingest("bsv21-list")
+	assert.Equal(t, Valid, h.Data(idxCtx, 0, BSV21_TAG).Data.(*Bsv21).Status)
+	for _, sort := range []string{SortRecent, SortPrice, SortPricePer} {
+		txos, err := SearchListings(ctx, h.Store, &ListingsCfg{Tag: id, Sort: sort, Limit: 10})
+		assert.NoError(t, err)
+		assert.Len(t, txos, 1, sort)
+		assert.Equal(t, listing, txos[0].Outpoint.String())
+	}
+
+	// Reingesting the listing doesn't list it twice
+	h.Ingest("bsv21-list")
+	price, err := h.Store.LogScore(ctx, MarketPriceKey(id), listing)
+	assert.NoError(t, err)
+	assert.Equal(t, float64(5000), price)
+}
@@ -3,6 +3,7 @@ package onesat
 import (
 	"bytes"
 	"encoding/hex"
+	"encoding/json"
 	"math"
 
 	"github.com/bsv-blockchain/go-sdk/script"
@@ -38,6 +39,14 @@ func (i *OrdLockIndexer) Tag() string {
 	return ORDLOCK_TAG
 }
 
+func (i *OrdLockIndexer) FromBytes(data []byte) (any, error) {
+	obj := &OrdLock{}
+	if err := json.Unmarshal(data, obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
 func (i *OrdLockIndexer) Parse(idxCtx *idx.IndexContext, vout uint32) *idx.IndexData {
 	txo := idxCtx.Txos[vout]
 	if *txo.Satoshis != 1 {
@@ -99,13 +108,27 @@ func (i *OrdLockIndexer) Parse(idxCtx *idx.IndexContext, vout uint32) *idx.Index
 	}
 }
 
+// PreSave lists open OrdLocks and closes those spent. It runs after the token indexers,
+// so listings of tokens which are pending or invalid are left out of the market.
 func (i *OrdLockIndexer) PreSave(idxCtx *idx.IndexContext) {
+	for _, txo := range idxCtx.Txos {
+		if listData, ok := txo.Data[ORDLOCK_TAG]; ok {
+			if ordLock, ok := listData.Data.(*OrdLock); ok && ordLock.State == OrdLockPending {
+				if listable(txo) {
+					logListing(idxCtx, txo.Outpoint.String(), ordLock)
+				} else {
+					listData.Events = nil
+				}
+			}
+		}
+	}
 	if len(idxCtx.Spends) == 0 {
 		return
 	}
 	spend := idxCtx.Spends[0]
 	if spendData, ok := spend.Data[ORDLOCK_TAG]; ok {
 		if ordLock, ok := spendData.Data.(*OrdLock); ok {
+			delogListing(idxCtx, spend.Outpoint.String(), ordLock)
 			txo := idxCtx.Txos[0]
 			idxData := &idx.IndexData{
 				Data: ordLock,
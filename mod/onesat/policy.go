@@ -0,0 +1,332 @@
+package onesat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shruggr/1sat-indexer/v5/idx"
+)
+
+// PolicyAllowListKey holds the tags in allow list mode, where only allowed tokens are indexed
+const PolicyAllowListKey = "pol:allowlist"
+
+// PolicyAllowKey holds the tokens of a tag which are indexed in allow list mode
+func PolicyAllowKey(tag string) string {
+	return "pol:allow:" + tag
+}
+
+// PolicyDenyKey holds the tokens of a tag which are never indexed
+func PolicyDenyKey(tag string) string {
+	return "pol:deny:" + tag
+}
+
+// PolicyBlockedKey logs the transactions skipped because a token was not allowed,
+// so they can be requeued when it is
+func PolicyBlockedKey(tag string, token string) string {
+	return "pol:blocked:" + tag + ":" + token
+}
+
+// PolicyBlockedTokensKey counts the skipped transactions of each token of a tag, so
+// leaving allow list mode can requeue them
+func PolicyBlockedTokensKey(tag string) string {
+	return "pol:blocked:" + tag
+}
+
+// policyToken normalizes a token for a tag. BSV-20 tickers are case insensitive.
+func policyToken(tag string, token string) string {
+	if tag == BSV20_TAG {
+		return strings.ToUpper(token)
+	}
+	return token
+}
+
+type PolicyLists struct {
+	// AllowList puts the tag in allow list mode, so only tokens in Allow are indexed
+	AllowList bool     `json:"allowList"`
+	Allow     []string `json:"allow"`
+	Deny      []string `json:"deny"`
+}
+
+// TokenPolicy decides which tokens an indexer parses. Lists are merged from an optional
+// JSON file and the store, and are reloaded by Watch without a restart.
+type TokenPolicy struct {
+	Tag       string
+	File      string
+	Store     idx.TxoStore
+	mu        sync.RWMutex
+	allowList bool
+	allow     map[string]struct{}
+	deny      map[string]struct{}
+}
+
+func NewTokenPolicy(tag string, file string, store idx.TxoStore) *TokenPolicy {
+	return &TokenPolicy{
+		Tag:   tag,
+		File:  file,
+		Store: store,
+		allow: map[string]struct{}{},
+		deny:  map[string]struct{}{},
+	}
+}
+
+// Load replaces the lists with the current contents of the file and store
+func (p *TokenPolicy) Load(ctx context.Context) error {
+	allowList := false
+	allow := map[string]struct{}{}
+	deny := map[string]struct{}{}
+	if p.File != "" {
+		lists := &PolicyLists{}
+		if b, err := os.ReadFile(p.File); err != nil {
+			return err
+		} else if err := json.Unmarshal(b, lists); err != nil {
+			return err
+		}
+		allowList = lists.AllowList
+		for _, token := range lists.Allow {
+			allow[policyToken(p.Tag, token)] = struct{}{}
+		}
+		for _, token := range lists.Deny {
+			deny[policyToken(p.Tag, token)] = struct{}{}
+		}
+	}
+	if p.Store != nil {
+		if lists, err := LoadPolicyLists(ctx, p.Store, p.Tag); err != nil {
+			return err
+		} else {
+			allowList = allowList || lists.AllowList
+			for _, token := range lists.Allow {
+				allow[token] = struct{}{}
+			}
+			for _, token := range lists.Deny {
+				deny[token] = struct{}{}
+			}
+		}
+	}
+	p.mu.Lock()
+	p.allowList = allowList
+	p.allow = allow
+	p.deny = deny
+	p.mu.Unlock()
+	return nil
+}
+
+// Watch reloads the lists every interval until ctx is cancelled
+func (p *TokenPolicy) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.Load(ctx); err != nil {
+				log.Println("policy reload", p.Tag, err)
+			}
+		}
+	}
+}
+
+// Whitelisted reports whether token is allowed. Every token is allowed unless the tag is in allow list mode.
+func (p *TokenPolicy) Whitelisted(token string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if !p.allowList {
+		return true
+	}
+	_, ok := p.allow[token]
+	return ok
+}
+
+// Blacklisted reports whether token is denied
+func (p *TokenPolicy) Blacklisted(token string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	_, ok := p.deny[token]
+	return ok
+}
+
+// Funcs returns the policy as the WhitelistFn and BlacklistFn of an indexer
+func (p *TokenPolicy) Funcs() (whitelistFn *func(string) bool, blacklistFn *func(string) bool) {
+	whitelist := p.Whitelisted
+	blacklist := p.Blacklisted
+	return &whitelist, &blacklist
+}
+
+// ApplyPolicies attaches a TokenPolicy to each BSV-20 and BSV-21 indexer in indexers,
+// reading the lists from store and the files named by BSV20_POLICY and BSV21_POLICY.
+// The lists are reloaded every interval until ctx is cancelled.
+func ApplyPolicies(ctx context.Context, store idx.TxoStore, indexers []idx.Indexer, interval time.Duration) error {
+	for _, indexer := range indexers {
+		var policy *TokenPolicy
+		switch i := indexer.(type) {
+		case *Bsv20Indexer:
+			policy = NewTokenPolicy(BSV20_TAG, os.Getenv("BSV20_POLICY"), store)
+			i.WhitelistFn, i.BlacklistFn = policy.Funcs()
+		case *Bsv21Indexer:
+			policy = NewTokenPolicy(BSV21_TAG, os.Getenv("BSV21_POLICY"), store)
+			i.WhitelistFn, i.BlacklistFn = policy.Funcs()
+		default:
+			continue
+		}
+		if err := policy.Load(ctx); err != nil {
+			return err
+		}
+		go policy.Watch(ctx, interval)
+	}
+	return nil
+}
+
+// allowed applies the policy functions of an indexer. Transactions with tokens which
+// aren't allowed are logged so AllowToken can requeue them. The log is only written when
+// the transaction is committed, and is dropped when the transaction is rolled back or
+// reingested once the token is allowed.
+func allowed(idxCtx *idx.IndexContext, tag string, token string, whitelistFn *func(string) bool, blacklistFn *func(string) bool) bool {
+	if (whitelistFn == nil || (*whitelistFn)(token)) && (blacklistFn == nil || !(*blacklistFn)(token)) {
+		return true
+	}
+	blockedKey := PolicyBlockedKey(tag, token)
+	for _, w := range idxCtx.Writes {
+		if w.Key == blockedKey {
+			return false
+		}
+	}
+	idxCtx.Log(blockedKey, idxCtx.TxidHex, idxCtx.Score)
+	idxCtx.Incr(PolicyBlockedTokensKey(tag), token, 1)
+	return false
+}
+
+// LoadPolicyLists returns the lists of a tag held in the store
+func LoadPolicyLists(ctx context.Context, store idx.TxoStore, tag string) (lists *PolicyLists, err error) {
+	lists = &PolicyLists{}
+	if mode, err := store.LogScore(ctx, PolicyAllowListKey, tag); err != nil {
+		return nil, err
+	} else {
+		lists.AllowList = mode > 0
+	}
+	if lists.Allow, err = store.SearchMembers(ctx, &idx.SearchCfg{
+		Keys: []string{PolicyAllowKey(tag)},
+	}); err != nil {
+		return nil, err
+	} else if lists.Deny, err = store.SearchMembers(ctx, &idx.SearchCfg{
+		Keys: []string{PolicyDenyKey(tag)},
+	}); err != nil {
+		return nil, err
+	}
+	return lists, nil
+}
+
+// AllowToken adds token to the allow list and removes it from the deny list. Transactions
+// previously skipped for the token are requeued onto queueKey in their original order.
+// The allow list only restricts indexing once the tag is put in allow list mode with
+// SetAllowListMode.
+func AllowToken(ctx context.Context, store idx.TxoStore, tag string, token string, queueKey string) (requeued int, err error) {
+	token = policyToken(tag, token)
+	if err = store.Log(ctx, PolicyAllowKey(tag), token, float64(time.Now().UnixNano())); err != nil {
+		return
+	} else if err = store.Delog(ctx, PolicyDenyKey(tag), token); err != nil {
+		return
+	}
+	return RequeueBlocked(ctx, store, tag, token, queueKey)
+}
+
+// UndenyToken removes token from the deny list, leaving the allow list untouched, and
+// requeues the transactions skipped for it
+func UndenyToken(ctx context.Context, store idx.TxoStore, tag string, token string, queueKey string) (int, error) {
+	token = policyToken(tag, token)
+	if err := store.Delog(ctx, PolicyDenyKey(tag), token); err != nil {
+		return 0, err
+	}
+	return RequeueBlocked(ctx, store, tag, token, queueKey)
+}
+
+// SetAllowListMode switches allow list mode for a tag. Leaving it requeues onto queueKey
+// the transactions skipped for every token which isn't denied.
+func SetAllowListMode(ctx context.Context, store idx.TxoStore, tag string, enabled bool, queueKey string) (requeued int, err error) {
+	if enabled {
+		return 0, store.Log(ctx, PolicyAllowListKey, tag, 1)
+	} else if queueKey == "" {
+		return 0, ErrNoRequeueKey
+	} else if err = store.Delog(ctx, PolicyAllowListKey, tag); err != nil {
+		return
+	}
+	lists, err := LoadPolicyLists(ctx, store, tag)
+	if err != nil {
+		return
+	}
+	tokens, err := store.SearchMembers(ctx, &idx.SearchCfg{
+		Keys: []string{PolicyBlockedTokensKey(tag)},
+	})
+	if err != nil {
+		return
+	}
+	for _, token := range tokens {
+		if slices.Contains(lists.Deny, token) {
+			continue
+		} else if n, err := RequeueBlocked(ctx, store, tag, token, queueKey); err != nil {
+			return requeued, err
+		} else {
+			requeued += n
+		}
+	}
+	return
+}
+
+// DenyToken adds token to the deny list and removes it from the allow list
+func DenyToken(ctx context.Context, store idx.TxoStore, tag string, token string) error {
+	token = policyToken(tag, token)
+	if err := store.Log(ctx, PolicyDenyKey(tag), token, float64(time.Now().UnixNano())); err != nil {
+		return err
+	}
+	return store.Delog(ctx, PolicyAllowKey(tag), token)
+}
+
+// ClearToken removes token from both lists. Skipped transactions are requeued since
+// the token may now pass the policy.
+func ClearToken(ctx context.Context, store idx.TxoStore, tag string, token string, queueKey string) (int, error) {
+	token = policyToken(tag, token)
+	if err := store.Delog(ctx, PolicyAllowKey(tag), token); err != nil {
+		return 0, err
+	} else if err := store.Delog(ctx, PolicyDenyKey(tag), token); err != nil {
+		return 0, err
+	}
+	return RequeueBlocked(ctx, store, tag, token, queueKey)
+}
+
+// ErrNoRequeueKey is returned when skipped transactions would be requeued without a queue
+var ErrNoRequeueKey = errors.New("policy requeue requires a queue key")
+
+// RequeueBlocked moves the transactions skipped for token onto queueKey, which must be
+// a queue consumed by an ingest, such as idx.IngestQueueKey
+func RequeueBlocked(ctx context.Context, store idx.TxoStore, tag string, token string, queueKey string) (int, error) {
+	if queueKey == "" {
+		return 0, ErrNoRequeueKey
+	}
+	blockedKey := PolicyBlockedKey(tag, policyToken(tag, token))
+	if logs, err := store.Search(ctx, &idx.SearchCfg{
+		Keys: []string{blockedKey},
+	}); err != nil {
+		return 0, err
+	} else if len(logs) == 0 {
+		return 0, nil
+	} else {
+		queued := make([]idx.Log, 0, len(logs))
+		txids := make([]string, 0, len(logs))
+		for _, l := range logs {
+			queued = append(queued, *l)
+			txids = append(txids, l.Member)
+		}
+		if err := store.LogMany(ctx, queueKey, queued); err != nil {
+			return 0, err
+		} else if err := store.Delog(ctx, blockedKey, txids...); err != nil {
+			return 0, err
+		}
+		return len(logs), nil
+	}
+}
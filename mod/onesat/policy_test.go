@@ -0,0 +1,171 @@
+package onesat
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/shruggr/1sat-indexer/v5/idx"
+	"github.com/shruggr/1sat-indexer/v5/idxtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func policyHarness(t *testing.T, policy *TokenPolicy) *idxtest.Harness {
+	bsv20 := &Bsv20Indexer{}
+	bsv20.WhitelistFn, bsv20.BlacklistFn = policy.Funcs()
+	h := idxtest.New(t, &InscriptionIndexer{}, bsv20)
+	policy.Store = h.Store
+	return h
+}
+
+func TestPolicyFile(t *testing.T) {
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "policy.json")
+	require.NoError(t, os.WriteFile(file, []byte(`{"deny":["test"]}`), 0644))
+	policy := NewTokenPolicy(BSV20_TAG, file, nil)
+	h := policyHarness(t, policy)
+	require.NoError(t, policy.Load(ctx))
+	assert.True(t, policy.Blacklisted("TEST"))
+
+	idxCtx := h.Parse("bsv20-deploy")
+	assert.NotContains(t, idxCtx.Txos[0].Data, BSV20_TAG)
+
+	// Lists are reloaded from the file. The allow list only applies in allow list mode.
+	require.NoError(t, os.WriteFile(file, []byte(`{"allow":["OTHR"]}`), 0644))
+	require.NoError(t, policy.Load(ctx))
+	assert.False(t, policy.Blacklisted("TEST"))
+	assert.True(t, policy.Whitelisted("TEST"))
+
+	require.NoError(t, os.WriteFile(file, []byte(`{"allowList":true,"allow":["OTHR"]}`), 0644))
+	require.NoError(t, policy.Load(ctx))
+	assert.False(t, policy.Whitelisted("TEST"))
+	assert.True(t, policy.Whitelisted("OTHR"))
+}
+
+func TestPolicyStore(t *testing.T) {
+	ctx := context.Background()
+	policy := NewTokenPolicy(BSV20_TAG, "", nil)
+	h := policyHarness(t, policy)
+	queueKey := idx.QueueKey("test")
+
+	require.NoError(t, DenyToken(ctx, h.Store, BSV20_TAG, "test"))
+	require.NoError(t, policy.Load(ctx))
+	idxCtx := h.Parse("bsv20-deploy")
+	assert.NotContains(t, idxCtx.Txos[0].Data, BSV20_TAG)
+
+	// Skipped transactions are only logged once they are committed
+	count, err := h.Store.CountMembers(ctx, PolicyBlockedKey(BSV20_TAG, "TEST"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), count)
+	h.Ingest("bsv20-deploy")
+
+	lists, err := LoadPolicyLists(ctx, h.Store, BSV20_TAG)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"TEST"}, lists.Deny)
+
+	// Allowing the ticker requeues the skipped deploy
+	requeued, err := AllowToken(ctx, h.Store, BSV20_TAG, "test", queueKey)
+	require.NoError(t, err)
+	assert.Equal(t, 1, requeued)
+	queued, err := h.Store.SearchMembers(ctx, &idx.SearchCfg{Keys: []string{queueKey}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{h.Txid("bsv20-deploy")}, queued)
+
+	require.NoError(t, policy.Load(ctx))
+	idxCtx = h.Parse("bsv20-deploy")
+	assert.Contains(t, idxCtx.Txos[0].Data, BSV20_TAG)
+
+	requeued, err = ClearToken(ctx, h.Store, BSV20_TAG, "TEST", queueKey)
+	require.NoError(t, err)
+	assert.Equal(t, 0, requeued)
+	lists, err = LoadPolicyLists(ctx, h.Store, BSV20_TAG)
+	require.NoError(t, err)
+	assert.Empty(t, lists.Allow)
+	assert.Empty(t, lists.Deny)
+}
+
+func TestPolicyUndeny(t *testing.T) {
+	ctx := context.Background()
+	policy := NewTokenPolicy(BSV20_TAG, "", nil)
+	h := policyHarness(t, policy)
+	queueKey := idx.QueueKey("test")
+
+	require.NoError(t, DenyToken(ctx, h.Store, BSV20_TAG, "test"))
+	require.NoError(t, policy.Load(ctx))
+	h.Ingest("bsv20-deploy")
+
+	// Lifting the denial requeues the deploy without switching to allow list mode
+	requeued, err := UndenyToken(ctx, h.Store, BSV20_TAG, "test", queueKey)
+	require.NoError(t, err)
+	assert.Equal(t, 1, requeued)
+	lists, err := LoadPolicyLists(ctx, h.Store, BSV20_TAG)
+	require.NoError(t, err)
+	assert.Empty(t, lists.Allow)
+	assert.Empty(t, lists.Deny)
+
+	require.NoError(t, policy.Load(ctx))
+	assert.True(t, policy.Whitelisted("OTHR"))
+	idxCtx := h.Ingest("bsv20-deploy")
+	assert.Contains(t, idxCtx.Txos[0].Data, BSV20_TAG)
+	count, err := h.Store.CountMembers(ctx, PolicyBlockedKey(BSV20_TAG, "TEST"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), count)
+}
+
+func TestPolicyAllowListMode(t *testing.T) {
+	ctx := context.Background()
+	policy := NewTokenPolicy(BSV20_TAG, "", nil)
+	h := policyHarness(t, policy)
+	queueKey := idx.QueueKey("test")
+
+	// Allowing a token doesn't restrict the others until the mode is switched on
+	_, err := AllowToken(ctx, h.Store, BSV20_TAG, "othr", queueKey)
+	require.NoError(t, err)
+	require.NoError(t, policy.Load(ctx))
+	assert.True(t, policy.Whitelisted("TEST"))
+
+	_, err = SetAllowListMode(ctx, h.Store, BSV20_TAG, true, queueKey)
+	require.NoError(t, err)
+	require.NoError(t, policy.Load(ctx))
+	lists, err := LoadPolicyLists(ctx, h.Store, BSV20_TAG)
+	require.NoError(t, err)
+	assert.True(t, lists.AllowList)
+	idxCtx := h.Ingest("bsv20-deploy")
+	assert.NotContains(t, idxCtx.Txos[0].Data, BSV20_TAG)
+
+	// Committing a skipped transaction again logs it once, under its latest score
+	idxCtx = h.Ingest("bsv20-deploy")
+	blocked, err := h.Store.Search(ctx, &idx.SearchCfg{Keys: []string{PolicyBlockedKey(BSV20_TAG, "TEST")}})
+	require.NoError(t, err)
+	require.Len(t, blocked, 1)
+	assert.Equal(t, idxCtx.Score, blocked[0].Score)
+
+	// Leaving the mode requeues the transactions skipped for tokens which aren't denied
+	_, err = SetAllowListMode(ctx, h.Store, BSV20_TAG, false, "")
+	assert.ErrorIs(t, err, ErrNoRequeueKey)
+	requeued, err := SetAllowListMode(ctx, h.Store, BSV20_TAG, false, queueKey)
+	require.NoError(t, err)
+	assert.Equal(t, 1, requeued)
+	queued, err := h.Store.SearchMembers(ctx, &idx.SearchCfg{Keys: []string{queueKey}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{h.Txid("bsv20-deploy")}, queued)
+	require.NoError(t, policy.Load(ctx))
+	assert.True(t, policy.Whitelisted("TEST"))
+}
+
+func TestApplyPolicies(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	file := filepath.Join(t.TempDir(), "policy.json")
+	require.NoError(t, os.WriteFile(file, []byte(`{"deny":["test"]}`), 0644))
+	t.Setenv("BSV20_POLICY", file)
+	bsv20 := &Bsv20Indexer{}
+	h := idxtest.New(t, &InscriptionIndexer{}, bsv20)
+
+	require.NoError(t, ApplyPolicies(ctx, h.Store, h.Ctx.Indexers, time.Minute))
+	idxCtx := h.Parse("bsv20-deploy")
+	assert.NotContains(t, idxCtx.Txos[0].Data, BSV20_TAG)
+}
@@ -0,0 +1,199 @@
+package onesat
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/shruggr/1sat-indexer/v5/evt"
+	"github.com/shruggr/1sat-indexer/v5/idx"
+)
+
+// ResolveEvent marks token outputs for the resolver once their transaction is committed
+const ResolveEvent = "res"
+
+// Bsv21ResolveKey logs the committed token outputs which the resolver has yet to visit
+var Bsv21ResolveKey = evt.EventKey(BSV21_TAG, &evt.Event{Id: ResolveEvent})
+
+// markResolvable logs every token output of idxCtx for the resolver. Whether an output
+// has dependents, or whether its own deps have settled, is only known after the commit,
+// so the resolver looks both up when it visits the output.
+func markResolvable(idxCtx *idx.IndexContext) {
+	for _, txo := range idxCtx.Txos {
+		if idxData, ok := txo.Data[BSV21_TAG]; ok {
+			idxData.Events = append(idxData.Events, &evt.Event{
+				Id: ResolveEvent,
+			})
+		}
+	}
+}
+
+// Bsv21Resolver visits committed token outputs in score order. Settled outputs reingest
+// the transactions pending on them, and pending outputs whose deps have since settled, or
+// were never saved, are reingested themselves. Reingested transactions which resolve are
+// logged in turn, so validity cascades forward. Reingests which fail, or which stay
+// pending on inputs missing from the store, are handed to Ingest.Retry, which pushes them
+// back onto the Ingest.Key queue with a backoff or dead-letters them.
+type Bsv21Resolver struct {
+	Ingest   *idx.IngestCtx
+	PageSize uint32
+	Once     bool
+}
+
+// Exec processes the resolve log until ctx is cancelled, or until the log is empty when Once is set
+func (r *Bsv21Resolver) Exec(ctx context.Context) error {
+	if r.Ingest.Key == "" {
+		return errors.New("bsv21 resolver requires an ingest queue key")
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+		if logs, err := r.Ingest.Store.Search(ctx, &idx.SearchCfg{
+			Keys:  []string{Bsv21ResolveKey},
+			Limit: r.PageSize,
+		}); err != nil {
+			return err
+		} else if len(logs) == 0 {
+			if r.Once {
+				return nil
+			}
+			time.Sleep(time.Second)
+		} else {
+			for _, l := range logs {
+				// Taken off the log first, as resolving may reingest the output and log it again
+				if err := r.Ingest.Store.Delog(ctx, Bsv21ResolveKey, l.Member); err != nil {
+					return err
+				} else if err := r.Resolve(ctx, l.Member); err != nil {
+					if err := r.Ingest.Store.Log(ctx, Bsv21ResolveKey, l.Member, l.Score); err != nil {
+						log.Println("bsv21 resolve", l.Member, err)
+					}
+					return err
+				}
+			}
+		}
+	}
+}
+
+// Resolve reingests the transactions which a committed token output unblocks. Only store
+// failures are returned; failed reingests are retried through the ingest queue.
+func (r *Bsv21Resolver) Resolve(ctx context.Context, outpoint string) error {
+	if txo, err := r.Ingest.Store.LoadTxo(ctx, outpoint, []string{BSV21_TAG}, false, false); err != nil {
+		return err
+	} else if txo == nil || txo.Data[BSV21_TAG] == nil {
+		return nil
+	} else if bsv21, err := bsv21Data(txo); err != nil {
+		return nil
+	} else if bsv21.Status == Pending {
+		return r.resolvePending(ctx, outpoint)
+	}
+
+	dependents, err := r.Ingest.Store.SearchMembers(ctx, &idx.SearchCfg{
+		Keys: []string{idx.DependentsKey(BSV21_TAG, outpoint)},
+	})
+	if err != nil {
+		return err
+	}
+	resolved := make(map[string]struct{}, len(dependents))
+	for _, dependent := range dependents {
+		txid := dependent[:64]
+		if _, ok := resolved[txid]; ok {
+			continue
+		} else if status, err := r.status(ctx, dependent); err != nil {
+			return err
+		} else if status == nil || *status != Pending {
+			continue
+		}
+		resolved[txid] = struct{}{}
+		if err := r.reingest(ctx, txid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolvePending reingests the transaction of a pending output once any of its deps has
+// settled or is missing from the store. Deps which are still pending resolve it in turn.
+func (r *Bsv21Resolver) resolvePending(ctx context.Context, outpoint string) error {
+	deps, err := r.Ingest.Store.SearchMembers(ctx, &idx.SearchCfg{
+		Keys: []string{idx.DepsKey(BSV21_TAG, outpoint)},
+	})
+	if err != nil {
+		return err
+	}
+	for _, dep := range deps {
+		if status, err := r.status(ctx, dep); err != nil {
+			return err
+		} else if status == nil || *status != Pending {
+			return r.reingest(ctx, outpoint[:64])
+		}
+	}
+	return nil
+}
+
+// status returns the token status of a saved output, or nil when it isn't saved with token data
+func (r *Bsv21Resolver) status(ctx context.Context, outpoint string) (*Bsv20Status, error) {
+	if txo, err := r.Ingest.Store.LoadTxo(ctx, outpoint, []string{BSV21_TAG}, false, false); err != nil {
+		return nil, err
+	} else if txo == nil || txo.Data[BSV21_TAG] == nil {
+		return nil, nil
+	} else if bsv21, err := bsv21Data(txo); err != nil {
+		return nil, nil
+	} else {
+		return &bsv21.Status, nil
+	}
+}
+
+// reingest validates txid again. A transaction which stays pending is taken off the
+// resolve log, as its deps resolve it once they settle. If it is still pending on inputs
+// which were never saved, it is retried in case they can be loaded later.
+func (r *Bsv21Resolver) reingest(ctx context.Context, txid string) error {
+	idxCtx, err := r.Ingest.IngestTxid(ctx, txid, r.Ingest.AncestorConfig)
+	if err != nil {
+		return r.Ingest.Retry(ctx, txid, err)
+	} else if idxCtx == nil {
+		return nil
+	} else if err := clearPending(ctx, idxCtx); err != nil {
+		return err
+	}
+	for _, txo := range idxCtx.Txos {
+		if idxData, ok := txo.Data[BSV21_TAG]; !ok {
+			continue
+		} else if bsv21, ok := idxData.Data.(*Bsv21); !ok || bsv21.Status != Pending {
+			continue
+		} else if err := r.Ingest.Store.Delog(ctx, Bsv21ResolveKey, txo.Outpoint.String()); err != nil {
+			return err
+		} else {
+			for _, dep := range idxData.Deps {
+				if status, err := r.status(ctx, dep.String()); err != nil {
+					return err
+				} else if status == nil {
+					return r.Ingest.Retry(ctx, txid, idx.NewIngestError(txid, idx.ErrMissingInput, fmt.Errorf("%s pending on %s", txo.Outpoint.String(), dep.String())))
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// clearPending removes the stale pending events of token outputs which were validated
+// when their transaction was reingested
+func clearPending(ctx context.Context, idxCtx *idx.IndexContext) error {
+	for _, txo := range idxCtx.Txos {
+		if idxData, ok := txo.Data[BSV21_TAG]; !ok {
+			continue
+		} else if bsv21, ok := idxData.Data.(*Bsv21); !ok || bsv21.Status == Pending {
+			continue
+		} else if err := idxCtx.Store.Delog(ctx, evt.EventKey(BSV21_TAG, &evt.Event{
+			Id:    PendingEvent,
+			Value: bsv21.Id,
+		}), txo.Outpoint.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -1,135 +1,207 @@
 package opns
 
-// type OpNS struct {
-// 	Genesis *lib.Outpoint `json:"genesis,omitempty"`
-// 	Domain  string        `json:"domain"`
-// 	Status  int           `json:"status"`
-// 	PoW     []byte        `json:"pow,omitempty"`
-// }
-
-// type OpNSMineFound struct {
-// 	Outpoint lib.Outpoint `json:"outpoint"`
-// 	Mine     *OpNS        `json:"opnsMine"`
-// }
-
-// var GENESIS, _ = lib.NewOutpointFromString("58b7558ea379f24266c7e2f5fe321992ad9a724fd7a87423ba412677179ccb25_0")
-
-// func IndexTxn(rawtx []byte, blockId string, height uint32, idx uint64, dryRun bool) (ctx *ingest.IndexContext) {
-// 	ctx, err := lib.ParseTxn(rawtx, blockId, height, idx)
-// 	if err != nil {
-// 		log.Panicln(err)
-// 	}
-// 	ParseOpNS(ctx)
-// 	for _, txo := range ctx.Txos {
-// 		txo.Save()
-// 	}
-// 	return
-// }
-
-// func ParseOpNS(ctx *ingest.IndexContext) {
-// 	for _, txo := range ctx.Txos {
-// 		if txo.Owner != nil && len(*txo.Owner) != 0 {
-// 			continue
-// 		}
-// 		opNsMine := ParseScript(txo)
-// 		if opNsMine != nil {
-// 			srcMine := loadSrcMine(ctx)
-// 			if srcMine == nil && bytes.Equal(*txo.Outpoint, *GENESIS) {
-// 				opNsMine.Genesis = txo.Outpoint
-// 				opNsMine.Status = 1
-// 			} else if srcMine != nil && srcMine.Status == 1 && bytes.Equal(*opNsMine.Genesis, *GENESIS) {
-// 				opNsMine.Status = 1
-// 			} else {
-// 				opNsMine.Status = -1
-// 			}
-// 			txo.AddData("opnsMine", opNsMine)
-// 			continue
-// 		}
-// 		ordinals.ParseScript(txo)
-// 		if txo.Data == nil {
-// 			continue
-// 		}
-// 		insc, ok := txo.Data["insc"].(*ordinals.Inscription)
-// 		if !ok || insc.File.Type != "application/op-ns" {
-// 			continue
-// 		}
-
-// 		srcMine := loadSrcMine(ctx)
-// 		if srcMine != nil {
-// 			txo.AddData("opns", &OpNS{
-// 				Genesis: srcMine.Genesis,
-// 				Domain:  insc.Text,
-// 				Status:  srcMine.Status,
-// 			})
-// 		}
-// 	}
-// }
-
-// func loadSrcMine(ctx *ingest.IndexContext) (srcMine *OpNS) {
-// 	var source *lib.Outpoint
-// 	for _, input := range ctx.Tx.Inputs {
-// 		if bytes.Contains(*input.UnlockingScript, OpNSPrefix) {
-// 			source = lib.NewOutpoint(input.PreviousTxID(), input.PreviousTxOutIndex)
-// 			break
-// 		}
-// 	}
-// 	// log.Println("source", source.String())
-// 	srcData, _ := lib.LoadTxoData(source)
-// 	for i := 0; i < 2; i++ {
-// 		if srcData != nil {
-// 			if mineData, ok := srcData["opnsMine"]; ok {
-// 				j, err := json.Marshal(mineData)
-// 				if err == nil {
-// 					srcMine = &OpNS{}
-// 					json.Unmarshal(j, srcMine)
-// 				}
-// 				return srcMine
-// 			}
-// 		}
-// 		if i > 0 {
-// 			break
-// 		}
-// 		srcTx, err := lib.LoadRawtx(hex.EncodeToString(ctx.Spends[0].Outpoint.Txid()))
-// 		if err != nil {
-// 			log.Panicln(err)
-// 		}
-// 		srcCtx := IndexTxn(srcTx, "", 0, 0, true)
-// 		srcData = srcCtx.Txos[source.Vout()].Data
-// 	}
-// 	return srcMine
-// }
-
-// func ParseScript(txo *ingest.Txo) (opNS *OpNS) {
-// 	script := *txo.Tx.Outputs[txo.Outpoint.Vout()].LockingScript
-// 	opNSPrefixIndex := bytes.Index(script, OpNSPrefix)
-// 	if opNSPrefixIndex > -1 {
-// 		opNSSuffixIndex := bytes.Index(script, OpNSSuffix)
-// 		if opNSSuffixIndex > -1 {
-// 			opNS = &OpNS{}
-// 			stateScript := script[opNSSuffixIndex+len(OpNSSuffix)+2:]
-// 			pos := 0
-// 			op, err := lib.ReadOp(stateScript, &pos)
-// 			if err != nil {
-// 				return
-// 			}
-// 			if op.Len == 36 {
-// 				genesis := op.Data
-// 				txid := bt.ReverseBytes(genesis[:32])
-// 				vout := binary.LittleEndian.Uint32(genesis[32:36])
-// 				opNS.Genesis = lib.NewOutpoint(txid, vout)
-// 			}
-// 			if _, err = lib.ReadOp(stateScript, &pos); err != nil {
-// 				return
-// 			}
-// 			if op, err = lib.ReadOp(stateScript, &pos); err != nil {
-// 				return
-// 			}
-// 			opNS.Domain = string(op.Data)
-// 			if op, err = lib.ReadOp(stateScript, &pos); err != nil {
-// 				return
-// 			}
-// 			opNS.PoW = op.Data
-// 		}
-// 	}
-// 	return
-// }
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	"github.com/bsv-blockchain/go-sdk/script"
+	"github.com/shruggr/1sat-indexer/v5/evt"
+	"github.com/shruggr/1sat-indexer/v5/idx"
+	"github.com/shruggr/1sat-indexer/v5/lib"
+	"github.com/shruggr/1sat-indexer/v5/mod/onesat"
+)
+
+const OPNS_TAG = "opns"
+
+const OpNSMimeType = "application/op-ns"
+
+type Status int
+
+var (
+	Invalid Status = -1
+	Pending Status = 0
+	Valid   Status = 1
+)
+
+type OpNS struct {
+	Genesis *lib.Outpoint `json:"genesis,omitempty"`
+	Domain  string        `json:"domain"`
+	Status  Status        `json:"status"`
+	// Mine is set on outputs locked by the mining contract, which carry the
+	// domain prefix mined so far and the proof of work that extended it
+	Mine bool           `json:"mine,omitempty"`
+	PoW  lib.ByteString `json:"pow,omitempty"`
+}
+
+var GENESIS, _ = lib.NewOutpointFromString("58b7558ea379f24266c7e2f5fe321992ad9a724fd7a87423ba412677179ccb25_0")
+
+type OpNSIndexer struct {
+	idx.BaseIndexer
+}
+
+func (i *OpNSIndexer) Tag() string {
+	return OPNS_TAG
+}
+
+func (i *OpNSIndexer) FromBytes(data []byte) (any, error) {
+	return OpNSFromBytes(data)
+}
+
+func OpNSFromBytes(data []byte) (*OpNS, error) {
+	obj := &OpNS{}
+	if err := json.Unmarshal(data, obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func (i *OpNSIndexer) Parse(idxCtx *idx.IndexContext, vout uint32) *idx.IndexData {
+	txo := idxCtx.Txos[vout]
+
+	if opns := ParseScript(idxCtx.Tx.Outputs[vout].LockingScript); opns != nil {
+		srcMine := sourceMine(idxCtx)
+		if srcMine == nil && bytes.Equal(*txo.Outpoint, *GENESIS) {
+			opns.Genesis = txo.Outpoint
+			opns.Status = Valid
+		} else if srcMine == nil {
+			opns.Status = Invalid
+		} else if srcMine.Status == Valid && opns.Genesis != nil && bytes.Equal(*opns.Genesis, *GENESIS) {
+			opns.Status = Valid
+		} else if srcMine.Status == Pending {
+			opns.Status = Pending
+		} else {
+			opns.Status = Invalid
+		}
+		idxData := &idx.IndexData{
+			Data: opns,
+		}
+		if opns.Status == Valid {
+			idxData.Events = append(idxData.Events, &evt.Event{
+				Id:    "mine",
+				Value: opns.Domain,
+			})
+		}
+		return idxData
+	}
+
+	inscData, ok := txo.Data[onesat.INSC_TAG]
+	if !ok {
+		return nil
+	}
+	insc, ok := inscData.Data.(*onesat.Inscription)
+	if !ok || insc.File == nil || insc.File.Type != OpNSMimeType {
+		return nil
+	}
+	srcMine := sourceMine(idxCtx)
+	if srcMine == nil {
+		return nil
+	}
+	domain := insc.Text
+	if domain == "" {
+		domain = string(insc.File.Content)
+	}
+	opns := &OpNS{
+		Genesis: srcMine.Genesis,
+		Domain:  domain,
+		Status:  srcMine.Status,
+	}
+	idxData := &idx.IndexData{
+		Data: opns,
+	}
+	if opns.Status == Valid {
+		idxData.Events = append(idxData.Events, &evt.Event{
+			Id:    "domain",
+			Value: opns.Domain,
+		})
+	}
+	return idxData
+}
+
+// sourceMine returns the mine spent by the transaction. A mine whose data is
+// not yet indexed is reported as pending.
+func sourceMine(idxCtx *idx.IndexContext) *OpNS {
+	for vin, spend := range idxCtx.Spends {
+		if spendData, ok := spend.Data[OPNS_TAG]; ok {
+			if opns, ok := spendData.Data.(*OpNS); ok && opns.Mine {
+				return opns
+			}
+		} else if vin < len(idxCtx.Tx.Inputs) && bytes.Contains(*idxCtx.Tx.Inputs[vin].UnlockingScript, OpNSPrefix) {
+			return &OpNS{Mine: true, Status: Pending}
+		}
+	}
+	return nil
+}
+
+// ParseScript reads the state of an OpNS mining contract
+func ParseScript(scr *script.Script) *OpNS {
+	if bytes.Index(*scr, OpNSPrefix) == -1 {
+		return nil
+	}
+	suffixIndex := bytes.Index(*scr, OpNSSuffix)
+	if suffixIndex == -1 || len(*scr) < suffixIndex+len(OpNSSuffix)+2 {
+		return nil
+	}
+	opns := &OpNS{Mine: true}
+	state := script.NewFromBytes((*scr)[suffixIndex+len(OpNSSuffix)+2:])
+	pos := 0
+	if op, err := state.ReadOp(&pos); err != nil {
+		return opns
+	} else if len(op.Data) == 36 {
+		opns.Genesis = lib.NewOutpointFromBytes(op.Data)
+	}
+	if _, err := state.ReadOp(&pos); err != nil {
+		return opns
+	} else if op, err := state.ReadOp(&pos); err != nil {
+		return opns
+	} else {
+		opns.Domain = string(op.Data)
+	}
+	if op, err := state.ReadOp(&pos); err == nil {
+		opns.PoW = op.Data
+	}
+	return opns
+}
+
+func DomainKey(domain string) string {
+	return evt.EventKey(OPNS_TAG, &evt.Event{
+		Id:    "domain",
+		Value: domain,
+	})
+}
+
+type Resolution struct {
+	Domain   string   `json:"domain"`
+	Claim    string   `json:"claim"`
+	Outpoint string   `json:"outpoint"`
+	Owners   []string `json:"owners"`
+}
+
+// Resolve returns the current location of the first valid claim on domain,
+// following the claim inscription's origin chain. It returns nil when the
+// domain has not been mined.
+func Resolve(ctx context.Context, store idx.TxoStore, domain string) (*Resolution, error) {
+	claims, err := store.SearchOutpoints(ctx, &idx.SearchCfg{
+		Keys:  []string{DomainKey(domain)},
+		Limit: 1,
+	})
+	if err != nil || len(claims) == 0 {
+		return nil, err
+	}
+	res := &Resolution{
+		Domain:   domain,
+		Claim:    claims[0],
+		Outpoint: claims[0],
+	}
+	if latest, err := onesat.LatestOrigin(ctx, store, res.Claim); err != nil {
+		return nil, err
+	} else if latest != nil {
+		res.Outpoint = latest.String()
+	}
+	if txo, err := store.LoadTxo(ctx, res.Outpoint, nil, false, false); err != nil {
+		return nil, err
+	} else if txo != nil {
+		res.Owners = txo.Owners
+	}
+	return res, nil
+}
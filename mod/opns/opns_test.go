@@ -0,0 +1,119 @@
+package opns
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bsv-blockchain/go-sdk/script"
+	"github.com/bsv-blockchain/go-sdk/transaction"
+	"github.com/shruggr/1sat-indexer/v5/idxtest"
+	"github.com/shruggr/1sat-indexer/v5/lib"
+	"github.com/shruggr/1sat-indexer/v5/mod/onesat"
+	"github.com/stretchr/testify/assert"
+)
+
+var p2pkhScript, _ = script.NewFromHex("76a914a1d6e32b14eb8d2b9a6fd8c0cf7b2b8de6bea2ec88ac")
+
+func mineScript(genesis *lib.Outpoint, domain string) *script.Script {
+	scr := script.NewFromBytes(append(append([]byte{}, OpNSPrefix...), OpNSSuffix...))
+	scr.AppendOpcodes(script.OpRETURN, script.OpFALSE)
+	if genesis != nil {
+		scr.AppendPushData(*genesis)
+	} else {
+		scr.AppendOpcodes(script.OpFALSE)
+	}
+	scr.AppendOpcodes(script.OpFALSE)
+	scr.AppendPushData([]byte(domain))
+	scr.AppendPushData([]byte{0x00, 0x00, 0x01})
+	return scr
+}
+
+func claimScript(domain string) *script.Script {
+	scr := &script.Script{}
+	scr.AppendOpcodes(script.OpFALSE, script.OpIF)
+	scr.AppendPushDataString("ord")
+	scr.AppendOpcodes(script.Op1)
+	scr.AppendPushDataString(OpNSMimeType)
+	scr.AppendOpcodes(script.Op0)
+	scr.AppendPushDataString(domain)
+	scr.AppendOpcodes(script.OpENDIF)
+	*scr = append(*scr, *p2pkhScript...)
+	return scr
+}
+
+func buildTx(h *idxtest.Harness, name string, inputs []*lib.Outpoint, outputs ...*transaction.TransactionOutput) *transaction.Transaction {
+	tx := transaction.NewTransaction()
+	for _, input := range inputs {
+		tx.AddInput(&transaction.TransactionInput{
+			SourceTXID:       input.TxidHash(),
+			SourceTxOutIndex: input.Vout(),
+			UnlockingScript:  &script.Script{},
+			SequenceNumber:   0xffffffff,
+		})
+	}
+	for _, output := range outputs {
+		tx.AddOutput(output)
+	}
+	h.AddTx(name, tx)
+	return tx
+}
+
+func TestOpNS(t *testing.T) {
+	h := idxtest.New(t, &onesat.InscriptionIndexer{}, &onesat.OriginIndexer{}, &OpNSIndexer{})
+	ctx := context.Background()
+	// Let origins follow unmined fixtures
+	prevTrigger := onesat.TRIGGER
+	onesat.TRIGGER = 0
+	defer func() { onesat.TRIGGER = prevTrigger }()
+
+	fund := buildTx(h, "fund", nil,
+		&transaction.TransactionOutput{Satoshis: 1, LockingScript: p2pkhScript},
+		&transaction.TransactionOutput{Satoshis: 1000, LockingScript: p2pkhScript},
+	)
+	genesisTx := buildTx(h, "genesis", []*lib.Outpoint{lib.NewOutpointFromHash(fund.TxID(), 0)},
+		&transaction.TransactionOutput{Satoshis: 1, LockingScript: mineScript(nil, "")},
+	)
+	prevGenesis := GENESIS
+	GENESIS = lib.NewOutpointFromHash(genesisTx.TxID(), 0)
+	defer func() { GENESIS = prevGenesis }()
+
+	idxCtx := h.Ingest("genesis")
+	mine := h.Data(idxCtx, 0, OPNS_TAG).Data.(*OpNS)
+	assert.True(t, mine.Mine)
+	assert.Equal(t, Valid, mine.Status)
+	assert.Equal(t, GENESIS.String(), mine.Genesis.String())
+
+	claimTx := buildTx(h, "claim", []*lib.Outpoint{GENESIS, lib.NewOutpointFromHash(fund.TxID(), 1)},
+		&transaction.TransactionOutput{Satoshis: 1, LockingScript: mineScript(GENESIS, "a")},
+		&transaction.TransactionOutput{Satoshis: 1, LockingScript: claimScript("a")},
+	)
+	idxCtx = h.Ingest("claim")
+	assert.Equal(t, []string{"evt:opns:mine:a"}, idxtest.Events(idxCtx, 0, OPNS_TAG))
+	claim := h.Data(idxCtx, 1, OPNS_TAG).Data.(*OpNS)
+	assert.Equal(t, "a", claim.Domain)
+	assert.Equal(t, Valid, claim.Status)
+	assert.Equal(t, []string{"evt:opns:domain:a"}, idxtest.Events(idxCtx, 1, OPNS_TAG))
+
+	// A mine that does not descend from GENESIS is invalid
+	buildTx(h, "fake", []*lib.Outpoint{lib.NewOutpointFromHash(fund.TxID(), 1)},
+		&transaction.TransactionOutput{Satoshis: 1, LockingScript: mineScript(GENESIS, "b")},
+	)
+	idxCtx = h.Parse("fake")
+	assert.Equal(t, Invalid, h.Data(idxCtx, 0, OPNS_TAG).Data.(*OpNS).Status)
+	assert.Empty(t, idxtest.Events(idxCtx, 0, OPNS_TAG))
+
+	// The domain resolves to the current location of the claim
+	buildTx(h, "transfer", []*lib.Outpoint{lib.NewOutpointFromHash(claimTx.TxID(), 1)},
+		&transaction.TransactionOutput{Satoshis: 1, LockingScript: p2pkhScript},
+	)
+	h.Ingest("transfer")
+	res, err := Resolve(ctx, h.Store, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, h.Outpoint("claim", 1), res.Claim)
+	assert.Equal(t, h.Outpoint("transfer", 0), res.Outpoint)
+	assert.Len(t, res.Owners, 1)
+
+	res, err = Resolve(ctx, h.Store, "b")
+	assert.NoError(t, err)
+	assert.Nil(t, res)
+}
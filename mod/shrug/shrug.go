@@ -70,13 +70,17 @@ func parseScript(s *script.Script) *Shrug {
 	pos := 0
 	if op, err := s.ReadOp(&pos); err != nil {
 		return nil
-	} else if len(op.Data) != 9 || string(op.Data[:8]) != SHRUG_TAG {
+	} else if string(op.Data) != SHRUG_TAG {
 		return nil
 	} else if op, err = s.ReadOp(&pos); err != nil {
 		return nil
 	} else if len(op.Data) == 36 {
 		shrug.Id = lib.NewOutpointFromBytes(op.Data)
-	} else if op, err = s.ReadOp(&pos); err != nil {
+	} else if op.Op != script.OpFALSE {
+		return nil
+	}
+
+	if op, err := s.ReadOp(&pos); err != nil {
 		return nil
 	} else if op.Op != script.Op2DROP {
 		return nil
@@ -116,6 +120,7 @@ func (i *ShrugIndexer) PreSave(idxCtx *idx.IndexContext) {
 				id := shrug.Id.String()
 				if token, ok := tokens[id]; !ok {
 					token = &shrugToken{
+						balance: big.NewInt(0),
 						outputs: []*idx.IndexData{
 							idxData,
 						},
@@ -141,7 +146,10 @@ func (i *ShrugIndexer) PreSave(idxCtx *idx.IndexContext) {
 				if shrug.Status == Pending {
 					return
 				} else if shrug.Status == Valid {
-					id := shrug.Id.String()
+					id := spend.Outpoint.String()
+					if shrug.Id != nil {
+						id = shrug.Id.String()
+					}
 					if token, ok := tokens[id]; ok {
 						if shrug.Amount.Cmp(interpreter.Zero) == 0 {
 							token.hasMint = true
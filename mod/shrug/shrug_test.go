@@ -0,0 +1,48 @@
+package shrug
+
+import (
+	"testing"
+
+	"github.com/shruggr/1sat-indexer/v5/idxtest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShrugDeploy(t *testing.T) {
+	h := idxtest.New(t, &ShrugIndexer{})
+	id := h.Outpoint("shrug-deploy", 0)
+	idxCtx := h.Parse("shrug-deploy")
+
+	shrug := h.Data(idxCtx, 0, SHRUG_TAG).Data.(*Shrug)
+	assert.Nil(t, shrug.Id)
+	assert.Equal(t, int64(1000), shrug.Amount.Int64())
+	assert.Equal(t, Valid, shrug.Status)
+	assert.Equal(t, []string{
+		"evt:" + SHRUG_TAG + ":deploy:",
+		"evt:" + SHRUG_TAG + ":valid:" + id,
+	}, idxtest.Events(idxCtx, 0, SHRUG_TAG))
+
+	shrug = h.Data(idxCtx, 1, SHRUG_TAG).Data.(*Shrug)
+	assert.Equal(t, int64(0), shrug.Amount.Int64())
+	assert.Contains(t, idxtest.Events(idxCtx, 1, SHRUG_TAG), "evt:"+SHRUG_TAG+":mint:"+h.Outpoint("shrug-deploy", 1))
+
+	h.Golden("shrug-deploy", idxCtx)
+}
+
+func TestShrugTransfer(t *testing.T) {
+	h := idxtest.New(t, &ShrugIndexer{})
+	id := h.Outpoint("shrug-deploy", 0)
+
+	idxCtx := h.Parse("shrug-transfer")
+	for vout := uint32(0); vout < 2; vout++ {
+		shrug := h.Data(idxCtx, vout, SHRUG_TAG).Data.(*Shrug)
+		assert.Equal(t, id, shrug.Id.String())
+		assert.Equal(t, Valid, shrug.Status)
+		assert.Equal(t, []string{"evt:" + SHRUG_TAG + ":valid:" + id}, idxtest.Events(idxCtx, vout, SHRUG_TAG))
+	}
+	h.Golden("shrug-transfer", idxCtx)
+
+	idxCtx = h.Parse("shrug-overspend")
+	shrug := h.Data(idxCtx, 0, SHRUG_TAG).Data.(*Shrug)
+	assert.Equal(t, Invalid, shrug.Status)
+	h.Golden("shrug-overspend", idxCtx)
+}
@@ -0,0 +1,169 @@
+package sigil
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/bsv-blockchain/go-sdk/script"
+	"github.com/shruggr/1sat-indexer/v5/evt"
+	"github.com/shruggr/1sat-indexer/v5/idx"
+	"github.com/shruggr/1sat-indexer/v5/lib"
+)
+
+const SIGIL_TAG = "sigil"
+
+type Sigil struct {
+	Origin     *lib.Outpoint   `json:"origin"`
+	Nonce      uint64          `json:"nonce"`
+	Title      string          `json:"title,omitempty"`
+	Collection string          `json:"collection,omitempty"`
+	Data       json.RawMessage `json:"data"`
+}
+
+type SigilIndexer struct {
+	idx.BaseIndexer
+}
+
+func (i *SigilIndexer) Tag() string {
+	return SIGIL_TAG
+}
+
+func (i *SigilIndexer) FromBytes(data []byte) (any, error) {
+	return SigilFromBytes(data)
+}
+
+func SigilFromBytes(data []byte) (*Sigil, error) {
+	obj := &Sigil{}
+	if err := json.Unmarshal(data, obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func (i *SigilIndexer) Parse(idxCtx *idx.IndexContext, vout uint32) *idx.IndexData {
+	txo := idxCtx.Txos[vout]
+	pkhash, payload := ParseScript(idxCtx.Tx.Outputs[vout].LockingScript)
+	if payload == nil {
+		return nil
+	}
+	txo.AddOwner(pkhash.Address(idxCtx.Network))
+
+	sigil := &Sigil{
+		Data: payload,
+	}
+	fields := map[string]any{}
+	if err := json.Unmarshal(payload, &fields); err == nil {
+		sigil.Title, _ = fields["title"].(string)
+		sigil.Collection, _ = fields["collection"].(string)
+	}
+
+	// A transfer restates the payload of the sigil it spends
+	deps := make([]*lib.Outpoint, 0)
+	for _, spend := range idxCtx.Spends {
+		if spendData, ok := spend.Data[SIGIL_TAG]; !ok {
+			continue
+		} else if parent, ok := spendData.Data.(*Sigil); !ok || parent.Origin == nil {
+			continue
+		} else if claimed(idxCtx, vout, spend.Outpoint) || !samePayload(parent.Data, payload) {
+			continue
+		} else {
+			sigil.Origin = parent.Origin
+			sigil.Nonce = parent.Nonce + 1
+			deps = append(deps, spend.Outpoint)
+			break
+		}
+	}
+	if sigil.Origin == nil {
+		sigil.Origin = txo.Outpoint
+	}
+
+	idxData := &idx.IndexData{
+		Data: sigil,
+		Deps: deps,
+		Events: []*evt.Event{
+			{
+				Id:    "origin",
+				Value: sigil.Origin.String(),
+			},
+		},
+	}
+	if sigil.Title != "" {
+		idxData.Events = append(idxData.Events, &evt.Event{
+			Id:    "title",
+			Value: sigil.Title,
+		})
+	}
+	if sigil.Collection != "" {
+		idxData.Events = append(idxData.Events, &evt.Event{
+			Id:    "collection",
+			Value: sigil.Collection,
+		})
+	}
+	return idxData
+}
+
+// claimed reports whether an earlier output of the transaction already
+// continues the chain of spend
+func claimed(idxCtx *idx.IndexContext, vout uint32, spend *lib.Outpoint) bool {
+	for _, txo := range idxCtx.Txos[:vout] {
+		if sigilData, ok := txo.Data[SIGIL_TAG]; ok {
+			for _, dep := range sigilData.Deps {
+				if bytes.Equal(*dep, *spend) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func samePayload(a json.RawMessage, b json.RawMessage) bool {
+	var bufA, bufB bytes.Buffer
+	if json.Compact(&bufA, a) != nil || json.Compact(&bufB, b) != nil {
+		return false
+	}
+	return bytes.Equal(bufA.Bytes(), bufB.Bytes())
+}
+
+// ParseScript recognizes the two Sigil templates and returns the owner and
+// JSON payload:
+//
+//	OP_HASH160 <hash> OP_EQUALVERIFY OP_DUP OP_HASH160 <pkhash> OP_EQUALVERIFY OP_CHECKSIG OP_RETURN <json>
+//	OP_DUP OP_HASH160 <pkhash> OP_EQUALVERIFY OP_2 OP_SWAP <pubkey> OP_2 OP_CHECKMULTISIG OP_RETURN <json>
+func ParseScript(scr *script.Script) (pkhash lib.PKHash, payload json.RawMessage) {
+	s := *scr
+	pos := 0
+	if len(s) > 49 &&
+		s[0] == script.OpHASH160 &&
+		s[1] == script.OpDATA20 &&
+		s[22] == script.OpEQUALVERIFY &&
+		s[23] == script.OpDUP &&
+		s[24] == script.OpHASH160 &&
+		s[25] == script.OpDATA20 &&
+		s[46] == script.OpEQUALVERIFY &&
+		s[47] == script.OpCHECKSIG &&
+		s[48] == script.OpRETURN {
+		pkhash = lib.PKHash(s[26:46])
+		pos = 49
+	} else if len(s) > 63 &&
+		s[0] == script.OpDUP &&
+		s[1] == script.OpHASH160 &&
+		s[2] == script.OpDATA20 &&
+		s[23] == script.OpEQUALVERIFY &&
+		s[24] == script.Op2 &&
+		s[25] == script.OpSWAP &&
+		s[26] == script.OpDATA33 &&
+		s[60] == script.Op2 &&
+		s[61] == script.OpCHECKMULTISIG &&
+		s[62] == script.OpRETURN {
+		pkhash = lib.PKHash(s[3:23])
+		pos = 63
+	} else {
+		return nil, nil
+	}
+	if op, err := scr.ReadOp(&pos); err != nil || !json.Valid(op.Data) {
+		return nil, nil
+	} else {
+		return pkhash, json.RawMessage(op.Data)
+	}
+}
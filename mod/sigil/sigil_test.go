@@ -0,0 +1,92 @@
+package sigil
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+
+	"github.com/bsv-blockchain/go-sdk/script"
+	"github.com/bsv-blockchain/go-sdk/transaction"
+	"github.com/shruggr/1sat-indexer/v5/idxtest"
+	"github.com/shruggr/1sat-indexer/v5/lib"
+	"github.com/stretchr/testify/assert"
+)
+
+const payload = `{"title":"Tendie","collection":"Tendies","image":"b://36caf72e804a65e2577f8421b52d4897c67fa4cb354a8837365051a7040f0391"}`
+
+var pkhash, _ = hex.DecodeString("ea5b697ebacbf7c679bae7ab743c8bdffe885581")
+
+func hashLockScript() *script.Script {
+	scr := &script.Script{}
+	scr.AppendOpcodes(script.OpHASH160)
+	scr.AppendPushData(bytes.Repeat([]byte{1}, 20))
+	scr.AppendOpcodes(script.OpEQUALVERIFY, script.OpDUP, script.OpHASH160)
+	scr.AppendPushData(pkhash)
+	scr.AppendOpcodes(script.OpEQUALVERIFY, script.OpCHECKSIG, script.OpRETURN)
+	scr.AppendPushDataString(payload)
+	return scr
+}
+
+func multisigScript() *script.Script {
+	pubkey, _ := hex.DecodeString("022cde271100c7164068931b5d62b38e13d92d1d7e1d354426b86c328a71952b29")
+	scr := &script.Script{}
+	scr.AppendOpcodes(script.OpDUP, script.OpHASH160)
+	scr.AppendPushData(pkhash)
+	scr.AppendOpcodes(script.OpEQUALVERIFY, script.Op2, script.OpSWAP)
+	scr.AppendPushData(pubkey)
+	scr.AppendOpcodes(script.Op2, script.OpCHECKMULTISIG, script.OpRETURN)
+	scr.AppendPushDataString(payload)
+	return scr
+}
+
+func addTx(h *idxtest.Harness, name string, input *lib.Outpoint, scr *script.Script) *transaction.Transaction {
+	tx := transaction.NewTransaction()
+	if input != nil {
+		tx.AddInput(&transaction.TransactionInput{
+			SourceTXID:       input.TxidHash(),
+			SourceTxOutIndex: input.Vout(),
+			UnlockingScript:  &script.Script{},
+			SequenceNumber:   0xffffffff,
+		})
+	}
+	tx.AddOutput(&transaction.TransactionOutput{
+		Satoshis:      1,
+		LockingScript: scr,
+	})
+	h.AddTx(name, tx)
+	return tx
+}
+
+func TestSigil(t *testing.T) {
+	h := idxtest.New(t, &SigilIndexer{})
+	ownerHash := lib.PKHash(pkhash)
+	owner := ownerHash.Address()
+
+	mint := addTx(h, "sigil-mint", nil, hashLockScript())
+	origin := h.Outpoint("sigil-mint", 0)
+	idxCtx := h.Ingest("sigil-mint")
+	sigil := h.Data(idxCtx, 0, SIGIL_TAG).Data.(*Sigil)
+	assert.Equal(t, origin, sigil.Origin.String())
+	assert.Equal(t, uint64(0), sigil.Nonce)
+	assert.Equal(t, "Tendie", sigil.Title)
+	assert.Contains(t, idxCtx.Txos[0].Owners, owner)
+	assert.Equal(t, []string{
+		"evt:sigil:origin:" + origin,
+		"evt:sigil:title:Tendie",
+		"evt:sigil:collection:Tendies",
+	}, idxtest.Events(idxCtx, 0, SIGIL_TAG))
+
+	// A transfer to the multisig template continues the chain
+	addTx(h, "sigil-transfer", lib.NewOutpointFromHash(mint.TxID(), 0), multisigScript())
+	idxCtx = h.Ingest("sigil-transfer")
+	sigil = h.Data(idxCtx, 0, SIGIL_TAG).Data.(*Sigil)
+	assert.Equal(t, origin, sigil.Origin.String())
+	assert.Equal(t, uint64(1), sigil.Nonce)
+	assert.Contains(t, idxCtx.Txos[0].Owners, owner)
+
+	// Scripts that only resemble a template are ignored
+	scr := hashLockScript()
+	(*scr)[48] = script.OpNOP
+	_, data := ParseScript(scr)
+	assert.Nil(t, data)
+}
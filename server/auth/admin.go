@@ -0,0 +1,24 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Admin guards routes which change indexer state. Requests must carry key as a
+// bearer token. When key is empty every request is refused, so admin routes are
+// closed unless a key is configured.
+func Admin(key string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if key == "" {
+			return c.SendStatus(fiber.StatusForbidden)
+		}
+		token, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(key)) != 1 {
+			return c.SendStatus(fiber.StatusUnauthorized)
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,84 @@
+package bsv20
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shruggr/1sat-indexer/v5/idx"
+	"github.com/shruggr/1sat-indexer/v5/mod/onesat"
+)
+
+var ingest *idx.IngestCtx
+
+func RegisterRoutes(r fiber.Router, ingestCtx *idx.IngestCtx) {
+	ingest = ingestCtx
+	r.Get("/:tick", GetTicker)
+	r.Get("/:tick/mints", GetMints)
+}
+
+type Mint struct {
+	Outpoint string  `json:"outpoint"`
+	Score    float64 `json:"score"`
+	Supply   uint64  `json:"supply"`
+}
+
+// @Summary Get BSV-20 ticker
+// @Description Get the valid deploy of a BSV-20 ticker along with its minted supply
+// @Tags bsv20
+// @Produce json
+// @Param tick path string true "Ticker"
+// @Success 200 {object} onesat.Bsv20Ticker
+// @Failure 404 {string} string "Ticker not deployed"
+// @Failure 500 {string} string "Internal server error"
+// @Router /v5/bsv20/{tick} [get]
+func GetTicker(c *fiber.Ctx) error {
+	if ticker, err := onesat.LoadBsv20Ticker(c.Context(), ingest.Store, c.Params("tick")); err != nil {
+		return err
+	} else if ticker == nil {
+		return c.SendStatus(404)
+	} else {
+		return c.JSON(ticker)
+	}
+}
+
+// @Summary List BSV-20 mints
+// @Description List the valid mints of a BSV-20 ticker in score order along with the minted supply after each mint
+// @Tags bsv20
+// @Produce json
+// @Param tick path string true "Ticker"
+// @Param from query number false "Starting score for pagination"
+// @Param rev query bool false "Reverse order"
+// @Param limit query int false "Maximum number of results" default(100)
+// @Success 200 {array} Mint
+// @Failure 500 {string} string "Internal server error"
+// @Router /v5/bsv20/{tick}/mints [get]
+func GetMints(c *fiber.Ctx) error {
+	from := c.QueryFloat("from", 0)
+	if txos, err := ingest.Store.SearchTxos(c.Context(), &idx.SearchCfg{
+		Keys:        []string{onesat.Bsv20MintKey(strings.ToUpper(c.Params("tick")))},
+		From:        &from,
+		Reverse:     c.QueryBool("rev", false),
+		Limit:       uint32(c.QueryInt("limit", 100)),
+		IncludeTags: []string{onesat.BSV20_TAG},
+	}); err != nil {
+		return err
+	} else {
+		mints := make([]*Mint, 0, len(txos))
+		for _, txo := range txos {
+			mint := &Mint{
+				Outpoint: txo.Outpoint.String(),
+				Score:    txo.Score,
+			}
+			if data, ok := txo.Data[onesat.BSV20_TAG]; ok {
+				if raw, ok := data.Data.(json.RawMessage); ok {
+					if bsv20, err := onesat.Bsv20FromBytes(raw); err == nil {
+						mint.Supply = bsv20.Supply
+					}
+				}
+			}
+			mints = append(mints, mint)
+		}
+		return c.JSON(mints)
+	}
+}
@@ -0,0 +1,157 @@
+package bsv21
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shruggr/1sat-indexer/v5/idx"
+	"github.com/shruggr/1sat-indexer/v5/mod/onesat"
+)
+
+var ingest *idx.IngestCtx
+
+func RegisterRoutes(r fiber.Router, ingestCtx *idx.IngestCtx) {
+	ingest = ingestCtx
+	r.Get("/:id", GetToken)
+	r.Get("/:id/fund", GetFund)
+	r.Get("/:id/holders", GetHolders)
+	r.Get("/:id/owner/:address/balance", GetBalance)
+	r.Get("/:id/owner/:address/utxos", GetUtxos)
+}
+
+type Balance struct {
+	Id      string `json:"id"`
+	Address string `json:"address"`
+	Amt     uint64 `json:"amt"`
+	Dec     uint8  `json:"dec"`
+	Balance string `json:"balance"`
+	Utxos   int    `json:"utxos"`
+}
+
+// @Summary Get BSV-21 token
+// @Description Get the deploy details and circulating supply of a BSV-21 token
+// @Tags bsv21
+// @Produce json
+// @Param id path string true "Token id (deploy outpoint)"
+// @Success 200 {object} onesat.Bsv21Token
+// @Failure 404 {string} string "Token not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /v5/bsv21/{id} [get]
+func GetToken(c *fiber.Ctx) error {
+	if token, err := onesat.LoadBsv21Token(c.Context(), ingest.Store, c.Params("id")); err != nil {
+		return err
+	} else if token == nil {
+		return c.SendStatus(404)
+	} else {
+		return c.JSON(token)
+	}
+}
+
+// @Summary Get BSV-21 fund status
+// @Description Get the indexing fee balance of a BSV-21 token and whether its validation is paused
+// @Tags bsv21
+// @Produce json
+// @Param id path string true "Token id (deploy outpoint)"
+// @Success 200 {object} onesat.Bsv21FundStatus
+// @Failure 404 {string} string "Token not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /v5/bsv21/{id}/fund [get]
+func GetFund(c *fiber.Ctx) error {
+	if status, err := onesat.LoadBsv21FundStatus(c.Context(), ingest.Store, c.Params("id")); err != nil {
+		return err
+	} else if status == nil {
+		return c.SendStatus(404)
+	} else {
+		return c.JSON(status)
+	}
+}
+
+// @Summary Get BSV-21 token holders
+// @Description Get the holders of a BSV-21 token ranked by balance
+// @Tags bsv21
+// @Produce json
+// @Param id path string true "Token id (deploy outpoint)"
+// @Param from query number false "Only return holders with a smaller balance than this"
+// @Param limit query int false "Maximum number of results" default(100)
+// @Success 200 {array} onesat.Bsv21Holder
+// @Failure 400 {string} string "Invalid from"
+// @Failure 404 {string} string "Token not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /v5/bsv21/{id}/holders [get]
+func GetHolders(c *fiber.Ctx) error {
+	id := c.Params("id")
+	var from *float64
+	if c.Query("from") != "" {
+		if f, err := strconv.ParseFloat(c.Query("from"), 64); err != nil {
+			return c.SendStatus(400)
+		} else {
+			from = &f
+		}
+	}
+	if token, err := onesat.LoadBsv21Token(c.Context(), ingest.Store, id); err != nil {
+		return err
+	} else if token == nil {
+		return c.SendStatus(404)
+	} else if holders, err := onesat.LoadBsv21Holders(c.Context(), ingest.Store, id, token.Decimals, from, uint32(c.QueryInt("limit", 100))); err != nil {
+		return err
+	} else {
+		return c.JSON(holders)
+	}
+}
+
+// @Summary Get BSV-21 balance
+// @Description Get the balance of a BSV-21 token held by an address
+// @Tags bsv21
+// @Produce json
+// @Param id path string true "Token id (deploy outpoint)"
+// @Param address path string true "Owner address"
+// @Success 200 {object} Balance
+// @Failure 404 {string} string "Token not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /v5/bsv21/{id}/owner/{address}/balance [get]
+func GetBalance(c *fiber.Ctx) error {
+	id := c.Params("id")
+	address := c.Params("address")
+	if token, err := onesat.LoadBsv21Token(c.Context(), ingest.Store, id); err != nil {
+		return err
+	} else if token == nil {
+		return c.SendStatus(404)
+	} else if utxos, err := onesat.LoadBsv21Utxos(c.Context(), ingest.Store, id, address); err != nil {
+		return err
+	} else {
+		balance := &Balance{
+			Id:      id,
+			Address: address,
+			Dec:     token.Decimals,
+			Utxos:   len(utxos),
+		}
+		for _, utxo := range utxos {
+			balance.Amt += utxo.Data[onesat.BSV21_TAG].Data.(*onesat.Bsv21).Amt
+		}
+		balance.Balance = onesat.FormatAmount(balance.Amt, token.Decimals)
+		return c.JSON(balance)
+	}
+}
+
+// @Summary Get BSV-21 utxos
+// @Description Get the unspent valid outputs of a BSV-21 token held by an address
+// @Tags bsv21
+// @Produce json
+// @Param id path string true "Token id (deploy outpoint)"
+// @Param address path string true "Owner address"
+// @Success 200 {array} idx.Txo
+// @Failure 404 {string} string "Token not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /v5/bsv21/{id}/owner/{address}/utxos [get]
+func GetUtxos(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if token, err := onesat.LoadBsv21Token(c.Context(), ingest.Store, id); err != nil {
+		return err
+	} else if token == nil {
+		return c.SendStatus(404)
+	} else if utxos, err := onesat.LoadBsv21Utxos(c.Context(), ingest.Store, id, c.Params("address")); err != nil {
+		return err
+	} else {
+		return c.JSON(utxos)
+	}
+}
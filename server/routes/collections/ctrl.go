@@ -0,0 +1,38 @@
+package collections
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/shruggr/1sat-indexer/v5/idx"
+	"github.com/shruggr/1sat-indexer/v5/mod/onesat"
+)
+
+var ingest *idx.IngestCtx
+
+func RegisterRoutes(r fiber.Router, ingestCtx *idx.IngestCtx) {
+	ingest = ingestCtx
+	r.Get("/:origin/items", CollectionItems)
+}
+
+// @Summary Get collection items
+// @Description List the inscriptions whose parent, spent in the same transaction, belongs to the collection origin
+// @Tags collections
+// @Produce json
+// @Param origin path string true "Collection origin outpoint"
+// @Param from query number false "Starting score for pagination"
+// @Param limit query int false "Maximum number of results" default(100)
+// @Success 200 {array} onesat.CollectionItem
+// @Failure 500 {string} string "Internal server error"
+// @Router /v5/collections/{origin}/items [get]
+func CollectionItems(c *fiber.Ctx) error {
+	if items, err := onesat.LoadCollectionItems(
+		c.Context(),
+		ingest.Store,
+		c.Params("origin"),
+		c.QueryFloat("from", 0),
+		uint32(c.QueryInt("limit", 100)),
+	); err != nil {
+		return err
+	} else {
+		return c.JSON(items)
+	}
+}
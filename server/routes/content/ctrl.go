@@ -0,0 +1,142 @@
+package content
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shruggr/1sat-indexer/v5/idx"
+	"github.com/shruggr/1sat-indexer/v5/jb"
+	"github.com/shruggr/1sat-indexer/v5/lib"
+	"github.com/shruggr/1sat-indexer/v5/mod/onesat"
+)
+
+var ingest *idx.IngestCtx
+var loader *onesat.ContentLoader
+
+func RegisterRoutes(r fiber.Router, ingestCtx *idx.IngestCtx) {
+	ingest = ingestCtx
+	loader = &onesat.ContentLoader{
+		Source:  ingestCtx.TxSource(),
+		Store:   ingestCtx.Store,
+		Cache:   jb.Cache,
+		TTL:     24 * time.Hour,
+		MaxSize: 1024 * 1024,
+	}
+	r.Get("/origin/:origin", GetOriginContent)
+	r.Get("/:outpoint", GetContent)
+}
+
+// @Summary Get inscription content
+// @Description Get the body of the inscription at an outpoint, served with its MIME type and encoding. Supports ETag and single Range requests; multiple ranges are answered with the whole body. Responses are not compressed.
+// @Tags content
+// @Produce octet-stream
+// @Param outpoint path string true "Outpoint (txid_vout)"
+// @Success 200 {string} binary "Inscription content"
+// @Success 206 {string} binary "Partial inscription content"
+// @Success 304 "Not modified"
+// @Failure 400 {string} string "Invalid outpoint"
+// @Failure 404 {string} string "Inscription not found"
+// @Failure 416 {string} string "Range not satisfiable"
+// @Failure 500 {string} string "Internal server error"
+// @Router /v5/content/{outpoint} [get]
+func GetContent(c *fiber.Ctx) error {
+	if outpoint, err := lib.NewOutpointFromString(c.Params("outpoint")); err != nil {
+		return c.SendStatus(400)
+	} else {
+		return sendContent(c, outpoint, "public,max-age=31536000,immutable")
+	}
+}
+
+// @Summary Get latest inscription content for an origin
+// @Description Get the body of the inscription at the latest outpoint in the chain of an origin
+// @Tags content
+// @Produce octet-stream
+// @Param origin path string true "Origin outpoint (txid_vout)"
+// @Success 200 {string} binary "Inscription content"
+// @Success 206 {string} binary "Partial inscription content"
+// @Success 304 "Not modified"
+// @Failure 404 {string} string "Origin not found"
+// @Failure 416 {string} string "Range not satisfiable"
+// @Failure 500 {string} string "Internal server error"
+// @Router /v5/content/origin/{origin} [get]
+func GetOriginContent(c *fiber.Ctx) error {
+	if outpoint, err := onesat.LatestOrigin(c.Context(), ingest.Store, c.Params("origin")); err != nil {
+		return err
+	} else if outpoint == nil {
+		return c.SendStatus(404)
+	} else {
+		c.Set("X-Outpoint", outpoint.String())
+		return sendContent(c, outpoint, "public,max-age=60")
+	}
+}
+
+func sendContent(c *fiber.Ctx, outpoint *lib.Outpoint, cacheControl string) error {
+	// The saved inscription answers conditional requests without loading the transaction.
+	// Delegating inscriptions are served with their delegate's body, so they are loaded.
+	var meta *lib.File
+	if insc, err := loader.Stored(c.Context(), outpoint); err != nil {
+		return err
+	} else if insc != nil && (insc.Delegate == nil || insc.File.Size > 0) {
+		meta = insc.File
+	}
+	var file *lib.File
+	if meta == nil {
+		var err error
+		if file, err = loadContent(c, outpoint); err != nil || file == nil {
+			return err
+		}
+		meta = file
+	}
+
+	etag := onesat.ContentETag(meta)
+	c.Set("Cache-Control", cacheControl)
+	c.Set("ETag", etag)
+	c.Set("Accept-Ranges", "bytes")
+	if onesat.ETagMatches(c.Get("If-None-Match"), etag) {
+		return c.SendStatus(304)
+	}
+
+	if file == nil {
+		var err error
+		if file, err = loadContent(c, outpoint); err != nil || file == nil {
+			return err
+		}
+	}
+	if meta.Type != "" {
+		c.Set("Content-Type", meta.Type)
+	} else {
+		c.Set("Content-Type", "application/octet-stream")
+	}
+	if meta.Encoding != "" {
+		c.Set("Content-Encoding", meta.Encoding)
+	}
+
+	if c.Get("Range") == "" {
+		return c.Send(file.Content)
+	}
+	size := len(file.Content)
+	if rng, err := c.Range(size); err != nil || rng.Type != "bytes" {
+		c.Set("Content-Range", "bytes */"+strconv.Itoa(size))
+		return c.SendStatus(416)
+	} else if len(rng.Ranges) > 1 {
+		// Multipart responses aren't supported, so the whole body is sent
+		return c.Send(file.Content)
+	} else {
+		start, end := rng.Ranges[0].Start, rng.Ranges[0].End
+		c.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, size))
+		return c.Status(206).Send(file.Content[start : end+1])
+	}
+}
+
+// loadContent loads the inscription body at outpoint, responding 404 and returning a nil
+// file when there is none
+func loadContent(c *fiber.Ctx, outpoint *lib.Outpoint) (*lib.File, error) {
+	file, err := loader.Load(c.Context(), outpoint)
+	if errors.Is(err, jb.ErrNotFound) {
+		return nil, c.SendStatus(404)
+	}
+	return file, err
+}
@@ -0,0 +1,106 @@
+package cosigner
+
+import (
+	"errors"
+	"log"
+
+	"github.com/bsv-blockchain/go-sdk/transaction"
+	"github.com/bsv-blockchain/go-sdk/transaction/broadcaster"
+	"github.com/gofiber/fiber/v2"
+	"github.com/shruggr/1sat-indexer/v5/broadcast"
+	"github.com/shruggr/1sat-indexer/v5/idx"
+	"github.com/shruggr/1sat-indexer/v5/mod/cosign"
+)
+
+var ingest *idx.IngestCtx
+var b *broadcaster.Arc
+var approver *cosign.Approver
+
+func RegisterRoutes(r fiber.Router, ingestCtx *idx.IngestCtx, arcBroadcaster *broadcaster.Arc, cosignApprover *cosign.Approver) {
+	ingest = ingestCtx
+	b = arcBroadcaster
+	approver = cosignApprover
+	r.Get("/", GetCosigner)
+	r.Post("/", Approve)
+}
+
+type Cosigner struct {
+	Cosigner string `json:"cosigner"`
+}
+
+type Approval struct {
+	Txid      string                       `json:"txid"`
+	Rawtx     string                       `json:"rawtx"`
+	Broadcast *broadcast.BroadcastResponse `json:"broadcast,omitempty"`
+}
+
+// @Summary Get cosigner
+// @Description Get the public key this service approves cosign locks for
+// @Tags cosign
+// @Produce json
+// @Success 200 {object} Cosigner
+// @Router /v5/cosign [get]
+func GetCosigner(c *fiber.Ctx) error {
+	return c.JSON(Cosigner{Cosigner: approver.Cosigner()})
+}
+
+// @Summary Approve cosigned transaction
+// @Description Check a transaction signed by the owners of its cosign inputs against the approval policies and add the cosigner signature
+// @Tags cosign
+// @Accept octet-stream,plain
+// @Produce json
+// @Param fmt query string false "Transaction format: 'beef' or standard" Enums(beef)
+// @Param broadcast query bool false "Broadcast the approved transaction"
+// @Param transaction body string true "Transaction bytes (binary or hex)"
+// @Success 200 {object} Approval "Approved transaction"
+// @Failure 400 {string} string "Invalid or unsigned transaction"
+// @Failure 403 {string} string "Rejected by policy"
+// @Failure 500 {string} string "Internal server error"
+// @Router /v5/cosign [post]
+func Approve(c *fiber.Ctx) (err error) {
+	var tx *transaction.Transaction
+	switch c.Get("Content-Type") {
+	case "application/octet-stream":
+		if c.Query("fmt") == "beef" {
+			tx, err = transaction.NewTransactionFromBEEF(c.Body())
+		} else {
+			tx, err = transaction.NewTransactionFromBytes(c.Body())
+		}
+	case "text/plain":
+		if c.Query("fmt") == "beef" {
+			tx, err = transaction.NewTransactionFromBEEFHex(string(c.Body()))
+		} else {
+			tx, err = transaction.NewTransactionFromHex(string(c.Body()))
+		}
+	default:
+		return c.SendStatus(400)
+	}
+	if err != nil {
+		return c.SendStatus(400)
+	}
+
+	if _, err := approver.Approve(c.Context(), tx); errors.Is(err, cosign.ErrRejected) {
+		return c.Status(403).SendString(err.Error())
+	} else if errors.Is(err, cosign.ErrNotCosigned) || errors.Is(err, cosign.ErrUnsigned) {
+		return c.Status(400).SendString(err.Error())
+	} else if err != nil {
+		return err
+	}
+
+	approval := &Approval{
+		Txid:  tx.TxID().String(),
+		Rawtx: tx.Hex(),
+	}
+	if c.QueryBool("broadcast") {
+		approval.Broadcast = broadcast.Broadcast(c.Context(), ingest.Store, tx, b)
+		if approval.Broadcast.Success {
+			if _, err := ingest.IngestTx(c.Context(), tx, idx.AncestorConfig{Load: true, Parse: true, Save: true}); err != nil {
+				log.Println("Ingest Error", approval.Txid, err)
+			}
+		} else {
+			log.Println("Broadcast Error", approval.Txid, approval.Broadcast.Error)
+		}
+		c.Status(int(approval.Broadcast.Status))
+	}
+	return c.JSON(approval)
+}
@@ -0,0 +1,84 @@
+package dlq
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/shruggr/1sat-indexer/v5/idx"
+)
+
+var ingest *idx.IngestCtx
+
+func RegisterRoutes(r fiber.Router, ingestCtx *idx.IngestCtx, admin fiber.Handler) {
+	ingest = ingestCtx
+	r.Get("/:tag", ListDeadLetters)
+	r.Post("/:tag/:txid", admin, RequeueDeadLetter)
+}
+
+type DeadLetter struct {
+	Txid     string  `json:"txid"`
+	Score    float64 `json:"score"`
+	Attempts uint32  `json:"attempts"`
+}
+
+// @Summary List dead-lettered transactions
+// @Description List transactions which exhausted their ingest retries for a tag
+// @Tags dlq
+// @Produce json
+// @Param tag path string true "Ingest tag"
+// @Param from query number false "Starting score for pagination"
+// @Param rev query bool false "Reverse order"
+// @Param limit query int false "Maximum number of results" default(100)
+// @Success 200 {array} DeadLetter
+// @Failure 500 {string} string "Internal server error"
+// @Router /v5/dlq/{tag} [get]
+func ListDeadLetters(c *fiber.Ctx) error {
+	tag := c.Params("tag")
+	from := c.QueryFloat("from", 0)
+	if logs, err := ingest.Store.Search(c.Context(), &idx.SearchCfg{
+		Keys:    []string{idx.DeadLetterKey(tag)},
+		From:    &from,
+		Reverse: c.QueryBool("rev", false),
+		Limit:   uint32(c.QueryInt("limit", 100)),
+	}); err != nil {
+		return err
+	} else {
+		results := make([]*DeadLetter, 0, len(logs))
+		for _, l := range logs {
+			if attempts, err := ingest.Store.LogScore(c.Context(), idx.AttemptsKey(tag), l.Member); err != nil {
+				return err
+			} else {
+				results = append(results, &DeadLetter{
+					Txid:     l.Member,
+					Score:    l.Score,
+					Attempts: uint32(attempts),
+				})
+			}
+		}
+		return c.JSON(results)
+	}
+}
+
+// @Summary Requeue a dead-lettered transaction
+// @Description Move a dead-lettered transaction back onto its ingest queue and reset its attempts
+// @Tags dlq
+// @Param tag path string true "Ingest tag"
+// @Param txid path string true "Transaction ID"
+// @Param queue query string false "Queue tag to requeue onto, defaults to the ingest tag"
+// @Param Authorization header string true "Bearer ADMIN_KEY"
+// @Success 204
+// @Failure 401 {string} string "Missing or wrong admin key"
+// @Failure 403 {string} string "Admin routes disabled, ADMIN_KEY is not set"
+// @Failure 404 {string} string "Transaction is not dead-lettered"
+// @Failure 500 {string} string "Internal server error"
+// @Router /v5/dlq/{tag}/{txid} [post]
+func RequeueDeadLetter(c *fiber.Ctx) error {
+	tag := c.Params("tag")
+	txid := c.Params("txid")
+	if score, err := ingest.Store.LogScore(c.Context(), idx.DeadLetterKey(tag), txid); err != nil {
+		return err
+	} else if score == 0 {
+		return c.SendStatus(404)
+	} else if err := idx.Requeue(c.Context(), ingest.Store, tag, idx.QueueKey(c.Query("queue", tag)), txid); err != nil {
+		return err
+	}
+	return c.SendStatus(204)
+}
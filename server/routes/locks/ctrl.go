@@ -0,0 +1,110 @@
+package locks
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/shruggr/1sat-indexer/v5/blk"
+	"github.com/shruggr/1sat-indexer/v5/idx"
+	"github.com/shruggr/1sat-indexer/v5/mod/lock"
+)
+
+var ingest *idx.IngestCtx
+
+func RegisterRoutes(r fiber.Router, ingestCtx *idx.IngestCtx) {
+	ingest = ingestCtx
+	r.Get("/targets", LockTargets)
+	r.Get("/targets/:target", LockTarget)
+	r.Get("/:address", LocksByAddress)
+	r.Get("/:address/totals", LockTotals)
+}
+
+// @Summary Get locks by address
+// @Description Get the open time locks of an address that are still locked, or unlockable at the current chaintip
+// @Tags locks
+// @Produce json
+// @Param address path string true "Lock address"
+// @Param status query string false "Lock status" Enums(locked, unlockable) default(locked)
+// @Param limit query int false "Maximum number of results" default(100)
+// @Success 200 {array} idx.Txo
+// @Failure 400 {string} string "Invalid status"
+// @Failure 500 {string} string "Internal server error"
+// @Router /v5/locks/{address} [get]
+func LocksByAddress(c *fiber.Ctx) error {
+	status := c.Query("status", lock.StatusLocked)
+	if status != lock.StatusLocked && status != lock.StatusUnlockable {
+		return c.SendStatus(400)
+	}
+	if chaintip, err := blk.GetChaintip(c.Context()); err != nil {
+		return err
+	} else if txos, err := lock.SearchLocks(c.Context(), ingest.Store, c.Params("address"), status, chaintip.Height, uint32(c.QueryInt("limit", 100))); err != nil {
+		return err
+	} else {
+		c.Set("Cache-Control", "public,max-age=60")
+		return c.JSON(txos)
+	}
+}
+
+// @Summary Get lock totals by address
+// @Description Get the satoshis an address has locked and unlockable at the current chaintip
+// @Tags locks
+// @Produce json
+// @Param address path string true "Lock address"
+// @Success 200 {object} lock.LockTotals
+// @Failure 500 {string} string "Internal server error"
+// @Router /v5/locks/{address}/totals [get]
+func LockTotals(c *fiber.Ctx) error {
+	if chaintip, err := blk.GetChaintip(c.Context()); err != nil {
+		return err
+	} else if totals, err := lock.LoadLockTotals(c.Context(), ingest.Store, c.Params("address"), chaintip.Height); err != nil {
+		return err
+	} else {
+		c.Set("Cache-Control", "public,max-age=60")
+		return c.JSON(totals)
+	}
+}
+
+// @Summary Get lock targets
+// @Description Get the transactions liked through locks, ranked by the satoshis currently locked to them
+// @Tags locks
+// @Produce json
+// @Param from query number false "Only return targets with fewer locked satoshis than this"
+// @Param limit query int false "Maximum number of results" default(100)
+// @Success 200 {array} lock.TargetTotal
+// @Failure 400 {string} string "Invalid from"
+// @Failure 500 {string} string "Internal server error"
+// @Router /v5/locks/targets [get]
+func LockTargets(c *fiber.Ctx) error {
+	var from *float64
+	if c.Query("from") != "" {
+		if f, err := strconv.ParseFloat(c.Query("from"), 64); err != nil {
+			return c.SendStatus(400)
+		} else {
+			from = &f
+		}
+	}
+	if targets, err := lock.SearchTargets(c.Context(), ingest.Store, from, uint32(c.QueryInt("limit", 100))); err != nil {
+		return err
+	} else {
+		c.Set("Cache-Control", "public,max-age=60")
+		return c.JSON(targets)
+	}
+}
+
+// @Summary Get lock target
+// @Description Get the satoshis currently locked to a transaction and the addresses that locked them
+// @Tags locks
+// @Produce json
+// @Param target path string true "Target txid"
+// @Param limit query int false "Maximum number of lockers" default(100)
+// @Success 200 {object} lock.TargetLocks
+// @Failure 500 {string} string "Internal server error"
+// @Router /v5/locks/targets/{target} [get]
+func LockTarget(c *fiber.Ctx) error {
+	if locks, err := lock.LoadTargetLocks(c.Context(), ingest.Store, c.Params("target"), uint32(c.QueryInt("limit", 100))); err != nil {
+		return err
+	} else {
+		c.Set("Cache-Control", "public,max-age=60")
+		return c.JSON(locks)
+	}
+}
@@ -0,0 +1,84 @@
+package market
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/shruggr/1sat-indexer/v5/idx"
+	"github.com/shruggr/1sat-indexer/v5/mod/onesat"
+)
+
+var ingest *idx.IngestCtx
+
+func RegisterRoutes(r fiber.Router, ingestCtx *idx.IngestCtx) {
+	ingest = ingestCtx
+	r.Get("/listings", Listings)
+	r.Get("/sales", Sales)
+}
+
+func queryFloat(c *fiber.Ctx, key string) *float64 {
+	if c.Query(key) == "" {
+		return nil
+	}
+	v := c.QueryFloat(key)
+	return &v
+}
+
+// @Summary Get market listings
+// @Description Get open OrdLock listings for a tag (owner address, origin, token id or ticker, bsv20, bsv21, or empty for all), with origin and token data
+// @Tags market
+// @Produce json
+// @Param tag query string false "Listing tag"
+// @Param sort query string false "Sort order" Enums(recent, price, pricePer) default(recent)
+// @Param min query number false "Minimum price, inclusive"
+// @Param max query number false "Maximum price, inclusive"
+// @Param from query number false "Starting score for pagination"
+// @Param rev query bool false "Reverse order"
+// @Param limit query int false "Maximum number of results" default(100)
+// @Success 200 {array} idx.Txo
+// @Failure 400 {string} string "Invalid sort"
+// @Failure 500 {string} string "Internal server error"
+// @Router /v5/market/listings [get]
+func Listings(c *fiber.Ctx) error {
+	sort := c.Query("sort", onesat.SortRecent)
+	if sort != onesat.SortRecent && sort != onesat.SortPrice && sort != onesat.SortPricePer {
+		return c.SendStatus(400)
+	}
+	if txos, err := onesat.SearchListings(c.Context(), ingest.Store, &onesat.ListingsCfg{
+		Tag:     c.Query("tag"),
+		Sort:    sort,
+		Min:     queryFloat(c, "min"),
+		Max:     queryFloat(c, "max"),
+		From:    queryFloat(c, "from"),
+		Reverse: c.QueryBool("rev", false),
+		Limit:   uint32(c.QueryInt("limit", 100)),
+	}); err != nil {
+		return err
+	} else {
+		return c.JSON(txos)
+	}
+}
+
+// @Summary Get market sales
+// @Description Get completed OrdLock sales for a tag, with origin and token data
+// @Tags market
+// @Produce json
+// @Param tag query string false "Listing tag"
+// @Param from query number false "Starting score for pagination"
+// @Param rev query bool false "Reverse order" default(true)
+// @Param limit query int false "Maximum number of results" default(100)
+// @Success 200 {array} idx.Txo
+// @Failure 500 {string} string "Internal server error"
+// @Router /v5/market/sales [get]
+func Sales(c *fiber.Ctx) error {
+	if txos, err := onesat.SearchSales(
+		c.Context(),
+		ingest.Store,
+		c.Query("tag"),
+		queryFloat(c, "from"),
+		c.QueryBool("rev", true),
+		uint32(c.QueryInt("limit", 100)),
+	); err != nil {
+		return err
+	} else {
+		return c.JSON(txos)
+	}
+}
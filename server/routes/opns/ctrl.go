@@ -0,0 +1,33 @@
+package opns
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/shruggr/1sat-indexer/v5/idx"
+	"github.com/shruggr/1sat-indexer/v5/mod/opns"
+)
+
+var ingest *idx.IngestCtx
+
+func RegisterRoutes(r fiber.Router, ingestCtx *idx.IngestCtx) {
+	ingest = ingestCtx
+	r.Get("/:domain", ResolveDomain)
+}
+
+// @Summary Resolve OpNS domain
+// @Description Resolve a mined OpNS domain to the current outpoint and owners of its claim inscription
+// @Tags opns
+// @Produce json
+// @Param domain path string true "Domain name"
+// @Success 200 {object} opns.Resolution
+// @Failure 404 {string} string "Domain not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /v5/opns/{domain} [get]
+func ResolveDomain(c *fiber.Ctx) error {
+	if res, err := opns.Resolve(c.Context(), ingest.Store, c.Params("domain")); err != nil {
+		return err
+	} else if res == nil {
+		return c.SendStatus(404)
+	} else {
+		return c.JSON(res)
+	}
+}
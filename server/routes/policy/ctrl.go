@@ -0,0 +1,169 @@
+package policy
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/shruggr/1sat-indexer/v5/idx"
+	"github.com/shruggr/1sat-indexer/v5/mod/onesat"
+)
+
+var ingest *idx.IngestCtx
+
+func RegisterRoutes(r fiber.Router, ingestCtx *idx.IngestCtx, admin fiber.Handler) {
+	ingest = ingestCtx
+	r.Get("/:tag", GetPolicy)
+	r.Put("/:tag/allowlist", admin, EnableAllowList)
+	r.Delete("/:tag/allowlist", admin, DisableAllowList)
+	r.Put("/:tag/allow/:token", admin, AllowToken)
+	r.Put("/:tag/deny/:token", admin, DenyToken)
+	r.Delete("/:tag/deny/:token", admin, UndenyToken)
+	r.Delete("/:tag/:token", admin, ClearToken)
+}
+
+type Requeued struct {
+	Requeued int `json:"requeued"`
+}
+
+// requeueKey is the queue skipped transactions are requeued onto: the queue tag given,
+// or the queue consumed by the ingest
+func requeueKey(c *fiber.Ctx) string {
+	if queue := c.Query("queue"); queue != "" {
+		return idx.QueueKey(queue)
+	} else if ingest.Key != "" {
+		return ingest.Key
+	}
+	return idx.IngestQueueKey
+}
+
+// @Summary Get token policy
+// @Description Get the allow list mode and the allow and deny lists held in the store for a token tag
+// @Tags policy
+// @Produce json
+// @Param tag path string true "Token tag (bsv20 or bsv21)"
+// @Success 200 {object} onesat.PolicyLists
+// @Failure 500 {string} string "Internal server error"
+// @Router /v5/policy/{tag} [get]
+func GetPolicy(c *fiber.Ctx) error {
+	if lists, err := onesat.LoadPolicyLists(c.Context(), ingest.Store, c.Params("tag")); err != nil {
+		return err
+	} else {
+		return c.JSON(lists)
+	}
+}
+
+// @Summary Enter allow list mode
+// @Description Only index the tokens of a tag which are in its allow list. Other tokens are skipped until they are allowed or the mode is left.
+// @Tags policy
+// @Param tag path string true "Token tag (bsv20 or bsv21)"
+// @Param Authorization header string true "Bearer ADMIN_KEY"
+// @Success 204
+// @Failure 401 {string} string "Missing or wrong admin key"
+// @Failure 403 {string} string "Admin routes disabled, ADMIN_KEY is not set"
+// @Failure 500 {string} string "Internal server error"
+// @Router /v5/policy/{tag}/allowlist [put]
+func EnableAllowList(c *fiber.Ctx) error {
+	if _, err := onesat.SetAllowListMode(c.Context(), ingest.Store, c.Params("tag"), true, requeueKey(c)); err != nil {
+		return err
+	}
+	return c.SendStatus(204)
+}
+
+// @Summary Leave allow list mode
+// @Description Index every token of a tag which isn't denied, and requeue the transactions skipped for tokens missing from the allow list
+// @Tags policy
+// @Produce json
+// @Param tag path string true "Token tag (bsv20 or bsv21)"
+// @Param queue query string false "Queue tag to requeue onto, defaults to the ingest queue"
+// @Param Authorization header string true "Bearer ADMIN_KEY"
+// @Success 200 {object} Requeued
+// @Failure 401 {string} string "Missing or wrong admin key"
+// @Failure 403 {string} string "Admin routes disabled, ADMIN_KEY is not set"
+// @Failure 500 {string} string "Internal server error"
+// @Router /v5/policy/{tag}/allowlist [delete]
+func DisableAllowList(c *fiber.Ctx) error {
+	if requeued, err := onesat.SetAllowListMode(c.Context(), ingest.Store, c.Params("tag"), false, requeueKey(c)); err != nil {
+		return err
+	} else {
+		return c.JSON(&Requeued{Requeued: requeued})
+	}
+}
+
+// @Summary Allow a token
+// @Description Add a token to the allow list and requeue the transactions skipped while it was not allowed. The allow list only restricts indexing while the tag is in allow list mode.
+// @Tags policy
+// @Produce json
+// @Param tag path string true "Token tag (bsv20 or bsv21)"
+// @Param token path string true "Ticker or token id"
+// @Param queue query string false "Queue tag to requeue onto, defaults to the ingest queue"
+// @Param Authorization header string true "Bearer ADMIN_KEY"
+// @Success 200 {object} Requeued
+// @Failure 401 {string} string "Missing or wrong admin key"
+// @Failure 403 {string} string "Admin routes disabled, ADMIN_KEY is not set"
+// @Failure 500 {string} string "Internal server error"
+// @Router /v5/policy/{tag}/allow/{token} [put]
+func AllowToken(c *fiber.Ctx) error {
+	if requeued, err := onesat.AllowToken(c.Context(), ingest.Store, c.Params("tag"), c.Params("token"), requeueKey(c)); err != nil {
+		return err
+	} else {
+		return c.JSON(&Requeued{Requeued: requeued})
+	}
+}
+
+// @Summary Deny a token
+// @Description Add a token to the deny list
+// @Tags policy
+// @Param tag path string true "Token tag (bsv20 or bsv21)"
+// @Param token path string true "Ticker or token id"
+// @Param Authorization header string true "Bearer ADMIN_KEY"
+// @Success 204
+// @Failure 401 {string} string "Missing or wrong admin key"
+// @Failure 403 {string} string "Admin routes disabled, ADMIN_KEY is not set"
+// @Failure 500 {string} string "Internal server error"
+// @Router /v5/policy/{tag}/deny/{token} [put]
+func DenyToken(c *fiber.Ctx) error {
+	if err := onesat.DenyToken(c.Context(), ingest.Store, c.Params("tag"), c.Params("token")); err != nil {
+		return err
+	}
+	return c.SendStatus(204)
+}
+
+// @Summary Clear a token policy
+// @Description Remove a token from the allow and deny lists and requeue the transactions skipped for it
+// @Tags policy
+// @Produce json
+// @Param tag path string true "Token tag (bsv20 or bsv21)"
+// @Param token path string true "Ticker or token id"
+// @Param queue query string false "Queue tag to requeue onto, defaults to the ingest queue"
+// @Param Authorization header string true "Bearer ADMIN_KEY"
+// @Success 200 {object} Requeued
+// @Failure 401 {string} string "Missing or wrong admin key"
+// @Failure 403 {string} string "Admin routes disabled, ADMIN_KEY is not set"
+// @Failure 500 {string} string "Internal server error"
+// @Router /v5/policy/{tag}/{token} [delete]
+func ClearToken(c *fiber.Ctx) error {
+	if requeued, err := onesat.ClearToken(c.Context(), ingest.Store, c.Params("tag"), c.Params("token"), requeueKey(c)); err != nil {
+		return err
+	} else {
+		return c.JSON(&Requeued{Requeued: requeued})
+	}
+}
+
+// @Summary Lift a token denial
+// @Description Remove a token from the deny list without changing the allow list, and requeue the transactions skipped for it
+// @Tags policy
+// @Produce json
+// @Param tag path string true "Token tag (bsv20 or bsv21)"
+// @Param token path string true "Ticker or token id"
+// @Param queue query string false "Queue tag to requeue onto, defaults to the ingest queue"
+// @Param Authorization header string true "Bearer ADMIN_KEY"
+// @Success 200 {object} Requeued
+// @Failure 401 {string} string "Missing or wrong admin key"
+// @Failure 403 {string} string "Admin routes disabled, ADMIN_KEY is not set"
+// @Failure 500 {string} string "Internal server error"
+// @Router /v5/policy/{tag}/deny/{token} [delete]
+func UndenyToken(c *fiber.Ctx) error {
+	if requeued, err := onesat.UndenyToken(c.Context(), ingest.Store, c.Params("tag"), c.Params("token"), requeueKey(c)); err != nil {
+		return err
+	} else {
+		return c.JSON(&Requeued{Requeued: requeued})
+	}
+}
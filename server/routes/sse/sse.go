@@ -9,6 +9,7 @@ import (
 	"sync"
 
 	"github.com/redis/go-redis/v9"
+	"github.com/shruggr/1sat-indexer/v5/metrics"
 )
 
 type Session struct {
@@ -27,6 +28,7 @@ type SessionsLock struct {
 func (sl *SessionsLock) AddSession(s *Session) {
 	sl.MU.Lock()
 	sl.Sessions = append(sl.Sessions, s)
+	metrics.SSESessions.Inc()
 	var newSubs []string
 	for _, topic := range s.Topics {
 		if sessions, ok := sl.Topics[topic]; ok {
@@ -61,6 +63,7 @@ func (sl *SessionsLock) RemoveSession(s *Session) {
 		}
 		sl.Sessions[idx] = nil
 		sl.Sessions = slices.Delete(sl.Sessions, idx, idx+1)
+		metrics.SSESessions.Dec()
 	}
 	sl.MU.Unlock()
 	if len(removeSubs) > 0 {
@@ -12,6 +12,8 @@ var ingest *idx.IngestCtx
 func RegisterRoutes(r fiber.Router, ingestCtx *idx.IngestCtx) {
 	ingest = ingestCtx
 	r.Get("/:outpoint", GetTxo)
+	r.Get("/:outpoint/deps", GetDeps)
+	r.Get("/:outpoint/dependents", GetDependents)
 	r.Post("/", GetTxos)
 }
 
@@ -71,3 +73,48 @@ func GetTxos(c *fiber.Ctx) error {
 		return c.JSON(txos)
 	}
 }
+
+// @Summary Get transaction output dependencies
+// @Description Get the outpoints which the indexed data of an output depends on, grouped by tag
+// @Tags txos
+// @Produce json
+// @Param outpoint path string true "Transaction outpoint (txid_vout)"
+// @Param tag query string false "Comma-separated list of tags (defaults to all indexed tags)"
+// @Success 200 {object} map[string][]string
+// @Failure 500 {string} string "Internal server error"
+// @Router /v5/txo/{outpoint}/deps [get]
+func GetDeps(c *fiber.Ctx) error {
+	return searchEdges(c, idx.DepsKey)
+}
+
+// @Summary Get transaction output dependents
+// @Description Get the outpoints whose indexed data depends on an output, grouped by tag
+// @Tags txos
+// @Produce json
+// @Param outpoint path string true "Transaction outpoint (txid_vout)"
+// @Param tag query string false "Comma-separated list of tags (defaults to all indexed tags)"
+// @Success 200 {object} map[string][]string
+// @Failure 500 {string} string "Internal server error"
+// @Router /v5/txo/{outpoint}/dependents [get]
+func GetDependents(c *fiber.Ctx) error {
+	return searchEdges(c, idx.DependentsKey)
+}
+
+func searchEdges(c *fiber.Ctx, edgeKey func(tag string, outpoint string) string) error {
+	outpoint := c.Params("outpoint")
+	tags := ingest.IndexedTags()
+	if tag := c.Query("tag", ""); tag != "" {
+		tags = strings.Split(tag, ",")
+	}
+	edges := make(map[string][]string, len(tags))
+	for _, tag := range tags {
+		if members, err := ingest.Store.SearchMembers(c.Context(), &idx.SearchCfg{
+			Keys: []string{edgeKey(tag, outpoint)},
+		}); err != nil {
+			return err
+		} else if len(members) > 0 {
+			edges[tag] = members
+		}
+	}
+	return c.JSON(edges)
+}
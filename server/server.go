@@ -5,22 +5,40 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
+	ec "github.com/bsv-blockchain/go-sdk/primitives/ec"
 	"github.com/bsv-blockchain/go-sdk/transaction/broadcaster"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
 	"github.com/gofiber/fiber/v2/middleware/compress"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/redis/go-redis/v9"
+	"github.com/shruggr/1sat-indexer/v5/blk"
 	"github.com/shruggr/1sat-indexer/v5/broadcast"
 	"github.com/shruggr/1sat-indexer/v5/idx"
+	"github.com/shruggr/1sat-indexer/v5/metrics"
+	"github.com/shruggr/1sat-indexer/v5/mod/cosign"
+	"github.com/shruggr/1sat-indexer/v5/mod/lock"
+	"github.com/shruggr/1sat-indexer/v5/server/auth"
 	"github.com/shruggr/1sat-indexer/v5/server/routes/acct"
 	"github.com/shruggr/1sat-indexer/v5/server/routes/blocks"
+	"github.com/shruggr/1sat-indexer/v5/server/routes/bsv20"
+	"github.com/shruggr/1sat-indexer/v5/server/routes/bsv21"
+	"github.com/shruggr/1sat-indexer/v5/server/routes/collections"
+	"github.com/shruggr/1sat-indexer/v5/server/routes/content"
+	"github.com/shruggr/1sat-indexer/v5/server/routes/cosigner"
+	"github.com/shruggr/1sat-indexer/v5/server/routes/dlq"
 	"github.com/shruggr/1sat-indexer/v5/server/routes/evt"
+	"github.com/shruggr/1sat-indexer/v5/server/routes/locks"
+	"github.com/shruggr/1sat-indexer/v5/server/routes/market"
+	"github.com/shruggr/1sat-indexer/v5/server/routes/opns"
 	"github.com/shruggr/1sat-indexer/v5/server/routes/origins"
 	"github.com/shruggr/1sat-indexer/v5/server/routes/own"
+	"github.com/shruggr/1sat-indexer/v5/server/routes/policy"
 	"github.com/shruggr/1sat-indexer/v5/server/routes/spend"
 	"github.com/shruggr/1sat-indexer/v5/server/routes/sse"
 	"github.com/shruggr/1sat-indexer/v5/server/routes/tag"
@@ -53,8 +71,25 @@ func Initialize(ingestCtx *idx.IngestCtx, arcBroadcaster *broadcaster.Arc) *fibe
 	})
 	// app.Use(recover.New())
 	app.Use(logger.New())
-	app.Use(compress.New())
+	app.Use(compress.New(compress.Config{
+		// Inscription bodies carry their own Content-Encoding and are served in ranges
+		Next: func(c *fiber.Ctx) bool {
+			return strings.HasPrefix(c.Path(), "/v5/content/")
+		},
+	}))
 	app.Use(cors.New(cors.Config{AllowOrigins: "*"}))
+	app.Use(func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+		status := c.Response().StatusCode()
+		if fe, ok := err.(*fiber.Error); ok {
+			status = fe.Code
+		} else if err != nil {
+			status = fiber.StatusInternalServerError
+		}
+		metrics.APIDuration.WithLabelValues(c.Method(), c.Route().Path, strconv.Itoa(status)).Observe(time.Since(start).Seconds())
+		return err
+	})
 
 	// @Summary Health check
 	// @Description Simple health check endpoint
@@ -65,13 +100,43 @@ func Initialize(ingestCtx *idx.IngestCtx, arcBroadcaster *broadcaster.Arc) *fibe
 		return c.SendString("yo")
 	})
 
+	// @Summary Prometheus metrics
+	// @Description Ingest, audit, upstream request, SSE and store metrics in Prometheus text format
+	// @Tags health
+	// @Produce plain
+	// @Success 200 {string} string "metrics"
+	// @Router /metrics [get]
+	app.Get("/metrics", adaptor.HTTPHandler(metrics.Handler()))
+
 	v5 := app.Group("/v5")
+	admin := auth.Admin(os.Getenv("ADMIN_KEY"))
 
 	acct.RegisterRoutes(v5.Group("/acct"), ingestCtx)
 	blocks.RegisterRoutes(v5.Group("/blocks"))
+	bsv20.RegisterRoutes(v5.Group("/bsv20"), ingestCtx)
+	bsv21.RegisterRoutes(v5.Group("/bsv21"), ingestCtx)
+	collections.RegisterRoutes(v5.Group("/collections"), ingestCtx)
+	content.RegisterRoutes(v5.Group("/content"), ingestCtx)
+	if wif := os.Getenv("COSIGNER_WIF"); wif != "" {
+		if key, err := ec.PrivateKeyFromWif(wif); err != nil {
+			log.Panic("COSIGNER_WIF", err)
+		} else if policies, err := cosign.PoliciesFromEnv(ingestCtx.Store); err != nil {
+			log.Panic("cosign policies", err)
+		} else if len(policies) == 0 {
+			// Without a policy the approver would countersign anything its owners sign
+			log.Panic("COSIGNER_WIF is set without a COSIGN_DESTINATIONS, COSIGN_TOKEN_LIMITS or COSIGN_SPEND_LIMIT policy")
+		} else {
+			cosigner.RegisterRoutes(v5.Group("/cosign"), ingestCtx, arcBroadcaster, cosign.NewApprover(key, ingestCtx, policies...))
+		}
+	}
+	dlq.RegisterRoutes(v5.Group("/dlq"), ingestCtx, admin)
 	evt.RegisterRoutes(v5.Group("/evt"), ingestCtx)
+	locks.RegisterRoutes(v5.Group("/locks"), ingestCtx)
+	market.RegisterRoutes(v5.Group("/market"), ingestCtx)
+	opns.RegisterRoutes(v5.Group("/opns"), ingestCtx)
 	origins.RegisterRoutes(v5.Group("/origins"), ingestCtx)
 	own.RegisterRoutes(v5.Group("/own"), ingestCtx)
+	policy.RegisterRoutes(v5.Group("/policy"), ingestCtx, admin)
 	tag.RegisterRoutes(v5.Group("/tag"), ingestCtx)
 	tx.RegisterRoutes(v5.Group("/tx"), ingestCtx, arcBroadcaster)
 	txos.RegisterRoutes(v5.Group("/txo"), ingestCtx)
@@ -254,6 +319,11 @@ func Initialize(ingestCtx *idx.IngestCtx, arcBroadcaster *broadcaster.Arc) *fibe
 		}
 	}()
 
+	// Announce locks maturing at each new chaintip to SSE subscribers
+	if blk.BLOCK_API != "" {
+		go lock.WatchMaturity(context.Background(), ingestCtx.Store, 10*time.Second)
+	}
+
 	// Broadcast status listener for ARC callbacks
 	go func() {
 		if opts, err := redis.ParseURL(os.Getenv("REDISEVT")); err != nil {